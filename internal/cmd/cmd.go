@@ -15,6 +15,8 @@ var (
 
 // Build returns the root req CLI command.
 func Build() (*cli.Command, error) {
+	var options tui.Options
+
 	return cli.New(
 		"req",
 		cli.Short("Work with .http files on the command line"),
@@ -22,10 +24,14 @@ func Build() (*cli.Command, error) {
 		cli.Version(version),
 		cli.Commit(commit),
 		cli.BuildDate(date),
+		cli.Flag(&options.Env, "env", 'e', "", "Name of the environment to use from http-client.env.json"),
+		cli.Flag(&options.Vars, "var", cli.NoShortHand, nil, "Override a variable as name=value"),
+		cli.Flag(&options.VarFile, "var-file", cli.NoShortHand, "", "Dotenv or JSON file of variable overrides"),
+		cli.Flag(&options.Seed, "seed", cli.NoShortHand, "", "Seed for dynamic variables e.g. $uuid, also freezes $timestamp at this Unix time"),
 		cli.Run(func(cmd *cli.Command, args []string) error {
-			return tui.Run()
+			return tui.Run(options)
 		}),
-		cli.SubCommands(check, show, do),
+		cli.SubCommands(check, format, show, do, run, languageServer),
 	)
 }
 
@@ -39,12 +45,40 @@ func check() (*cli.Command, error) {
 		cli.Allow(cli.MinArgs(1)),
 		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
 		cli.Run(func(cmd *cli.Command, args []string) error {
-			req := req.New(cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
 			return req.Check(args, options)
 		}),
 	)
 }
 
+const fmtLong = `
+Files are rewritten in place, keeping all comments, the order of variables and
+headers and the '#' or '//' style of each comment. Only the layout changes,
+inline request bodies are kept exactly as written.
+
+Use '--check' in CI to fail if any files aren't formatted, or '--diff' to see
+what would change. Neither writes any files.
+`
+
+// format returns the fmt subcommand.
+func format() (*cli.Command, error) {
+	var options req.FmtOptions
+
+	return cli.New(
+		"fmt",
+		cli.Short("Format .http files"),
+		cli.Long(fmtLong),
+		cli.Allow(cli.MinArgs(1)),
+		cli.Flag(&options.Check, "check", 'c', false, "Exit non-zero if any files are not formatted, without writing them"),
+		cli.Flag(&options.Diff, "diff", 'd', false, "Print a diff of the changes, without writing them"),
+		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
+		cli.Run(func(cmd *cli.Command, args []string) error {
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			return req.Fmt(args, options)
+		}),
+	)
+}
+
 // show returns the show subcommand.
 func show() (*cli.Command, error) {
 	var options req.ShowOptions
@@ -61,9 +95,14 @@ func show() (*cli.Command, error) {
 			"Resolve the file handling variable interpolation etc.",
 		),
 		cli.Flag(&options.JSON, "json", 'j', false, "Output the file as JSON"),
+		cli.Flag(&options.Env, "env", 'e', "", "Name of the environment to use with --resolve"),
+		cli.Flag(&options.Vars, "var", cli.NoShortHand, nil, "Override a variable as name=value, used with --resolve"),
+		cli.Flag(&options.VarFile, "var-file", cli.NoShortHand, "", "Dotenv or JSON file of variable overrides, used with --resolve"),
+		cli.Flag(&options.Prompts, "prompt", 'p', nil, "Value for a prompt as name=value, used with --resolve"),
+		cli.Flag(&options.Seed, "seed", cli.NoShortHand, "", "Seed for dynamic variables e.g. $uuid, also freezes $timestamp at this Unix time, used with --resolve"),
 		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
 		cli.Run(func(cmd *cli.Command, args []string) error {
-			req := req.New(cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
 			return req.Show(cmd.Arg("file"), options)
 		}),
 	)
@@ -74,7 +113,56 @@ The request headers, body and other settings will be taken from the
 file but may be overridden by the use of command line flags like
 '--timeout' etc.
 
-Responses can be saved to a file with the '--output' flag.
+Responses can be saved to a file with the '--output' flag, this takes
+precedence over any response redirect e.g. '> ./response.json' declared
+in the file.
+
+A request with a response reference e.g. '<> ./previous.json' has it's
+response body saved there, and each later run shows what changed since the
+last one. Pass '--fail-on-diff' to exit non-zero if it changed, leaving the
+saved response as it was.
+
+A streaming response, such as server-sent events (text/event-stream) or
+newline delimited JSON, is printed as it arrives. The timeouts stop once it's
+headers arrive, after that Ctrl-C stops it cleanly. Pass '--stream' to stream
+any other response.
+
+A WEBSOCKET request sends it's messages, separated by '===' lines, and
+prints every message sent and received with the time. A '=== wait-for-server'
+line waits for a message from the server before going on. With
+'--interactive', or if the request has no messages, each line typed on stdin
+is then sent as a message until EOF (Ctrl-D).
+
+A GRPC request calls a unary gRPC method with it's JSON body, described by
+the server with reflection or by the '.proto' file declared with '@proto',
+and prints the status, response message and metadata.
+
+Variables may also be loaded from a named environment with '--env', these
+are read from 'http-client.env.json' and 'http-client.private.env.json' in
+the same directory as the .http file.
+
+Any variable may be overridden with '--var name=value' or a dotenv or JSON
+'--var-file'. From lowest to highest, the precedence of variables is:
+
+  1. The environment selected with '--env'
+  2. Global variables declared in the .http file
+  3. Variables declared in the request
+  4. '--var-file'
+  5. '--var'
+
+An overridden '@prompt' variable is never asked for.
+
+Values for '@prompt' variables are asked for interactively, unless provided
+with '--prompt name=value' or a '$REQ_PROMPT_<NAME>' environment variable.
+If stdin is not a terminal (e.g. in CI), a missing value is an error.
+
+Dynamic variables like '{{$uuid}}' and '{{$timestamp}}' change every time,
+pass '--seed N' to make them reproducible. Random values are then drawn from
+the seed and the clock is frozen at N seconds after the Unix epoch, so
+'{{$timestamp}}' is always N.
+
+A request may use the response to another request in the same file e.g.
+'{{login.response.body.$.token}}', the referenced request is sent first.
 `
 
 // do returns the do subcommand.
@@ -97,10 +185,97 @@ func do() (*cli.Command, error) {
 		),
 		cli.Flag(&options.NoRedirect, "no-redirect", cli.NoShortHand, false, "Disable following redirects"),
 		cli.Flag(&options.Output, "output", 'o', "", "Name of a file to save the response"),
+		cli.Flag(&options.FailOnDiff, "fail-on-diff", cli.NoShortHand, false, "Exit non-zero if the response differs from it's reference"),
+		cli.Flag(&options.Env, "env", 'e', "", "Name of the environment to use from http-client.env.json"),
+		cli.Flag(&options.Vars, "var", cli.NoShortHand, nil, "Override a variable as name=value"),
+		cli.Flag(&options.VarFile, "var-file", cli.NoShortHand, "", "Dotenv or JSON file of variable overrides"),
+		cli.Flag(&options.Prompts, "prompt", 'p', nil, "Value for a prompt as name=value, skipping the prompt"),
+		cli.Flag(&options.Seed, "seed", cli.NoShortHand, "", "Seed for dynamic variables e.g. $uuid, also freezes $timestamp at this Unix time"),
+		cli.Flag(&options.Stream, "stream", cli.NoShortHand, false, "Print the response body as it arrives"),
+		cli.Flag(&options.Interactive, "interactive", 'i', false, "Send lines typed on stdin as WebSocket messages"),
 		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
 		cli.Run(func(cmd *cli.Command, args []string) error {
-			req := req.New(cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
 			return req.Do(cmd.Arg("file"), cmd.Arg("name"), options)
 		}),
 	)
 }
+
+const runLong = `
+Requests are sent one after the other in the order they appear in the file,
+sharing cookies so e.g. a session from a login request is used by the rest.
+Any request may use the response to another with a reference like
+'{{login.response.body.$.token}}'.
+
+A request fails if it can't be sent or any of its assertions fail e.g.
+'?? status == 200' or, if it has none, its response has an error status
+(400 or above). Every request is run regardless, unless '--fail-fast' is
+given, and req exits non-zero if any of them failed.
+
+Use '--filter' to only run requests whose name matches a regular expression,
+requests they reference are still sent as needed.
+
+With '--parallel N', up to N requests are sent at once. Requests are still
+started in file order and the results reported in it, a request that
+references another waits for it. Ctrl-C cancels any requests in flight.
+
+Use '--report junit=report.xml' or '--report json=report.json' (or both) to
+write machine readable results for CI, failed requests include the request
+and response that was received.
+`
+
+// run returns the run subcommand.
+func run() (*cli.Command, error) {
+	var options req.RunOptions
+
+	return cli.New(
+		"run",
+		cli.Short("Execute every http request in a file"),
+		cli.Long(runLong),
+		cli.RequiredArg("file", ".http file containing the requests"),
+		cli.Flag(&options.Filter, "filter", 'f', "", "Only run requests whose name matches this regular expression"),
+		cli.Flag(&options.FailFast, "fail-fast", cli.NoShortHand, false, "Stop at the first failed request"),
+		cli.Flag(&options.Parallel, "parallel", cli.NoShortHand, 1, "Maximum number of requests to send at once"),
+		cli.Flag(&options.Reports, "report", cli.NoShortHand, nil, "Write a report as format=path, format is junit or json"),
+		cli.Flag(&options.Env, "env", 'e', "", "Name of the environment to use from http-client.env.json"),
+		cli.Flag(&options.Vars, "var", cli.NoShortHand, nil, "Override a variable as name=value"),
+		cli.Flag(&options.VarFile, "var-file", cli.NoShortHand, "", "Dotenv or JSON file of variable overrides"),
+		cli.Flag(&options.Prompts, "prompt", 'p', nil, "Value for a prompt as name=value, skipping the prompt"),
+		cli.Flag(&options.Seed, "seed", cli.NoShortHand, "", "Seed for dynamic variables e.g. $uuid, also freezes $timestamp at this Unix time"),
+		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
+		cli.Run(func(cmd *cli.Command, args []string) error {
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			return req.Run(cmd.Arg("file"), options)
+		}),
+	)
+}
+
+const lspLong = `
+The server talks to the editor over stdin and stdout, configure your editor to
+start 'req lsp' for .http files. It reports syntax errors as you type, completes
+variable names after '{{', shows a variable's value on hover, jumps to where a
+variable or request is declared and lists the requests in the file.
+
+The request under the cursor can be sent with a code action, the response is
+shown as a message. Use '--env' to pick the environment whose variables are used
+for hover and sending, without one the variables from every environment are
+offered.
+`
+
+// languageServer returns the lsp subcommand.
+func languageServer() (*cli.Command, error) {
+	var options req.LSPOptions
+
+	return cli.New(
+		"lsp",
+		cli.Short("Start the language server for .http files"),
+		cli.Long(lspLong),
+		cli.Allow(cli.NoArgs()),
+		cli.Flag(&options.Env, "env", 'e', "", "Name of the environment to use from http-client.env.json"),
+		cli.Flag(&options.Verbose, "verbose", 'v', false, "Enable debug logging"),
+		cli.Run(func(cmd *cli.Command, args []string) error {
+			req := req.New(cmd.Stdin(), cmd.Stdout(), cmd.Stderr(), options.Verbose)
+			return req.LSP(options)
+		}),
+	)
+}
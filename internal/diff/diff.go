@@ -0,0 +1,183 @@
+// Package diff implements a line based unified diff, as printed by `req fmt --diff`, and a
+// structural diff of JSON documents, as printed by `req do` for a response reference.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// noNewline marks a final line that doesn't end in a newline.
+const noNewline = "\n\\ No newline at end of file"
+
+// edit is a single line in the diff.
+type edit struct {
+	text string // The line, without it's newline
+	kind byte   // ' ' if the line is in both, '-' if it was removed and '+' if it was added
+}
+
+// Unified returns a unified diff from old to new, with 3 lines of context around
+// each change. The names label the two sides in the header.
+//
+// If old and new are the same, it returns nil.
+func Unified(oldName, newName string, old, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+
+	edits := compare(lines(old), lines(new))
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", oldName, newName)
+
+	for start := 0; start < len(edits); {
+		// Find the next change
+		for start < len(edits) && edits[start].kind == ' ' {
+			start++
+		}
+
+		if start == len(edits) {
+			break
+		}
+
+		// Extend the hunk until there's a gap of unchanged lines too big to show
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*context {
+				break
+			}
+		}
+
+		from := max(start-context, 0)
+		to := min(end+context, len(edits))
+
+		writeHunk(buf, edits, from, to)
+
+		start = to
+	}
+
+	return buf.Bytes()
+}
+
+// writeHunk writes the hunk of edits[from:to], including it's header.
+func writeHunk(buf *bytes.Buffer, edits []edit, from, to int) {
+	// Line numbers are 1 indexed, so start at the line before
+	oldStart, newStart := 1, 1
+
+	for _, e := range edits[:from] {
+		if e.kind != '+' {
+			oldStart++
+		}
+
+		if e.kind != '-' {
+			newStart++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+
+	for _, e := range edits[from:to] {
+		if e.kind != '+' {
+			oldCount++
+		}
+
+		if e.kind != '-' {
+			newCount++
+		}
+	}
+
+	// An empty range is numbered from the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+
+	if newCount == 0 {
+		newStart--
+	}
+
+	fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+	for _, e := range edits[from:to] {
+		buf.WriteByte(e.kind)
+		buf.WriteString(e.text)
+		buf.WriteByte('\n')
+	}
+}
+
+// compare returns the edits that turn old into new, using the longest common
+// subsequence of lines. The files diffed are small so the quadratic table is fine.
+func compare(old, new []string) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of old[i:] and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]edit, 0, max(len(old), len(new)))
+
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			edits = append(edits, edit{kind: ' ', text: old[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{kind: '-', text: old[i]})
+			i++
+		default:
+			edits = append(edits, edit{kind: '+', text: new[j]})
+			j++
+		}
+	}
+
+	for ; i < len(old); i++ {
+		edits = append(edits, edit{kind: '-', text: old[i]})
+	}
+
+	for ; j < len(new); j++ {
+		edits = append(edits, edit{kind: '+', text: new[j]})
+	}
+
+	return edits
+}
+
+// lines splits src into lines without their newlines, a final line without one
+// is marked as such.
+func lines(src []byte) []string {
+	if len(src) == 0 {
+		return nil
+	}
+
+	split := bytes.Split(src, []byte("\n"))
+
+	last := len(split) - 1
+	if len(split[last]) == 0 {
+		// Ended in a newline
+		split = split[:last]
+	} else {
+		split[last] = append(split[last], noNewline...)
+	}
+
+	result := make([]string, 0, len(split))
+	for _, line := range split {
+		result = append(result, string(line))
+	}
+
+	return result
+}
@@ -0,0 +1,146 @@
+package diff_test
+
+import (
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/diff"
+	"go.followtheprocess.codes/test"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string // Name of the test case
+		old  string // Old contents
+		new  string // New contents
+		want string // Expected diff
+	}{
+		{
+			name: "same",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "change",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "add to empty",
+			old:  "",
+			new:  "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name: "missing newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name: "merged hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\neight\n",
+			want: "--- old\n+++ new\n@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diff.Unified("old", "new", []byte(tt.old), []byte(tt.new))
+			test.Diff(t, string(got), tt.want)
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name    string // Name of the test case
+		old     string // Old JSON document
+		new     string // New JSON document
+		want    string // Expected diff
+		wantErr bool   // Whether it should return an error
+	}{
+		{
+			name: "same",
+			old:  `{"a": 1, "b": [1, 2]}`,
+			new:  `{"a": 1, "b": [1, 2]}`,
+			want: "",
+		},
+		{
+			name: "key order and whitespace",
+			old:  `{"a": 1, "b": {"c": true, "d": null}}`,
+			new:  "{\n  \"b\": {\"d\": null, \"c\": true},\n  \"a\": 1.0\n}",
+			want: "",
+		},
+		{
+			name: "changed",
+			old:  `{"name": "old", "count": 1}`,
+			new:  `{"name": "new", "count": 2}`,
+			want: "~ $.count: 1 => 2\n~ $.name: \"old\" => \"new\"\n",
+		},
+		{
+			name: "big integers",
+			old:  `{"id": 9007199254740993, "same": 12345678901234567890}`,
+			new:  `{"id": 9007199254740992, "same": 12345678901234567890}`,
+			want: "~ $.id: 9007199254740993 => 9007199254740992\n",
+		},
+		{
+			name: "same number written differently",
+			old:  `{"a": 100, "b": 0.1}`,
+			new:  `{"a": 1e2, "b": 1.0E-1}`,
+			want: "",
+		},
+		{
+			name: "added and removed keys",
+			old:  `{"a": 1, "gone": {"x": [1]}}`,
+			new:  `{"a": 1, "z": "<new>", "b": 2}`,
+			want: "- $.gone: {\"x\":[1]}\n+ $.b: 2\n+ $.z: \"<new>\"\n",
+		},
+		{
+			name: "arrays",
+			old:  `{"items": [{"id": 1}, {"id": 2}, {"id": 3}]}`,
+			new:  `{"items": [{"id": 1}, {"id": 20}]}`,
+			want: "~ $.items[1].id: 2 => 20\n- $.items[2]: {\"id\":3}\n",
+		},
+		{
+			name: "changed type",
+			old:  `{"a": [1]}`,
+			new:  `{"a": {"0": 1}}`,
+			want: "~ $.a: [1] => {\"0\":1}\n",
+		},
+		{
+			name: "awkward keys",
+			old:  `{"content-type": "json", "with space": 1}`,
+			new:  `{"content-type": "xml", "with space": 1}`,
+			want: "~ $[\"content-type\"]: \"json\" => \"xml\"\n",
+		},
+		{
+			name: "top level",
+			old:  `"hello"`,
+			new:  `"there"`,
+			want: "~ $: \"hello\" => \"there\"\n",
+		},
+		{
+			name:    "invalid",
+			old:     `{"a": 1}`,
+			new:     `not json`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diff.JSON([]byte(tt.old), []byte(tt.new))
+			test.WantErr(t, err, tt.wantErr)
+			test.Diff(t, string(got), tt.want)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// identifier matches object keys that can be written as '.key' in a JSONPath, anything
+// else is written as '["key"]'.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// JSON returns a structural diff from the old to the new JSON document, one line
+// for each value that was changed, removed or added, identified by it's JSONPath:
+//
+//	~ $.items[0].name: "old" => "new"
+//	- $.total: 2
+//	+ $.items[2]: {"name":"new"}
+//
+// Object keys are compared regardless of their order and whitespace is ignored, so
+// only changes to the data itself are shown.
+//
+// If old and new are equivalent, it returns nil. An error is returned if either
+// isn't valid JSON.
+func JSON(old, new []byte) ([]byte, error) {
+	oldValue, err := decode(old)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode old JSON: %w", err)
+	}
+
+	newValue, err := decode(new)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode new JSON: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	compareJSON(buf, "$", oldValue, newValue)
+
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode decodes a JSON document, keeping numbers as they were written so that
+// large integers aren't rounded.
+func decode(src []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// compareJSON writes the differences between two decoded JSON values at path, recursing
+// into objects and arrays.
+func compareJSON(buf *bytes.Buffer, path string, old, new any) {
+	switch old := old.(type) {
+	case map[string]any:
+		if new, ok := new.(map[string]any); ok {
+			keys := slices.Sorted(maps.Keys(old))
+			for key := range new {
+				if _, ok := old[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+
+			// Removed and changed keys in order, then any that were added
+			slices.Sort(keys[len(old):])
+
+			for _, key := range keys {
+				child := path + member(key)
+				oldChild, inOld := old[key]
+				newChild, inNew := new[key]
+
+				switch {
+				case !inNew:
+					fmt.Fprintf(buf, "- %s: %s\n", child, compact(oldChild))
+				case !inOld:
+					fmt.Fprintf(buf, "+ %s: %s\n", child, compact(newChild))
+				default:
+					compareJSON(buf, child, oldChild, newChild)
+				}
+			}
+
+			return
+		}
+	case []any:
+		if new, ok := new.([]any); ok {
+			for index := range max(len(old), len(new)) {
+				child := path + "[" + strconv.Itoa(index) + "]"
+
+				switch {
+				case index >= len(new):
+					fmt.Fprintf(buf, "- %s: %s\n", child, compact(old[index]))
+				case index >= len(old):
+					fmt.Fprintf(buf, "+ %s: %s\n", child, compact(new[index]))
+				default:
+					compareJSON(buf, child, old[index], new[index])
+				}
+			}
+
+			return
+		}
+	}
+
+	if !equal(old, new) {
+		fmt.Fprintf(buf, "~ %s: %s => %s\n", path, compact(old), compact(new))
+	}
+}
+
+// equal reports whether two decoded JSON scalars are the same, numbers are compared
+// by their exact value so e.g. 1.0 and 1 are equal but integers too big for a float64
+// are still told apart.
+func equal(old, new any) bool {
+	oldNumber, oldOK := old.(json.Number)
+	newNumber, newOK := new.(json.Number)
+
+	if oldOK && newOK {
+		if oldNumber == newNumber {
+			return true
+		}
+
+		// big.Rat refuses absurd exponents, in which case fall back to the literals
+		var oldRat, newRat big.Rat
+
+		_, oldOK = oldRat.SetString(oldNumber.String())
+		_, newOK = newRat.SetString(newNumber.String())
+
+		if oldOK && newOK {
+			return oldRat.Cmp(&newRat) == 0
+		}
+	}
+
+	return reflect.DeepEqual(old, new)
+}
+
+// member returns the JSONPath selector for an object key.
+func member(key string) string {
+	if identifier.MatchString(key) {
+		return "." + key
+	}
+
+	return "[" + strconv.Quote(key) + "]"
+}
+
+// compact returns a decoded JSON value as compact JSON.
+func compact(value any) string {
+	buf := &bytes.Buffer{}
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
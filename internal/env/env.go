@@ -0,0 +1,189 @@
+// Package env implements loading JetBrains compatible environment files, these hold named
+// sets of variables (e.g. "dev", "staging", "prod") that may be selected when
+// working with a .http file.
+//
+// There are two files, both of which are looked for in the same directory as the .http file:
+//
+//   - 'http-client.env.json' holds the general environment variables and is intended to be
+//     checked into version control
+//   - 'http-client.private.env.json' holds secrets (tokens, passwords etc.) and should
+//     not be checked into version control
+//
+// Both files are JSON objects of environment name to an object of variables:
+//
+//	{
+//	  "$shared": {
+//	    "version": "v1"
+//	  },
+//	  "dev": {
+//	    "base": "http://localhost:8080"
+//	  },
+//	  "prod": {
+//	    "base": "https://api.company.com"
+//	  }
+//	}
+//
+// The special "$shared" environment holds variables available to every environment.
+//
+// See https://www.jetbrains.com/help/idea/exploring-http-syntax.html#environment-variables.
+package env
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/vars"
+)
+
+const (
+	// PublicFile is the name of the file holding general environment variables.
+	PublicFile = "http-client.env.json"
+
+	// PrivateFile is the name of the file holding private environment variables.
+	PrivateFile = "http-client.private.env.json"
+
+	// Shared is the name of the environment holding variables common to all environments.
+	Shared = "$shared"
+)
+
+// Value is a single variable loaded from an environment file.
+type Value struct {
+	Value  string // The variable's value
+	Source string // Path to the file the value came from
+}
+
+// Load loads the variables for the named environment from the environment files
+// in dir, returning a map of variable name to [Value].
+//
+// Values are merged in order of increasing precedence:
+//
+//  1. The "$shared" environment in the public file
+//  2. The named environment in the public file
+//  3. The "$shared" environment in the private file
+//  4. The named environment in the private file
+//
+// Either file may be missing, but it is an error if the named environment isn't
+// declared in either of them.
+func Load(dir, name string) (map[string]Value, error) {
+	values := make(map[string]Value)
+	found := false
+
+	var available []string
+
+	for _, file := range []string{PublicFile, PrivateFile} {
+		path := filepath.Join(dir, file)
+
+		environments, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, env := range []string{Shared, name} {
+			vars, ok := environments[env]
+			if !ok {
+				continue
+			}
+
+			if env == name {
+				found = true
+			}
+
+			for key, value := range vars {
+				values[key] = Value{Value: value, Source: path}
+			}
+		}
+
+		for env := range environments {
+			if env != Shared && !slices.Contains(available, env) {
+				available = append(available, env)
+			}
+		}
+	}
+
+	if !found {
+		if len(available) == 0 {
+			return nil, fmt.Errorf("environment %q not found: no environments declared in %s", name, dir)
+		}
+
+		slices.Sort(available)
+
+		return nil, fmt.Errorf(
+			"environment %q not found, available environments: %s",
+			name,
+			strings.Join(available, ", "),
+		)
+	}
+
+	return values, nil
+}
+
+// All loads the variables for every environment declared in the environment files in
+// dir, returning a map of environment name (including "$shared") to it's variables.
+//
+// Unlike [Load], the "$shared" variables aren't merged into each environment. Where an
+// environment is declared in both files, the private file's values take precedence.
+func All(dir string) (map[string]map[string]Value, error) {
+	all := make(map[string]map[string]Value)
+
+	for _, file := range []string{PublicFile, PrivateFile} {
+		path := filepath.Join(dir, file)
+
+		environments, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for env, vars := range environments {
+			if all[env] == nil {
+				all[env] = make(map[string]Value, len(vars))
+			}
+
+			for key, value := range vars {
+				all[env][key] = Value{Value: value, Source: path}
+			}
+		}
+	}
+
+	return all, nil
+}
+
+// Strings returns just the values of a set of variables as returned from [Load].
+func Strings(values map[string]Value) map[string]string {
+	strs := make(map[string]string, len(values))
+	for key, value := range values {
+		strs[key] = value.Value
+	}
+
+	return strs
+}
+
+// read reads an environment file, returning a map of environment name to
+// it's variables. A file that does not exist has no environments.
+func read(path string) (map[string]map[string]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]map[string]string{}, nil
+		}
+
+		return nil, fmt.Errorf("could not read environment file: %w", err)
+	}
+
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &raw); err != nil {
+		return nil, fmt.Errorf("invalid environment file %s: %w", path, err)
+	}
+
+	environments := make(map[string]map[string]string, len(raw))
+	for env, rawVars := range raw {
+		environments[env] = vars.FromJSON(rawVars)
+	}
+
+	return environments, nil
+}
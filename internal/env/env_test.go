@@ -0,0 +1,163 @@
+package env_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/env"
+	"go.followtheprocess.codes/test"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		want    map[string]string // Expected variables
+		public  string            // Contents of the public env file, empty means missing
+		private string            // Contents of the private env file, empty means missing
+		name    string            // Name of the test case
+		env     string            // Name of the environment to load
+		wantErr bool              // Whether we want an error
+	}{
+		{
+			name:   "public only",
+			public: `{"dev": {"base": "http://localhost:8080", "version": "v1"}}`,
+			env:    "dev",
+			want:   map[string]string{"base": "http://localhost:8080", "version": "v1"},
+		},
+		{
+			name:    "private only",
+			private: `{"dev": {"token": "secret"}}`,
+			env:     "dev",
+			want:    map[string]string{"token": "secret"},
+		},
+		{
+			name:    "private overrides public",
+			public:  `{"dev": {"base": "http://localhost:8080", "token": "placeholder"}}`,
+			private: `{"dev": {"token": "secret"}}`,
+			env:     "dev",
+			want:    map[string]string{"base": "http://localhost:8080", "token": "secret"},
+		},
+		{
+			name:   "shared",
+			public: `{"$shared": {"version": "v1", "base": "shared"}, "dev": {"base": "http://localhost:8080"}}`,
+			env:    "dev",
+			want:   map[string]string{"base": "http://localhost:8080", "version": "v1"},
+		},
+		{
+			name:    "private shared overrides public named",
+			public:  `{"dev": {"token": "placeholder"}}`,
+			private: `{"$shared": {"token": "secret"}}`,
+			env:     "dev",
+			want:    map[string]string{"token": "secret"},
+		},
+		{
+			name:   "other environments ignored",
+			public: `{"dev": {"base": "http://localhost:8080"}, "prod": {"base": "https://api.com", "other": "yes"}}`,
+			env:    "dev",
+			want:   map[string]string{"base": "http://localhost:8080"},
+		},
+		{
+			name:   "non string values",
+			public: `{"dev": {"port": 8080, "debug": true, "ids": [1, 2], "user": {"name": "bob"}, "none": null}}`,
+			env:    "dev",
+			want: map[string]string{
+				"port":  "8080",
+				"debug": "true",
+				"ids":   "[1,2]",
+				"user":  `{"name":"bob"}`,
+				"none":  "",
+			},
+		},
+		{
+			name:    "missing environment",
+			public:  `{"dev": {"base": "http://localhost:8080"}}`,
+			private: `{"staging": {"token": "secret"}}`,
+			env:     "prod",
+			wantErr: true,
+		},
+		{
+			name:    "shared is not an environment",
+			public:  `{"$shared": {"version": "v1"}}`,
+			env:     "dev",
+			wantErr: true,
+		},
+		{
+			name:    "no files",
+			env:     "dev",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			public:  `{"dev": {"base": }}`,
+			env:     "dev",
+			wantErr: true,
+		},
+		{
+			name:    "wrong shape",
+			public:  `{"dev": "http://localhost:8080"}`,
+			env:     "dev",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			if tt.public != "" {
+				test.Ok(t, os.WriteFile(filepath.Join(dir, env.PublicFile), []byte(tt.public), 0o644))
+			}
+
+			if tt.private != "" {
+				test.Ok(t, os.WriteFile(filepath.Join(dir, env.PrivateFile), []byte(tt.private), 0o644))
+			}
+
+			got, err := env.Load(dir, tt.env)
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.EqualFunc(t, env.Strings(got), tt.want, maps.Equal)
+			}
+		})
+	}
+}
+
+func TestLoadSource(t *testing.T) {
+	dir := t.TempDir()
+
+	public := filepath.Join(dir, env.PublicFile)
+	private := filepath.Join(dir, env.PrivateFile)
+
+	test.Ok(t, os.WriteFile(public, []byte(`{"dev": {"base": "http://localhost:8080", "token": "placeholder"}}`), 0o644))
+	test.Ok(t, os.WriteFile(private, []byte(`{"dev": {"token": "secret"}}`), 0o644))
+
+	got, err := env.Load(dir, "dev")
+	test.Ok(t, err)
+
+	test.Equal(t, got["base"], env.Value{Value: "http://localhost:8080", Source: public})
+	test.Equal(t, got["token"], env.Value{Value: "secret", Source: private})
+}
+
+func TestAll(t *testing.T) {
+	dir := t.TempDir()
+
+	public := filepath.Join(dir, env.PublicFile)
+	private := filepath.Join(dir, env.PrivateFile)
+
+	test.Ok(t, os.WriteFile(public, []byte(`{"$shared": {"version": "v1"}, "dev": {"base": "http://localhost:8080", "token": "placeholder"}, "prod": {"base": "https://api.com"}}`), 0o644))
+	test.Ok(t, os.WriteFile(private, []byte(`{"dev": {"token": "secret"}}`), 0o644))
+
+	got, err := env.All(dir)
+	test.Ok(t, err)
+
+	test.Equal(t, len(got), 3)
+	test.Equal(t, got[env.Shared]["version"], env.Value{Value: "v1", Source: public})
+	test.Equal(t, got["dev"]["base"], env.Value{Value: "http://localhost:8080", Source: public})
+	test.Equal(t, got["dev"]["token"], env.Value{Value: "secret", Source: private})
+	test.Equal(t, got["prod"]["base"], env.Value{Value: "https://api.com", Source: public})
+
+	// The shared variables aren't merged in
+	_, ok := got["prod"]["version"]
+	test.False(t, ok)
+}
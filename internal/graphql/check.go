@@ -0,0 +1,549 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// bom is the unicode byte order mark, which GraphQL ignores.
+const bom = "\ufeff"
+
+// SyntaxError is a syntax error in a GraphQL query.
+type SyntaxError struct {
+	Msg    string // The error message
+	Offset int    // Byte offset of the error from the start of the query
+	Line   int    // Line number (1 indexed)
+	Col    int    // Column (1 indexed)
+}
+
+// Error implements the error interface for a [SyntaxError].
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Check checks the syntax of a GraphQL query document, made up of one or more operations
+// (e.g. 'query', 'mutation' or the '{ ... }' shorthand) and fragments. If it's invalid,
+// the returned error is a [*SyntaxError] describing the first problem found.
+func Check(query string) error {
+	p := &parser{src: query}
+	p.next()
+
+	if p.tok.kind == kindEOF {
+		p.fail("empty query, expected a query, mutation, subscription or fragment")
+	}
+
+	for p.tok.kind != kindEOF {
+		p.definition()
+	}
+
+	if p.err != nil {
+		return p.err
+	}
+
+	return nil
+}
+
+// kind is the kind of a GraphQL lexical token.
+type kind int
+
+const (
+	kindEOF    kind = iota // The end of the query
+	kindPunct              // One of '!', '$', '&', '(', ')', '...', ':', '=', '@', '[', ']', '{', '|' or '}'
+	kindName               // A name e.g. 'user'
+	kindInt                // An integer e.g. '-12'
+	kindFloat              // A float e.g. '1.5e3'
+	kindString             // A string e.g. '"hello"' or a '"""block string"""'
+)
+
+// token is a single GraphQL lexical token.
+type token struct {
+	value  string // The source text of the token
+	offset int    // Byte offset of the start of the token
+	kind   kind   // The kind of token
+}
+
+// String implements [fmt.Stringer] for a [token], describing it for error messages.
+func (t token) String() string {
+	switch t.kind {
+	case kindEOF:
+		return "end of query"
+	case kindPunct:
+		return "'" + t.value + "'"
+	case kindName:
+		return "name " + strconv.Quote(t.value)
+	case kindInt, kindFloat:
+		return "number " + t.value
+	default:
+		return "string " + t.value
+	}
+}
+
+// parser is a recursive descent parser for GraphQL executable documents, it stops at
+// the first error it finds.
+type parser struct {
+	err *SyntaxError // The first error, after which every token is kindEOF
+	src string       // The query
+	tok token        // The current token
+	pos int          // Byte offset of the next character to be lexed
+}
+
+// fail records a syntax error at the current token, unless there already is one, and
+// stops the parser.
+func (p *parser) fail(msg string) {
+	p.failAt(p.tok.offset, msg)
+}
+
+// failAt records a syntax error at offset, unless there already is one, and stops the parser.
+func (p *parser) failAt(offset int, msg string) {
+	if p.err == nil {
+		before := p.src[:offset]
+		line := 1 + strings.Count(before, "\n")
+		col := 1 + offset - (strings.LastIndex(before, "\n") + 1)
+
+		p.err = &SyntaxError{Msg: msg, Offset: offset, Line: line, Col: col}
+	}
+
+	p.pos = len(p.src)
+	p.tok = token{kind: kindEOF, offset: len(p.src)}
+}
+
+// expected records an error saying what was expected in place of the current token.
+func (p *parser) expected(what string) {
+	p.fail(fmt.Sprintf("expected %s, got %s", what, p.tok))
+}
+
+// isPunct reports whether the current token is the punctuator punct.
+func (p *parser) isPunct(punct string) bool {
+	return p.tok.kind == kindPunct && p.tok.value == punct
+}
+
+// isName reports whether the current token is the name (or keyword) name.
+func (p *parser) isName(name string) bool {
+	return p.tok.kind == kindName && p.tok.value == name
+}
+
+// expectPunct consumes the punctuator punct, or fails if it's not the current token.
+func (p *parser) expectPunct(punct string) {
+	if !p.isPunct(punct) {
+		p.expected("'" + punct + "'")
+		return
+	}
+
+	p.next()
+}
+
+// expectName consumes a name, or fails if the current token isn't one. what describes
+// the name for the error message.
+func (p *parser) expectName(what string) {
+	if p.tok.kind != kindName {
+		p.expected(what)
+		return
+	}
+
+	p.next()
+}
+
+// definition parses a single operation or fragment definition.
+func (p *parser) definition() {
+	switch {
+	case p.isPunct("{"):
+		p.selectionSet()
+	case p.isName("query"), p.isName("mutation"), p.isName("subscription"):
+		p.next()
+
+		if p.tok.kind == kindName {
+			p.next()
+		}
+
+		if p.isPunct("(") {
+			p.variableDefinitions()
+		}
+
+		p.directives(false)
+		p.selectionSet()
+	case p.isName("fragment"):
+		p.next()
+
+		if p.isName("on") {
+			p.fail("a fragment can't be called 'on'")
+			return
+		}
+
+		p.expectName("a fragment name")
+		p.typeCondition()
+		p.directives(false)
+		p.selectionSet()
+	default:
+		p.expected("a query, mutation, subscription or fragment")
+	}
+}
+
+// selectionSet parses a '{ ... }' block of one or more fields and fragments.
+func (p *parser) selectionSet() {
+	p.expectPunct("{")
+
+	if p.isPunct("}") {
+		p.fail("a selection set must select at least one field")
+		return
+	}
+
+	for !p.isPunct("}") && p.tok.kind != kindEOF {
+		p.selection()
+	}
+
+	p.expectPunct("}")
+}
+
+// selection parses a single field, fragment spread or inline fragment.
+func (p *parser) selection() {
+	if p.isPunct("...") {
+		p.next()
+
+		switch {
+		case p.isName("on"):
+			p.typeCondition()
+			p.directives(false)
+			p.selectionSet()
+		case p.tok.kind == kindName:
+			p.next()
+			p.directives(false)
+		default:
+			p.directives(false)
+			p.selectionSet()
+		}
+
+		return
+	}
+
+	p.expectName("a field")
+
+	// An alias e.g. 'smallPic: profilePic'
+	if p.isPunct(":") {
+		p.next()
+		p.expectName("a field")
+	}
+
+	if p.isPunct("(") {
+		p.arguments(false)
+	}
+
+	p.directives(false)
+
+	if p.isPunct("{") {
+		p.selectionSet()
+	}
+}
+
+// typeCondition parses the 'on Type' of a fragment.
+func (p *parser) typeCondition() {
+	if !p.isName("on") {
+		p.expected("'on'")
+		return
+	}
+
+	p.next()
+	p.expectName("a type")
+}
+
+// arguments parses a '(name: value, ...)' list of arguments, if constant then variables
+// aren't allowed in the values.
+func (p *parser) arguments(constant bool) {
+	p.expectPunct("(")
+
+	if p.isPunct(")") {
+		p.fail("an argument list must have at least one argument")
+		return
+	}
+
+	for !p.isPunct(")") && p.tok.kind != kindEOF {
+		p.expectName("an argument name")
+		p.expectPunct(":")
+		p.value(constant)
+	}
+
+	p.expectPunct(")")
+}
+
+// directives parses any number of directives e.g. '@include(if: $withFriends)'.
+func (p *parser) directives(constant bool) {
+	for p.isPunct("@") {
+		p.next()
+		p.expectName("a directive name")
+
+		if p.isPunct("(") {
+			p.arguments(constant)
+		}
+	}
+}
+
+// variableDefinitions parses the '($id: ID!, $first: Int = 10)' variables of an operation.
+func (p *parser) variableDefinitions() {
+	p.expectPunct("(")
+
+	if p.isPunct(")") {
+		p.fail("a variable list must declare at least one variable")
+		return
+	}
+
+	for !p.isPunct(")") && p.tok.kind != kindEOF {
+		p.variable()
+		p.expectPunct(":")
+		p.typeReference()
+
+		if p.isPunct("=") {
+			p.next()
+			p.value(true)
+		}
+
+		p.directives(true)
+	}
+
+	p.expectPunct(")")
+}
+
+// variable parses a variable e.g. '$id'.
+func (p *parser) variable() {
+	p.expectPunct("$")
+	p.expectName("a variable name")
+}
+
+// typeReference parses a type e.g. 'ID', '[String]' or '[Int!]!'.
+func (p *parser) typeReference() {
+	if p.isPunct("[") {
+		p.next()
+		p.typeReference()
+		p.expectPunct("]")
+	} else {
+		p.expectName("a type")
+	}
+
+	if p.isPunct("!") {
+		p.next()
+	}
+}
+
+// value parses a value, if constant then variables aren't allowed.
+func (p *parser) value(constant bool) {
+	switch {
+	case p.isPunct("$"):
+		if constant {
+			p.fail("variables aren't allowed in a constant value")
+			return
+		}
+
+		p.variable()
+	case p.tok.kind == kindInt, p.tok.kind == kindFloat, p.tok.kind == kindString, p.tok.kind == kindName:
+		p.next()
+	case p.isPunct("["):
+		p.next()
+
+		for !p.isPunct("]") && p.tok.kind != kindEOF {
+			p.value(constant)
+		}
+
+		p.expectPunct("]")
+	case p.isPunct("{"):
+		p.next()
+
+		for !p.isPunct("}") && p.tok.kind != kindEOF {
+			p.expectName("a field name")
+			p.expectPunct(":")
+			p.value(constant)
+		}
+
+		p.expectPunct("}")
+	default:
+		p.expected("a value")
+	}
+}
+
+// next lexes the next token into p.tok, skipping whitespace, commas and comments.
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+
+	p.skipIgnored()
+
+	start := p.pos
+
+	if start >= len(p.src) {
+		p.tok = token{kind: kindEOF, offset: start}
+		return
+	}
+
+	char := p.src[start]
+
+	switch {
+	case strings.HasPrefix(p.src[start:], "..."):
+		p.pos += len("...")
+		p.tok = token{kind: kindPunct, value: "...", offset: start}
+	case strings.IndexByte("!$&():=@[]{|}", char) != -1:
+		p.pos++
+		p.tok = token{kind: kindPunct, value: string(char), offset: start}
+	case isNameStart(char):
+		for p.pos < len(p.src) && isNameContinue(p.src[p.pos]) {
+			p.pos++
+		}
+
+		p.tok = token{kind: kindName, value: p.src[start:p.pos], offset: start}
+	case char == '-' || isDigit(char):
+		p.number()
+	case char == '"':
+		p.stringValue()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[start:])
+		p.failAt(start, fmt.Sprintf("unexpected character %q", r))
+	}
+}
+
+// skipIgnored skips whitespace, commas and '#' comments, which are insignificant in GraphQL.
+func (p *parser) skipIgnored() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r', ',':
+			p.pos++
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			// A byte order mark is ignored too
+			if strings.HasPrefix(p.src[p.pos:], bom) {
+				p.pos += len(bom)
+				continue
+			}
+
+			return
+		}
+	}
+}
+
+// number lexes an int or float, p.pos is at it's first character.
+func (p *parser) number() {
+	start := p.pos
+	numberKind := kindInt
+
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+
+	digits := p.digits()
+
+	switch {
+	case digits == 0:
+		p.failAt(start, "expected a digit after '-'")
+		return
+	case digits > 1 && p.src[p.pos-digits] == '0':
+		p.failAt(start, "a number can't have a leading zero")
+		return
+	}
+
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		numberKind = kindFloat
+
+		if p.digits() == 0 {
+			p.failAt(start, "expected a digit after '.'")
+			return
+		}
+	}
+
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		numberKind = kindFloat
+
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+
+		if p.digits() == 0 {
+			p.failAt(start, "expected a digit in exponent")
+			return
+		}
+	}
+
+	// Something like '123abc' or '1.2.3'
+	if p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.failAt(start, fmt.Sprintf("invalid number, unexpected %q", p.src[p.pos]))
+		return
+	}
+
+	p.tok = token{kind: numberKind, value: p.src[start:p.pos], offset: start}
+}
+
+// digits consumes a run of digits, returning how many there were.
+func (p *parser) digits() int {
+	start := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+
+	return p.pos - start
+}
+
+// stringValue lexes a '"string"' or a '"""block string"""', p.pos is at the opening quote.
+func (p *parser) stringValue() {
+	start := p.pos
+
+	if strings.HasPrefix(p.src[start:], `"""`) {
+		p.pos += len(`"""`)
+
+		for {
+			end := strings.Index(p.src[p.pos:], `"""`)
+			if end == -1 {
+				p.failAt(start, "unterminated block string")
+				return
+			}
+
+			p.pos += end + len(`"""`)
+
+			// An escaped '\"""' doesn't close the block string
+			if p.src[p.pos-len(`"""`)-1] != '\\' {
+				break
+			}
+		}
+
+		p.tok = token{kind: kindString, value: p.src[start:p.pos], offset: start}
+
+		return
+	}
+
+	p.pos++ // The opening '"'
+
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.failAt(start, "unterminated string")
+			return
+		}
+
+		switch p.src[p.pos] {
+		case '"':
+			p.pos++
+			p.tok = token{kind: kindString, value: p.src[start:p.pos], offset: start}
+
+			return
+		case '\\':
+			p.pos += 2
+		default:
+			p.pos++
+		}
+	}
+}
+
+// isNameStart reports whether char may start a GraphQL name.
+func isNameStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+// isNameContinue reports whether char may appear in a GraphQL name after the first character.
+func isNameContinue(char byte) bool {
+	return isNameStart(char) || isDigit(char)
+}
+
+// isDigit reports whether char is an ASCII digit.
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
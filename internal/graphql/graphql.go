@@ -0,0 +1,122 @@
+// Package graphql implements just enough of GraphQL to send a query over HTTP, and to
+// check it's syntax before it's sent. It knows nothing of any schema, so a query that
+// parses may still be rejected by the server.
+//
+// The body of a GraphQL request in a .http file is the query, optionally followed by a
+// blank line and a JSON object of variables, as in the VSCode REST Client:
+//
+//	query User($id: ID!) {
+//	  user(id: $id) {
+//	    name
+//	  }
+//	}
+//
+//	{"id": "123"}
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Request is the standard JSON body of a GraphQL request over HTTP.
+type Request struct {
+	Query     string          `json:"query"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+}
+
+// Response is the standard JSON body of a GraphQL response.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []Error         `json:"errors,omitempty"`
+}
+
+// Error is a single error in a GraphQL [Response].
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+// String implements [fmt.Stringer] for an [Error] e.g. 'Cannot query field "nme" (line 3:5) at user.nme'.
+func (e Error) String() string {
+	builder := &strings.Builder{}
+	builder.WriteString(e.Message)
+
+	for _, location := range e.Locations {
+		fmt.Fprintf(builder, " (line %d:%d)", location.Line, location.Column)
+	}
+
+	if len(e.Path) > 0 {
+		path := make([]string, 0, len(e.Path))
+		for _, segment := range e.Path {
+			path = append(path, fmt.Sprint(segment))
+		}
+
+		builder.WriteString(" at " + strings.Join(path, "."))
+	}
+
+	return builder.String()
+}
+
+// Location is a position in a query that a GraphQL [Error] refers to.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Body builds the JSON body of a GraphQL request from the body of a request in a .http file.
+func Body(src string) ([]byte, error) {
+	query, variables := Split(src)
+
+	request := Request{Query: query}
+
+	if variables != "" {
+		buf := &bytes.Buffer{}
+		if err := json.Compact(buf, []byte(variables)); err != nil {
+			return nil, fmt.Errorf("variables are not valid JSON: %w", err)
+		}
+
+		request.Variables = buf.Bytes()
+	}
+
+	return json.Marshal(request)
+}
+
+// Split splits the body of a GraphQL request in a .http file into the query and the
+// optional JSON object of variables after it, which is "" if there isn't one.
+//
+// The variables are the first block after a blank line that starts with '{"' or is an
+// empty object, which can't be the start of a selection set.
+func Split(src string) (query, variables string) {
+	offset := 0
+
+	for line := range strings.Lines(src) {
+		offset += len(line)
+
+		if strings.TrimSpace(line) != "" {
+			continue
+		}
+
+		rest := strings.TrimSpace(src[offset:])
+		if isVariables(rest) {
+			return strings.TrimSpace(src[:offset]), rest
+		}
+	}
+
+	return strings.TrimSpace(src), ""
+}
+
+// isVariables reports whether src looks like a JSON object rather than a selection set.
+func isVariables(src string) bool {
+	rest, ok := strings.CutPrefix(src, "{")
+	if !ok {
+		return false
+	}
+
+	rest = strings.TrimSpace(rest)
+
+	return strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "}")
+}
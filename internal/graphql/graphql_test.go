@@ -0,0 +1,205 @@
+package graphql_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/graphql"
+	"go.followtheprocess.codes/test"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name          string // Name of the test case
+		src           string // The body of the request
+		wantQuery     string // Expected query
+		wantVariables string // Expected variables
+	}{
+		{
+			name:      "shorthand",
+			src:       "{ viewer { login } }",
+			wantQuery: "{ viewer { login } }",
+		},
+		{
+			name:          "variables",
+			src:           "query User($id: ID!) {\n  user(id: $id) { name }\n}\n\n{\n  \"id\": \"123\"\n}",
+			wantQuery:     "query User($id: ID!) {\n  user(id: $id) { name }\n}",
+			wantVariables: "{\n  \"id\": \"123\"\n}",
+		},
+		{
+			name:          "empty variables",
+			src:           "query { me { name } }\n\n{}",
+			wantQuery:     "query { me { name } }",
+			wantVariables: "{}",
+		},
+		{
+			name:      "blank lines in query",
+			src:       "query {\n\n  me {\n\n    name\n  }\n}",
+			wantQuery: "query {\n\n  me {\n\n    name\n  }\n}",
+		},
+		{
+			name:          "blank lines in variables",
+			src:           "query { me { name } }\n\n{\n  \"a\": 1,\n\n  \"b\": 2\n}",
+			wantQuery:     "query { me { name } }",
+			wantVariables: "{\n  \"a\": 1,\n\n  \"b\": 2\n}",
+		},
+		{
+			name:      "second operation not variables",
+			src:       "query A { a }\n\n{ b }",
+			wantQuery: "query A { a }\n\n{ b }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, variables := graphql.Split(tt.src)
+			test.Equal(t, query, tt.wantQuery)
+			test.Equal(t, variables, tt.wantVariables)
+		})
+	}
+}
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		name    string // Name of the test case
+		src     string // The body of the request
+		want    string // Expected JSON body
+		wantErr bool   // Whether we want an error
+	}{
+		{
+			name: "no variables",
+			src:  "{ viewer { login } }",
+			want: `{"query":"{ viewer { login } }"}`,
+		},
+		{
+			name: "variables",
+			src:  "query User($id: ID!) {\n  user(id: $id) { name }\n}\n\n{\n  \"id\": \"123\"\n}",
+			want: `{"query":"query User($id: ID!) {\n  user(id: $id) { name }\n}","variables":{"id":"123"}}`,
+		},
+		{
+			name:    "bad variables",
+			src:     "query { me { name } }\n\n{\"id\": }",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := graphql.Body(tt.src)
+			test.WantErr(t, err, tt.wantErr)
+			test.Equal(t, string(got), tt.want)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string // Name of the test case
+		query string // The query to check
+		want  string // The expected error, "" if it's valid
+	}{
+		{
+			name:  "shorthand",
+			query: "{ viewer { login } }",
+		},
+		{
+			name: "everything",
+			query: `# A comment
+query User($id: ID!, $first: Int = 10, $tags: [String!]! = ["a", "b"]) @cached(ttl: 60) {
+  user(id: $id) {
+    smallPic: profilePic(size: 64)
+    friends(first: $first, filter: {active: true, score: -1.5e3, name: null, role: ADMIN}) {
+      ...friendFields @include(if: true)
+      ... on Admin { level }
+      ... @skip(if: false) { id }
+    }
+    bio(format: """
+      A "block" string \"""
+    """)
+  }
+}
+
+mutation { like(id: "1\"2") { count } }
+
+fragment friendFields on User {
+  id, name
+}`,
+		},
+		{
+			name:  "empty",
+			query: "  # Nothing here\n",
+			want:  "2:1: empty query, expected a query, mutation, subscription or fragment",
+		},
+		{
+			name:  "unclosed selection set",
+			query: "query {\n  user {\n    name\n  }\n",
+			want:  "5:1: expected '}', got end of query",
+		},
+		{
+			name:  "empty selection set",
+			query: "query { user {} }",
+			want:  "1:15: a selection set must select at least one field",
+		},
+		{
+			name:  "not an operation",
+			query: "get { user }",
+			want:  `1:1: expected a query, mutation, subscription or fragment, got name "get"`,
+		},
+		{
+			name:  "missing argument value",
+			query: "{ user(id: ) { name } }",
+			want:  "1:12: expected a value, got ')'",
+		},
+		{
+			name:  "variable in default",
+			query: "query ($a: Int = $b) { user }",
+			want:  "1:18: variables aren't allowed in a constant value",
+		},
+		{
+			name:  "unterminated string",
+			query: "{ user(id: \"123) { name } }",
+			want:  "1:12: unterminated string",
+		},
+		{
+			name:  "bad character",
+			query: "{ user; }",
+			want:  "1:7: unexpected character ';'",
+		},
+		{
+			name:  "leading zero",
+			query: "{ user(id: 012) { name } }",
+			want:  "1:12: a number can't have a leading zero",
+		},
+		{
+			name:  "fragment missing on",
+			query: "fragment F User { id }",
+			want:  `1:12: expected 'on', got name "User"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := graphql.Check(tt.query)
+			if tt.want == "" {
+				test.Ok(t, err)
+				return
+			}
+
+			test.Err(t, err)
+			test.Equal(t, err.Error(), tt.want)
+
+			var syntaxErr *graphql.SyntaxError
+			test.True(t, errors.As(err, &syntaxErr), test.Context("error %T is not a *graphql.SyntaxError", err))
+		})
+	}
+}
+
+func TestErrorString(t *testing.T) {
+	err := graphql.Error{
+		Message:   `Cannot query field "nme" on type "User"`,
+		Locations: []graphql.Location{{Line: 3, Column: 5}},
+		Path:      []any{"user", float64(0), "nme"},
+	}
+
+	test.Equal(t, err.String(), `Cannot query field "nme" on type "User" (line 3:5) at user.0.nme`)
+}
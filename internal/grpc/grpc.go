@@ -0,0 +1,368 @@
+// Package grpc sends unary gRPC requests from a .http file, as in JetBrains IDEs:
+//
+//	GRPC localhost:50051/grpc.health.v1.Health/Check
+//
+//	{"service": "api"}
+//
+// There's no generated code for the service, so the request and response messages are
+// described at runtime, either by the server with server reflection or by compiling
+// the service's .proto file. The JSON body is transcoded to protobuf using the request
+// message's descriptor, and the response back to JSON in the same way.
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Server reflection methods, v1alpha is tried if the server doesn't have v1. The messages
+// are identical on the wire, so the v1 types are used for both.
+const (
+	reflectionV1      = reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName
+	reflectionV1Alpha = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+)
+
+// Target is the parsed URL of a gRPC request e.g. 'localhost:50051/grpc.health.v1.Health/Check'.
+type Target struct {
+	Address string // The host:port of the server
+	Service string // The fully qualified service name e.g. grpc.health.v1.Health
+	Method  string // The method name e.g. Check
+	TLS     bool   // Whether to connect with TLS, set by a grpcs:// or https:// scheme
+}
+
+// ParseTarget parses the URL of a gRPC request.
+//
+// It's the server address followed by the service and method, with an optional scheme of
+// grpc:// or http:// for a plaintext connection (the default) or grpcs:// or https:// for TLS.
+func ParseTarget(raw string) (Target, error) {
+	var target Target
+
+	rest := raw
+	if scheme, after, ok := strings.Cut(raw, "://"); ok {
+		switch scheme {
+		case "grpc", "http":
+		case "grpcs", "https":
+			target.TLS = true
+		default:
+			return Target{}, fmt.Errorf("gRPC URL scheme must be grpc, grpcs, http or https, got %q", scheme)
+		}
+
+		rest = after
+	}
+
+	address, path, _ := strings.Cut(rest, "/")
+
+	service, method, ok := strings.Cut(path, "/")
+	if address == "" || !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return Target{}, fmt.Errorf("gRPC URL must be host:port/package.Service/Method, got %q", raw)
+	}
+
+	target.Address = address
+	target.Service = service
+	target.Method = method
+
+	return target, nil
+}
+
+// FullMethod returns the full method name as sent to the server e.g. '/grpc.health.v1.Health/Check'.
+func (t Target) FullMethod() string {
+	return "/" + t.Service + "/" + t.Method
+}
+
+// Request is a unary gRPC request.
+type Request struct {
+	// Metadata sent with the request, the equivalent of HTTP headers
+	Metadata metadata.MD
+
+	// The path to the service's .proto file, "" to ask the server with reflection
+	Proto string
+
+	// The request message as JSON, empty for an empty message
+	Body []byte
+
+	// Where to send it
+	Target Target
+
+	// How long to wait for a connection to the server
+	ConnectionTimeout time.Duration
+}
+
+// Response is the response to a unary gRPC request.
+type Response struct {
+	Header  metadata.MD    // Metadata sent by the server before the response
+	Trailer metadata.MD    // Metadata sent by the server after the response
+	Status  *status.Status // The status of the call
+	Body    []byte         // The response message as indented JSON, nil if the call failed
+}
+
+// descriptors finds descriptors by their full name, as both a compiled .proto file and
+// the files from server reflection can.
+type descriptors interface {
+	FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error)
+}
+
+// Invoke sends a unary gRPC request.
+//
+// The call failing on the server is not an error, it's status is in the [Response]. An
+// error is returned if the method can't be described, the body doesn't match it's
+// request message, or the call couldn't be made at all.
+func Invoke(ctx context.Context, request Request) (Response, error) {
+	creds := insecure.NewCredentials()
+	if request.Target.TLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	conn, err := grpc.NewClient(
+		request.Target.Address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: request.ConnectionTimeout,
+		}),
+	)
+	if err != nil {
+		return Response{}, fmt.Errorf("gRPC: %w", err)
+	}
+	defer conn.Close()
+
+	var files descriptors
+	if request.Proto != "" {
+		files, err = compile(ctx, request.Proto)
+	} else {
+		files, err = reflect(ctx, conn, request.Target.Service)
+	}
+
+	if err != nil {
+		return Response{}, err
+	}
+
+	method, err := findMethod(files, request.Target)
+	if err != nil {
+		return Response{}, err
+	}
+
+	input := dynamicpb.NewMessage(method.Input())
+	if len(bytes.TrimSpace(request.Body)) > 0 {
+		if err = protojson.Unmarshal(request.Body, input); err != nil {
+			return Response{}, fmt.Errorf("body is not a valid %s: %w", method.Input().FullName(), err)
+		}
+	}
+
+	var response Response
+
+	output := dynamicpb.NewMessage(method.Output())
+	ctx = metadata.NewOutgoingContext(ctx, request.Metadata)
+
+	err = conn.Invoke(ctx, request.Target.FullMethod(), input, output, grpc.Header(&response.Header), grpc.Trailer(&response.Trailer))
+
+	response.Status = status.Convert(err)
+	if err != nil {
+		return response, nil
+	}
+
+	response.Body, err = marshal(output)
+	if err != nil {
+		return Response{}, fmt.Errorf("could not convert %s to JSON: %w", method.Output().FullName(), err)
+	}
+
+	return response, nil
+}
+
+// findMethod finds the descriptor of the target's method.
+func findMethod(files descriptors, target Target) (protoreflect.MethodDescriptor, error) {
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(target.Service))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found", target.Service)
+	}
+
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", target.Service)
+	}
+
+	method := service.Methods().ByName(protoreflect.Name(target.Method))
+	if method == nil {
+		return nil, fmt.Errorf("service %s has no method %s", target.Service, target.Method)
+	}
+
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("%s is a streaming method, only unary methods are supported", target.FullMethod())
+	}
+
+	return method, nil
+}
+
+// marshal converts a message to indented JSON.
+//
+// protojson deliberately randomises it's whitespace, so it's compacted and indented
+// again to get the same output every time.
+func marshal(message proto.Message) ([]byte, error) {
+	raw, err := protojson.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, raw); err != nil {
+		return nil, err
+	}
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
+
+// compile compiles a .proto file, any files it imports are looked up relative to it's
+// directory, or are one of the well known types.
+func compile(ctx context.Context, path string) (descriptors, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{filepath.Dir(path)},
+		}),
+	}
+
+	files, err := compiler.Compile(ctx, filepath.Base(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not compile %s: %w", path, err)
+	}
+
+	return files.AsResolver(), nil
+}
+
+// reflect asks the server for the files describing a service, with server reflection.
+func reflect(ctx context.Context, conn *grpc.ClientConn, service string) (descriptors, error) {
+	// Cancelling ends the reflection stream
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files, err := reflectWith(ctx, conn, reflectionV1, service)
+	if status.Code(err) == codes.Unimplemented {
+		files, err = reflectWith(ctx, conn, reflectionV1Alpha, service)
+	}
+
+	if status.Code(err) == codes.Unimplemented {
+		return nil, errors.New("the server does not support reflection, declare the service's .proto file with '# @proto'")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %w", err)
+	}
+
+	return files, nil
+}
+
+// reflectWith asks the server for the files describing a service using the given
+// reflection method. Any files they import that the server doesn't send with them are
+// asked for in turn, unless they're one of the well known types.
+func reflectWith(ctx context.Context, conn *grpc.ClientConn, method, service string) (*protoregistry.Files, error) {
+	stream, err := conn.NewStream(ctx, &reflectionpb.ServerReflection_ServiceDesc.Streams[0], method)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend() //nolint:errcheck // Nothing to be done, the stream is cancelled anyway
+
+	queue, err := fileRequest(stream, &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+
+		if _, seen := files[file.GetName()]; seen {
+			continue
+		}
+
+		files[file.GetName()] = file
+
+		for _, dependency := range file.GetDependency() {
+			if _, seen := files[dependency]; seen {
+				continue
+			}
+
+			if known, err := protoregistry.GlobalFiles.FindFileByPath(dependency); err == nil {
+				queue = append(queue, protodesc.ToFileDescriptorProto(known))
+				continue
+			}
+
+			more, err := fileRequest(stream, &reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dependency},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			queue = append(queue, more...)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: slices.Collect(maps.Values(files)),
+	}
+
+	return protodesc.NewFiles(set)
+}
+
+// fileRequest sends a single request for files on a reflection stream and returns them.
+func fileRequest(stream grpc.ClientStream, request *reflectionpb.ServerReflectionRequest) ([]*descriptorpb.FileDescriptorProto, error) {
+	if err := stream.SendMsg(request); err != nil {
+		return nil, err
+	}
+
+	response := &reflectionpb.ServerReflectionResponse{}
+	if err := stream.RecvMsg(response); err != nil {
+		return nil, err
+	}
+
+	if failed := response.GetErrorResponse(); failed != nil {
+		return nil, status.Error(codes.Code(failed.GetErrorCode()), failed.GetErrorMessage()) //nolint:gosec // Codes are small
+	}
+
+	raw := response.GetFileDescriptorResponse().GetFileDescriptorProto()
+	files := make([]*descriptorpb.FileDescriptorProto, 0, len(raw))
+
+	for _, data := range raw {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, file); err != nil {
+			return nil, fmt.Errorf("invalid file descriptor from server: %w", err)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
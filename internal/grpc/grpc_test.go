@@ -0,0 +1,259 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/grpc"
+	"go.followtheprocess.codes/test"
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name    string      // Name of the test case
+		raw     string      // The URL to parse
+		errMsg  string      // If we wanted an error, what should it say
+		want    grpc.Target // Expected target
+		wantErr bool        // Whether we wanted an error
+	}{
+		{
+			name: "no scheme",
+			raw:  "localhost:50051/grpc.health.v1.Health/Check",
+			want: grpc.Target{Address: "localhost:50051", Service: "grpc.health.v1.Health", Method: "Check"},
+		},
+		{
+			name: "grpc",
+			raw:  "grpc://localhost:50051/grpc.health.v1.Health/Check",
+			want: grpc.Target{Address: "localhost:50051", Service: "grpc.health.v1.Health", Method: "Check"},
+		},
+		{
+			name: "grpcs",
+			raw:  "grpcs://api.com/grpc.health.v1.Health/Check",
+			want: grpc.Target{Address: "api.com", Service: "grpc.health.v1.Health", Method: "Check", TLS: true},
+		},
+		{
+			name: "https",
+			raw:  "https://api.com:443/grpc.health.v1.Health/Check",
+			want: grpc.Target{Address: "api.com:443", Service: "grpc.health.v1.Health", Method: "Check", TLS: true},
+		},
+		{
+			name:    "bad scheme",
+			raw:     "ws://localhost:50051/grpc.health.v1.Health/Check",
+			wantErr: true,
+			errMsg:  `gRPC URL scheme must be grpc, grpcs, http or https, got "ws"`,
+		},
+		{
+			name:    "no method",
+			raw:     "localhost:50051/grpc.health.v1.Health",
+			wantErr: true,
+			errMsg:  `gRPC URL must be host:port/package.Service/Method, got "localhost:50051/grpc.health.v1.Health"`,
+		},
+		{
+			name:    "no service",
+			raw:     "localhost:50051",
+			wantErr: true,
+			errMsg:  `gRPC URL must be host:port/package.Service/Method, got "localhost:50051"`,
+		},
+		{
+			name:    "too many parts",
+			raw:     "localhost:50051/grpc.health.v1.Health/Check/More",
+			wantErr: true,
+			errMsg:  `gRPC URL must be host:port/package.Service/Method, got "localhost:50051/grpc.health.v1.Health/Check/More"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := grpc.ParseTarget(tt.raw)
+			test.WantErr(t, err, tt.wantErr)
+
+			if err != nil {
+				test.Equal(t, err.Error(), tt.errMsg)
+				return
+			}
+
+			test.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	reflecting := serve(t, true)
+	plain := serve(t, false)
+
+	proto := filepath.Join("testdata", "health.proto")
+
+	tests := []struct {
+		name    string     // Name of the test case
+		address string     // The server to send it to
+		method  string     // The method to call on the health service
+		proto   string     // The .proto file, "" for reflection
+		body    string     // The JSON body
+		errMsg  string     // If we wanted an error, what should it say
+		want    string     // The expected JSON response
+		code    codes.Code // The expected status
+		wantErr bool       // Whether we wanted an error
+	}{
+		{
+			name:    "reflection",
+			address: reflecting,
+			method:  "Check",
+			body:    `{"service": "api"}`,
+			code:    codes.OK,
+			want:    "{\n  \"status\": \"SERVING\"\n}",
+		},
+		{
+			name:    "empty body",
+			address: reflecting,
+			method:  "Check",
+			code:    codes.OK,
+			want:    "{\n  \"status\": \"SERVING\"\n}",
+		},
+		{
+			name:    "proto file",
+			address: plain,
+			method:  "Check",
+			proto:   proto,
+			body:    `{"service": "api"}`,
+			code:    codes.OK,
+			want:    "{\n  \"status\": \"SERVING\"\n}",
+		},
+		{
+			name:    "error status",
+			address: reflecting,
+			method:  "Check",
+			body:    `{"service": "missing"}`,
+			code:    codes.NotFound,
+		},
+		{
+			name:    "no reflection",
+			address: plain,
+			method:  "Check",
+			wantErr: true,
+			errMsg:  "the server does not support reflection, declare the service's .proto file with '# @proto'",
+		},
+		{
+			name:    "missing proto file",
+			address: plain,
+			method:  "Check",
+			proto:   filepath.Join("testdata", "missing.proto"),
+			wantErr: true,
+		},
+		{
+			name:    "unknown method",
+			address: reflecting,
+			method:  "Nope",
+			wantErr: true,
+			errMsg:  "service grpc.health.v1.Health has no method Nope",
+		},
+		{
+			name:    "streaming",
+			address: reflecting,
+			method:  "Watch",
+			wantErr: true,
+			errMsg:  "/grpc.health.v1.Health/Watch is a streaming method, only unary methods are supported",
+		},
+		{
+			name:    "bad body",
+			address: reflecting,
+			method:  "Check",
+			body:    `{"nope": "api"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			request := grpc.Request{
+				Target: grpc.Target{
+					Address: tt.address,
+					Service: healthpb.Health_ServiceDesc.ServiceName,
+					Method:  tt.method,
+				},
+				Metadata:          metadata.Pairs("x-request-id", "123"),
+				Proto:             tt.proto,
+				Body:              []byte(tt.body),
+				ConnectionTimeout: time.Second,
+			}
+
+			response, err := grpc.Invoke(ctx, request)
+			test.WantErr(t, err, tt.wantErr)
+
+			if err != nil {
+				if tt.errMsg != "" {
+					test.Equal(t, err.Error(), tt.errMsg)
+				}
+
+				return
+			}
+
+			test.Equal(t, response.Status.Code(), tt.code)
+			test.Equal(t, string(response.Body), tt.want)
+
+			// The interceptor echoes the metadata back in both
+			test.EqualFunc(t, response.Header.Get("x-request-id"), []string{"123"}, slices.Equal)
+			test.EqualFunc(t, response.Trailer.Get("x-request-id"), []string{"123"}, slices.Equal)
+		})
+	}
+}
+
+// serve starts a gRPC server with the health service, and optionally server reflection,
+// returning it's address.
+//
+// Every call echoes it's x-request-id metadata back in both the header and trailer.
+func serve(t *testing.T, reflect bool) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	test.Ok(t, err)
+
+	server := grpcgo.NewServer(grpcgo.UnaryInterceptor(echoMetadata))
+
+	checker := health.NewServer()
+	checker.SetServingStatus("api", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, checker)
+
+	if reflect {
+		reflection.Register(server)
+	}
+
+	go server.Serve(listener) //nolint:errcheck // Returns when the server is stopped
+
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// echoMetadata is a [grpcgo.UnaryServerInterceptor] that echoes x-request-id back.
+func echoMetadata(
+	ctx context.Context,
+	req any,
+	_ *grpcgo.UnaryServerInfo,
+	handler grpcgo.UnaryHandler,
+) (any, error) {
+	incoming, _ := metadata.FromIncomingContext(ctx)
+	echo := metadata.MD{"x-request-id": incoming.Get("x-request-id")}
+
+	if err := grpcgo.SetHeader(ctx, echo); err != nil {
+		return nil, err
+	}
+
+	if err := grpcgo.SetTrailer(ctx, echo); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
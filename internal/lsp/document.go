@@ -0,0 +1,348 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go.followtheprocess.codes/req/internal/spec"
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/ast"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+	"go.followtheprocess.codes/req/internal/syntax/parser"
+	"go.followtheprocess.codes/req/internal/syntax/token"
+)
+
+// source is some text and the offset of the start of each line, for converting
+// between byte offsets and LSP positions.
+type source struct {
+	text  string // The text
+	lines []int  // Byte offset of the start of each line in text
+}
+
+// newSource returns a new [source] for text.
+func newSource(text string) source {
+	lines := []int{0}
+
+	for index := range len(text) {
+		if text[index] == '\n' {
+			lines = append(lines, index+1)
+		}
+	}
+
+	return source{text: text, lines: lines}
+}
+
+// position returns the LSP position of the byte offset.
+func (s source) position(offset int) Position {
+	offset = min(max(offset, 0), len(s.text))
+
+	// The index of the last line starting at or before offset
+	line, found := slices.BinarySearch(s.lines, offset)
+	if !found {
+		line--
+	}
+
+	return Position{Line: line, Character: utf16Len(s.text[s.lines[line]:offset])}
+}
+
+// offset returns the byte offset of the LSP position, positions past the end of a
+// line are clamped to the end of it.
+func (s source) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+
+	if pos.Line >= len(s.lines) {
+		return len(s.text)
+	}
+
+	offset := s.lines[pos.Line]
+	units := 0
+
+	for offset < len(s.text) && s.text[offset] != '\n' && units < pos.Character {
+		char, width := utf8.DecodeRuneInString(s.text[offset:])
+		units += utf16.RuneLen(char)
+		offset += width
+	}
+
+	return offset
+}
+
+// between returns the LSP range between two byte offsets.
+func (s source) between(start, end int) Range {
+	return Range{Start: s.position(start), End: s.position(end)}
+}
+
+// span returns the LSP range of a syntax tree node.
+func (s source) span(span ast.Span) Range {
+	return s.between(span.Start.Offset, span.End.Offset)
+}
+
+// errorRange returns the LSP range of a syntax error position, which uses
+// 1 indexed lines and byte columns.
+func (s source) errorRange(pos syntax.Position) Range {
+	line := min(max(pos.Line-1, 0), len(s.lines)-1)
+	start := min(s.lines[line]+pos.StartCol-1, len(s.text))
+	end := min(s.lines[line]+pos.EndCol-1, len(s.text))
+
+	return s.between(start, end)
+}
+
+// document is a .http file open in the editor, along with what's known about it.
+type document struct {
+	file        *ast.File    // The syntax tree, possibly partial if there are errors
+	uri         string       // The document URI
+	path        string       // Path to the file on disk
+	names       []string     // The name of each request in file.Requests
+	diagnostics []Diagnostic // Syntax errors and bad references to other requests
+	source
+}
+
+// newDocument parses the text of the document at uri.
+func newDocument(uri, text string) (*document, error) {
+	doc := &document{
+		uri:    uri,
+		path:   uriPath(uri),
+		source: newSource(text),
+	}
+
+	// The parser reports errors as they're found but they're also returned, in order
+	p, err := parser.New(doc.path, strings.NewReader(text), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.ParseFile()
+	doc.file = file
+
+	var errs syntax.ErrorList
+	errors.As(err, &errs)
+
+	lowered := file.Lower()
+	for _, request := range lowered.Requests {
+		doc.names = append(doc.names, request.Name)
+	}
+
+	// References to other requests and GraphQL queries can only be checked once the file
+	// is valid, any errors are added to the list by the handler
+	if len(errs) == 0 {
+		_ = spec.CheckReferences(lowered, errs.Add)
+		_ = spec.CheckGraphQL(lowered, errs.Add)
+	}
+
+	for _, err := range errs {
+		doc.diagnostics = append(doc.diagnostics, Diagnostic{
+			Range:    doc.errorRange(err.Pos),
+			Severity: severityError,
+			Source:   "req",
+			Message:  err.Msg,
+		})
+	}
+
+	return doc, nil
+}
+
+// request returns the index of the request at offset, or -1 if it's before the
+// first request.
+func (d *document) request(offset int) int {
+	index := -1
+
+	for i, request := range d.file.Requests {
+		if request.Separator.Start.Offset > offset {
+			break
+		}
+
+		index = i
+	}
+
+	return index
+}
+
+// tag returns the '{{...}}' tag containing offset, and it's start and end offsets.
+func (d *document) tag(offset int) (tag interp.Tag, start, end int, ok bool) {
+	for _, tag := range interp.Tags(d.text, syntax.Position{Name: d.path, Line: 1, StartCol: 1, EndCol: 1}) {
+		start := tag.Pos.Offset
+		end := start + tag.Pos.EndCol - tag.Pos.StartCol
+
+		if offset >= start && offset < end {
+			return tag, start, end, true
+		}
+	}
+
+	return interp.Tag{}, 0, 0, false
+}
+
+// completing reports whether offset is inside an unterminated '{{' on the same line, i.e.
+// the user is typing a variable name.
+func (d *document) completing(offset int) bool {
+	line := d.text[d.lines[d.position(offset).Line]:offset]
+
+	open := strings.LastIndex(line, "{{")
+
+	return open != -1 && !strings.Contains(line[open:], "}}")
+}
+
+// requestName returns the node naming a request, either it's '@name' variable or
+// if it has none, it's separator.
+func requestName(request *ast.Request) ast.Node {
+	for _, statement := range request.Statements {
+		if v, ok := statement.(*ast.Var); ok && v.Keyword == token.Name {
+			return v
+		}
+	}
+
+	return request.Separator
+}
+
+// declaration is a variable declared in the document or an environment file.
+type declaration struct {
+	name   string // The variable name
+	value  string // It's value as declared
+	detail string // Where it comes from e.g. "global variable"
+	uri    string // The document it's declared in
+	rng    Range  // The range of the declaration
+	prompt bool   // Whether it's a prompt, so has no value until the request is sent
+}
+
+// declarations returns the variables declared in the document that are visible at
+// offset, in order of precedence.
+func (d *document) declarations(offset int) []declaration {
+	return append(d.locals(offset), d.globals()...)
+}
+
+// locals returns the variables declared by the request at offset.
+func (d *document) locals(offset int) []declaration {
+	index := d.request(offset)
+	if index == -1 {
+		return nil
+	}
+
+	return d.statements(d.file.Requests[index].Statements, "request variable")
+}
+
+// globals returns the variables declared at the top of the document.
+func (d *document) globals() []declaration {
+	return d.statements(d.file.Statements, "global variable")
+}
+
+// statements returns the variables and prompts declared by statements.
+func (d *document) statements(statements []ast.Statement, detail string) []declaration {
+	var decls []declaration
+
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *ast.Var:
+			if statement.Keyword != token.Ident {
+				continue
+			}
+
+			decl := declaration{
+				name:   statement.Name.Value,
+				detail: detail,
+				uri:    d.uri,
+				rng:    d.span(statement.Span),
+			}
+
+			if statement.Value != nil {
+				decl.value = statement.Value.Value
+			}
+
+			decls = append(decls, decl)
+		case *ast.Prompt:
+			decl := declaration{
+				name:   statement.Name.Value,
+				detail: "prompt",
+				uri:    d.uri,
+				rng:    d.span(statement.Span),
+				prompt: true,
+			}
+
+			if statement.Description != nil {
+				decl.detail = "prompt: " + statement.Description.Value
+			}
+
+			decls = append(decls, decl)
+		}
+	}
+
+	return decls
+}
+
+// variable returns the name of the variable referenced by the expression in a '{{...}}'
+// tag, or "" if it doesn't reference one e.g. a dynamic variable or a response.
+func variable(expr string) string {
+	if rest, ok := strings.CutPrefix(expr, "."); ok {
+		_, name, _ := strings.Cut(rest, ".")
+		return name
+	}
+
+	if strings.HasPrefix(expr, "$") {
+		return ""
+	}
+
+	if _, ok, _ := interp.ParseReference(expr); ok {
+		return ""
+	}
+
+	return expr
+}
+
+// locate returns the range of the key name in the JSON source of an environment file,
+// or the start of the file if it can't be found.
+func locate(src source, name string) Range {
+	pattern := regexp.MustCompile(`"` + regexp.QuoteMeta(name) + `"\s*:`)
+
+	match := pattern.FindStringIndex(src.text)
+	if match == nil {
+		return Range{}
+	}
+
+	return src.between(match[0], match[0]+len(name)+2)
+}
+
+// uriPath returns the filesystem path of a 'file://' URI. Any other URI is returned
+// as is.
+func uriPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+
+	return filepath.FromSlash(parsed.Path)
+}
+
+// fileURI returns the 'file://' URI of a filesystem path.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// utf16Len returns the length of s in UTF-16 code units, as LSP positions are counted.
+func utf16Len(s string) int {
+	length := 0
+	for _, char := range s {
+		length += utf16.RuneLen(char)
+	}
+
+	return length
+}
+
+// markdown returns a code span containing s, as inline code in markdown.
+func markdown(s string) string {
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s%s%s", fence, s, fence)
+}
@@ -0,0 +1,734 @@
+// Package lsp implements a language server for .http files, as used by `req lsp`.
+//
+// The server speaks the [Language Server Protocol] over a reader and writer (typically
+// stdin and stdout) so that editors without a native REST client e.g. Neovim and Helix
+// get:
+//
+//   - Diagnostics for syntax errors and references to requests that don't exist
+//   - Completion of variable names inside '{{', from '@' declarations, environment files,
+//     dynamic variables and the names of other requests
+//   - Hover over a '{{variable}}' to see it's value and where it's declared
+//   - Go to definition of a '{{variable}}' or a reference to another request's response
+//   - Document symbols for each '###' request
+//   - A code action to send the request under the cursor, through the 'req.send' command
+//
+// Documents are synced in full on every change, .http files are small enough that
+// re-parsing them each time is cheap.
+//
+// [Language Server Protocol]: https://microsoft.github.io/language-server-protocol/
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.followtheprocess.codes/log"
+	"go.followtheprocess.codes/req/internal/env"
+	"go.followtheprocess.codes/req/internal/spec"
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+)
+
+// SendCommand is the command that sends a request, it's arguments are the document URI
+// and the name of the request.
+const SendCommand = "req.send"
+
+// An Executor sends the named request from the .http file at path, returning the
+// response as text to show the user.
+//
+// The src is the current contents of the file in the editor, which may not have been
+// saved yet.
+type Executor func(ctx context.Context, path string, src []byte, name string) (string, error)
+
+// Option is a functional option for configuring a [Server].
+type Option func(*Server)
+
+// WithEnv sets the name of the environment whose variables are used for hover and
+// when sending requests. Without one, completion and hover show the variables from
+// every environment.
+func WithEnv(name string) Option {
+	return func(s *Server) {
+		s.env = name
+	}
+}
+
+// WithExecutor sets the [Executor] used by the 'req.send' command, without one sending
+// requests isn't offered.
+func WithExecutor(execute Executor) Option {
+	return func(s *Server) {
+		s.execute = execute
+	}
+}
+
+// WithLogger sets the logger, by default nothing is logged.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// Server is the .http language server.
+type Server struct {
+	in        *bufio.Reader        // Incoming messages
+	out       io.Writer            // Outgoing messages, guarded by mu
+	logger    *log.Logger          // The logger
+	execute   Executor             // Sends requests for the 'req.send' command, may be nil
+	documents map[string]*document // Open documents by URI
+	env       string               // The selected environment, may be empty
+	wg        sync.WaitGroup       // Tracks requests being sent in the background
+	mu        sync.Mutex           // Guards writes to out
+	shutdown  bool                 // Whether the client has asked the server to shut down
+}
+
+// New returns a new [Server] that reads messages from in and writes them to out.
+func New(in io.Reader, out io.Writer, options ...Option) *Server {
+	server := &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		logger:    log.New(io.Discard),
+		documents: make(map[string]*document),
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	return server
+}
+
+// Serve handles messages until the client sends 'exit' or in is closed. Requests being
+// sent in the background are cancelled and waited for before it returns.
+func (s *Server) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer s.wg.Wait()
+	defer cancel()
+
+	for {
+		msg, err := read(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			var rpcErr *responseError
+			if errors.As(err, &rpcErr) {
+				// The message was framed fine so carry on, we just can't respond to it
+				s.logger.Warn("Bad message", "error", err)
+				continue
+			}
+
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("client exited without asking the server to shut down")
+			}
+
+			return nil
+		}
+
+		s.logger.Debug("Received", "method", msg.Method)
+
+		if err := s.handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles a single message, responding to it if it's a request.
+func (s *Server) handle(ctx context.Context, msg message) error {
+	var (
+		result any
+		err    error
+	)
+
+	switch msg.Method {
+	case "initialize":
+		result = s.initialize()
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave":
+		// Nothing to do
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		err = s.didOpen(msg.Params)
+	case "textDocument/didChange":
+		err = s.didChange(msg.Params)
+	case "textDocument/didClose":
+		err = s.didClose(msg.Params)
+	case "textDocument/completion":
+		result, err = s.completion(msg.Params)
+	case "textDocument/hover":
+		result, err = s.hover(msg.Params)
+	case "textDocument/definition":
+		result, err = s.definition(msg.Params)
+	case "textDocument/documentSymbol":
+		result, err = s.documentSymbol(msg.Params)
+	case "textDocument/codeAction":
+		result, err = s.codeAction(msg.Params)
+	case "workspace/executeCommand":
+		if !msg.isNotification() {
+			// Sending a request may take a while, so respond once it's done
+			return s.executeCommand(ctx, msg.ID, msg.Params)
+		}
+	default:
+		if msg.isNotification() {
+			return nil
+		}
+
+		err = &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+
+	if msg.isNotification() {
+		if err != nil {
+			s.logger.Warn("Could not handle notification", "method", msg.Method, "error", err)
+		}
+
+		return nil
+	}
+
+	return s.respond(msg.ID, result, err)
+}
+
+// respond sends the response to the request with the given id.
+func (s *Server) respond(id *json.RawMessage, result any, err error) error {
+	if err != nil {
+		var rpcErr *responseError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+
+		return s.send(errorResponse{JSONRPC: "2.0", ID: id, Error: rpcErr})
+	}
+
+	return s.send(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+// notify sends a notification to the client.
+func (s *Server) notify(method string, params any) error {
+	return s.send(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// send writes a message to the client.
+func (s *Server) send(msg any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return write(s.out, msg)
+}
+
+// initialize returns the result of the initialize request, declaring what the
+// server can do.
+func (s *Server) initialize() any {
+	capabilities := map[string]any{
+		"textDocumentSync":       syncFull,
+		"completionProvider":     map[string]any{"triggerCharacters": []string{"{", "$"}},
+		"hoverProvider":          true,
+		"definitionProvider":     true,
+		"documentSymbolProvider": true,
+	}
+
+	if s.execute != nil {
+		capabilities["codeActionProvider"] = true
+		capabilities["executeCommandProvider"] = map[string]any{"commands": []string{SendCommand}}
+	}
+
+	return map[string]any{
+		"capabilities": capabilities,
+		"serverInfo":   map[string]string{"name": "req"},
+	}
+}
+
+// didOpen parses a newly opened document and publishes it's diagnostics.
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p didOpenParams
+	if err := unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	return s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+// didChange re-parses a changed document and publishes it's diagnostics.
+func (s *Server) didChange(params json.RawMessage) error {
+	var p didChangeParams
+	if err := unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+
+	// With full sync, the last change is the whole document
+	return s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+// didClose forgets a closed document and clears it's diagnostics.
+func (s *Server) didClose(params json.RawMessage) error {
+	var p didCloseParams
+	if err := unmarshal(params, &p); err != nil {
+		return err
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update parses the new text of a document and publishes it's diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc, err := newDocument(uri, text)
+	if err != nil {
+		return err
+	}
+
+	s.documents[uri] = doc
+
+	diagnostics := doc.diagnostics
+	if diagnostics == nil {
+		// An empty list clears any previous diagnostics, null isn't allowed
+		diagnostics = []Diagnostic{}
+	}
+
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// document returns the open document with the given URI.
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+
+	return doc, nil
+}
+
+// completion completes variable names inside a '{{'.
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	var p positionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := doc.offset(p.Position)
+
+	items := []CompletionItem{}
+
+	if !doc.completing(offset) {
+		return items, nil
+	}
+
+	seen := make(map[string]bool)
+
+	for _, decl := range append(doc.declarations(offset), s.environment(doc)...) {
+		if seen[decl.name] {
+			continue
+		}
+
+		seen[decl.name] = true
+
+		detail := decl.detail
+		if !decl.prompt {
+			detail = decl.value + " (" + decl.detail + ")"
+		}
+
+		items = append(items, CompletionItem{Label: decl.name, Kind: completionVariable, Detail: detail})
+	}
+
+	for index, name := range doc.names {
+		// Unnamed requests (e.g. '#1') can't be referenced
+		if strings.HasPrefix(name, "#") || seen[name] {
+			continue
+		}
+
+		request := doc.file.Requests[index]
+
+		items = append(items, CompletionItem{
+			Label:  name,
+			Kind:   completionModule,
+			Detail: "response to " + request.Method.Value + " " + request.URL.Value,
+		})
+	}
+
+	for _, name := range spec.Builtins() {
+		items = append(items, CompletionItem{Label: "$" + name, Kind: completionFunction, Detail: "dynamic variable"})
+	}
+
+	return items, nil
+}
+
+// hover shows the value of the variable under the cursor.
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	var p positionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := doc.offset(p.Position)
+
+	tag, start, end, ok := doc.tag(offset)
+	if !ok {
+		return nil, nil
+	}
+
+	var contents string
+
+	switch name := variable(tag.Expr); {
+	case strings.HasPrefix(tag.Expr, "$"):
+		contents = fmt.Sprintf("Dynamic variable %s, a new value is generated each time it's used", markdown(tag.Expr))
+	case name == "":
+		ref, _, err := interp.ParseReference(tag.Expr)
+		if err != nil {
+			contents = err.Error()
+			break
+		}
+
+		contents = fmt.Sprintf("The %s of the response to %s, sent first if it hasn't been already", ref.Part, markdown(ref.Request))
+	default:
+		decls := s.lookup(doc, offset, name)
+		if len(decls) == 0 {
+			contents = fmt.Sprintf("%s is not declared", markdown(name))
+			break
+		}
+
+		contents = s.describe(doc, offset, name, decls)
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: markupMarkdown, Value: contents},
+		Range:    doc.between(start, end),
+	}, nil
+}
+
+// describe returns the markdown describing a variable for hover, decls are it's
+// declarations in order of precedence so the first is the one that's used.
+func (s *Server) describe(doc *document, offset int, name string, decls []declaration) string {
+	builder := &strings.Builder{}
+
+	used := decls[0]
+
+	if used.prompt {
+		fmt.Fprintf(builder, "**%s**, asked for when the request is sent\n\n%s", name, used.detail)
+	} else {
+		fmt.Fprintf(builder, "**%s** = %s\n\n%s", name, markdown(used.value), used.detail)
+
+		if resolved := s.resolve(doc, offset, used.value); resolved != used.value {
+			fmt.Fprintf(builder, ", resolves to %s", markdown(resolved))
+		}
+	}
+
+	for _, other := range decls[1:] {
+		if other.prompt {
+			fmt.Fprintf(builder, "\n- %s", other.detail)
+			continue
+		}
+
+		fmt.Fprintf(builder, "\n- %s (%s)", markdown(other.value), other.detail)
+	}
+
+	return builder.String()
+}
+
+// resolve interpolates any variables in value as they'd be when the request at offset
+// is sent. If it can't be resolved e.g. it needs a prompt answered, it's returned as is.
+func (s *Server) resolve(doc *document, offset int, value string) string {
+	if !strings.Contains(value, "{{") {
+		return value
+	}
+
+	scope := interp.Scope{Global: make(map[string]string), Local: make(map[string]string)}
+
+	if s.env != "" {
+		for _, decl := range s.environment(doc) {
+			scope.Global[decl.name] = decl.value
+		}
+	}
+
+	for _, decl := range doc.globals() {
+		scope.Global[decl.name] = decl.value
+	}
+
+	for _, decl := range doc.locals(offset) {
+		scope.Local[decl.name] = decl.value
+	}
+
+	resolved, err := interp.New(scope, nil).Interpolate(value, syntax.Position{Name: doc.path, Line: 1, StartCol: 1, EndCol: 1})
+	if err != nil {
+		return value
+	}
+
+	return resolved
+}
+
+// definition goes to the declaration of the variable or request under the cursor.
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	var p positionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := doc.offset(p.Position)
+
+	tag, _, _, ok := doc.tag(offset)
+	if !ok {
+		return nil, nil
+	}
+
+	if ref, ok, err := interp.ParseReference(tag.Expr); ok && err == nil {
+		index := slices.Index(doc.names, ref.Request)
+		if index == -1 {
+			return nil, nil
+		}
+
+		return Location{URI: doc.uri, Range: doc.span(requestName(doc.file.Requests[index]).Range())}, nil
+	}
+
+	name := variable(tag.Expr)
+	if name == "" {
+		return nil, nil
+	}
+
+	decls := s.lookup(doc, offset, name)
+	if len(decls) == 0 {
+		return nil, nil
+	}
+
+	return Location{URI: decls[0].uri, Range: decls[0].rng}, nil
+}
+
+// documentSymbol returns a symbol for each request.
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p documentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []DocumentSymbol{}
+
+	for index, request := range doc.file.Requests {
+		symbols = append(symbols, DocumentSymbol{
+			Name:           doc.names[index],
+			Detail:         strings.TrimSpace(request.Method.Value + " " + request.URL.Value),
+			Kind:           symbolMethod,
+			Range:          doc.span(request.Span),
+			SelectionRange: doc.span(requestName(request).Range()),
+		})
+	}
+
+	return symbols, nil
+}
+
+// codeAction offers to send the request under the cursor.
+func (s *Server) codeAction(params json.RawMessage) (any, error) {
+	var p codeActionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []CodeAction{}
+
+	index := doc.request(doc.offset(p.Range.Start))
+	if index == -1 || s.execute == nil {
+		return actions, nil
+	}
+
+	name := doc.names[index]
+	title := "Send " + name
+
+	actions = append(actions, CodeAction{
+		Title:   title,
+		Kind:    actionSource,
+		Command: &Command{Title: title, Command: SendCommand, Arguments: []any{doc.uri, name}},
+	})
+
+	return actions, nil
+}
+
+// executeCommand runs the 'req.send' command in the background, responding to the
+// request with id and showing the response to the user once it's done.
+func (s *Server) executeCommand(ctx context.Context, id *json.RawMessage, params json.RawMessage) error {
+	var p executeCommandParams
+	if err := unmarshal(params, &p); err != nil {
+		return s.respond(id, nil, err)
+	}
+
+	if p.Command != SendCommand || s.execute == nil {
+		return s.respond(id, nil, &responseError{Code: codeInvalidParams, Message: "unknown command: " + p.Command})
+	}
+
+	var uri, name string
+	if len(p.Arguments) != 2 || unmarshal(p.Arguments[0], &uri) != nil || unmarshal(p.Arguments[1], &name) != nil {
+		return s.respond(id, nil, &responseError{
+			Code:    codeInvalidParams,
+			Message: SendCommand + " takes 2 arguments: the document URI and the request name",
+		})
+	}
+
+	path := uriPath(uri)
+
+	// Send what's in the editor if it's open, it may not have been saved
+	var src []byte
+	if doc, ok := s.documents[uri]; ok {
+		src = []byte(doc.text)
+	} else {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return s.respond(id, nil, err)
+		}
+
+		src = contents
+	}
+
+	s.wg.Go(func() {
+		s.logger.Debug("Sending request", "file", path, "request", name)
+
+		result, err := s.execute(ctx, path, src, name)
+		if err != nil {
+			s.logger.Warn("Could not send request", "request", name, "error", err)
+			s.report(s.notify("window/showMessage", showMessageParams{Type: messageError, Message: err.Error()}))
+			s.report(s.respond(id, nil, err))
+
+			return
+		}
+
+		s.report(s.notify("window/showMessage", showMessageParams{Type: messageInfo, Message: result}))
+		s.report(s.respond(id, result, nil))
+	})
+
+	return nil
+}
+
+// report logs an error writing to the client from the background, where there's no
+// one to return it to.
+func (s *Server) report(err error) {
+	if err != nil {
+		s.logger.Error("Could not write to client", "error", err)
+	}
+}
+
+// lookup returns the declarations of the named variable visible at offset in the
+// document, in order of precedence.
+func (s *Server) lookup(doc *document, offset int, name string) []declaration {
+	var decls []declaration
+
+	for _, decl := range append(doc.declarations(offset), s.environment(doc)...) {
+		if decl.name == name {
+			decls = append(decls, decl)
+		}
+	}
+
+	return decls
+}
+
+// environment returns the variables declared in the environment files alongside the
+// document. If an environment is selected, only it's variables are returned, otherwise
+// those in every environment.
+func (s *Server) environment(doc *document) []declaration {
+	dir := filepath.Dir(doc.path)
+
+	environments := make(map[string]map[string]env.Value)
+
+	if s.env != "" {
+		values, err := env.Load(dir, s.env)
+		if err != nil {
+			s.logger.Warn("Could not load environment", "env", s.env, "error", err)
+			return nil
+		}
+
+		environments[s.env] = values
+	} else {
+		all, err := env.All(dir)
+		if err != nil {
+			s.logger.Warn("Could not load environments", "error", err)
+			return nil
+		}
+
+		environments = all
+	}
+
+	var decls []declaration
+
+	sources := make(map[string]source)
+
+	for _, name := range slices.Sorted(maps.Keys(environments)) {
+		values := environments[name]
+
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			value := values[key]
+
+			src, ok := sources[value.Source]
+			if !ok {
+				contents, err := os.ReadFile(value.Source)
+				if err != nil {
+					continue
+				}
+
+				src = newSource(string(contents))
+				sources[value.Source] = src
+			}
+
+			decls = append(decls, declaration{
+				name:   key,
+				value:  value.Value,
+				detail: name + " environment",
+				uri:    fileURI(value.Source),
+				rng:    locate(src, key),
+			})
+		}
+	}
+
+	return decls
+}
+
+// unmarshal decodes the params of a message, an error is reported to the client as
+// invalid params.
+func unmarshal(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,500 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/lsp"
+	"go.followtheprocess.codes/test"
+	"go.uber.org/goleak"
+)
+
+const demo = `@prompt token Your API token
+@base = https://api.com
+
+### Login
+# @name login
+POST {{base}}/login
+
+### Items
+# @id = 123
+GET {{base}}/items/{{id}}?v={{version}}
+Authorization: Bearer {{login.response.body.$.token}}
+`
+
+const environments = `{"dev": {"version": "v1"}, "prod": {"version": "v2"}}`
+
+// client builds up the messages an editor would send to the server.
+type client struct {
+	buf bytes.Buffer
+	id  int
+}
+
+// request queues a request, returning it's ID.
+func (c *client) request(tb testing.TB, method string, params any) int {
+	tb.Helper()
+
+	c.id++
+	c.write(tb, map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+
+	return c.id
+}
+
+// notify queues a notification.
+func (c *client) notify(tb testing.TB, method string, params any) {
+	tb.Helper()
+	c.write(tb, map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) write(tb testing.TB, msg any) {
+	tb.Helper()
+
+	body, err := json.Marshal(msg)
+	test.Ok(tb, err)
+
+	fmt.Fprintf(&c.buf, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// open queues the initialize handshake and opening a document with the given text.
+func (c *client) open(tb testing.TB, uri, text string) {
+	tb.Helper()
+
+	c.request(tb, "initialize", map[string]any{"capabilities": map[string]any{}})
+	c.notify(tb, "initialized", map[string]any{})
+	c.notify(tb, "textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "http", "version": 1, "text": text},
+	})
+}
+
+// at returns the params for a request about a position in a document.
+func at(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// reply is a message sent from the server to the client.
+type reply struct {
+	ID     *int            `json:"id"`
+	Error  *replyError     `json:"error"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+type replyError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// serve queues a clean shutdown, runs the server over everything the client has
+// queued and returns the replies, by request ID, and notifications it sent.
+func serve(t *testing.T, c *client, options ...lsp.Option) (map[int]reply, []reply) {
+	t.Helper()
+
+	c.request(t, "shutdown", nil)
+	c.notify(t, "exit", nil)
+
+	out := &bytes.Buffer{}
+
+	server := lsp.New(&c.buf, out, options...)
+	test.Ok(t, server.Serve(context.Background()))
+
+	replies := make(map[int]reply)
+
+	var notifications []reply
+
+	reader := bufio.NewReader(out)
+
+	for {
+		headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+
+		test.Ok(t, err)
+
+		length, err := strconv.Atoi(headers.Get("Content-Length"))
+		test.Ok(t, err)
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		test.Ok(t, err)
+
+		var msg reply
+		test.Ok(t, json.Unmarshal(body, &msg))
+
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+			continue
+		}
+
+		replies[*msg.ID] = msg
+	}
+
+	return replies, notifications
+}
+
+// setup writes the demo .http file and environment file to a temporary directory,
+// returning the URI of the .http file and the path to the environment file.
+func setup(t *testing.T) (uri, envFile string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "demo.http")
+	envFile = filepath.Join(dir, "http-client.env.json")
+
+	test.Ok(t, os.WriteFile(file, []byte(demo), 0o644))
+	test.Ok(t, os.WriteFile(envFile, []byte(environments), 0o644))
+
+	return "file://" + filepath.ToSlash(file), envFile
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("initialize", func(t *testing.T) {
+		defer goleak.VerifyNone(t)
+
+		c := &client{}
+		id := c.request(t, "initialize", map[string]any{"capabilities": map[string]any{}})
+
+		replies, _ := serve(t, c, lsp.WithExecutor(nil))
+
+		var result struct {
+			Capabilities map[string]any `json:"capabilities"`
+		}
+
+		test.Ok(t, json.Unmarshal(replies[id].Result, &result))
+
+		test.Equal(t, result.Capabilities["textDocumentSync"], any(float64(1)))
+		test.Equal(t, result.Capabilities["hoverProvider"], any(true))
+		test.Equal(t, result.Capabilities["definitionProvider"], any(true))
+		test.Equal(t, result.Capabilities["documentSymbolProvider"], any(true))
+
+		// No executor, so no sending requests
+		_, ok := result.Capabilities["executeCommandProvider"]
+		test.False(t, ok)
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		c := &client{}
+		id := c.request(t, "textDocument/rename", map[string]any{})
+
+		replies, _ := serve(t, c)
+
+		test.True(t, replies[id].Error != nil, test.Context("expected an error for an unknown method"))
+		test.Equal(t, replies[id].Error.Code, -32601)
+	})
+
+	t.Run("exit without shutdown", func(t *testing.T) {
+		c := &client{}
+		c.notify(t, "exit", nil)
+
+		err := lsp.New(&c.buf, io.Discard).Serve(context.Background())
+		test.Err(t, err)
+	})
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string   // Name of the test case
+		text string   // The document text
+		want []string // Expected diagnostics as "line:start-end: message", zero indexed
+	}{
+		{
+			name: "valid",
+			text: demo,
+			want: nil,
+		},
+		{
+			name: "every error",
+			text: "### A\nGET https://api.com\nAccept application/json\n\n### B\nGET https://api.com\n\n?? latency < 200\n",
+			want: []string{
+				"2:6-6: expected ':' got ' '",
+				`7:3-10: unknown assertion subject "latency", expected status, header or body`,
+			},
+		},
+		{
+			name: "missing request",
+			text: "### A\nGET https://api.com/{{login.response.body.$.id}}\n",
+			want: []string{`1:20-48: reference to unknown request "login"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := "file:///tmp/test.http"
+
+			c := &client{}
+			c.open(t, uri, tt.text)
+
+			_, notifications := serve(t, c)
+
+			test.Equal(t, len(notifications), 1)
+			test.Equal(t, notifications[0].Method, "textDocument/publishDiagnostics")
+
+			var params struct {
+				URI         string           `json:"uri"`
+				Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+			}
+
+			test.Ok(t, json.Unmarshal(notifications[0].Params, &params))
+			test.Equal(t, params.URI, uri)
+
+			var got []string
+			for _, diagnostic := range params.Diagnostics {
+				got = append(got, fmt.Sprintf(
+					"%d:%d-%d: %s",
+					diagnostic.Range.Start.Line,
+					diagnostic.Range.Start.Character,
+					diagnostic.Range.End.Character,
+					diagnostic.Message,
+				))
+			}
+
+			test.EqualFunc(t, got, tt.want, func(a, b []string) bool { return strings.Join(a, "\n") == strings.Join(b, "\n") })
+		})
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	uri, _ := setup(t)
+
+	c := &client{}
+	c.open(t, uri, demo)
+
+	inside := c.request(t, "textDocument/completion", at(uri, 9, 8))  // GET {{ba|se}}
+	outside := c.request(t, "textDocument/completion", at(uri, 9, 1)) // G|ET
+
+	replies, _ := serve(t, c)
+
+	var items []lsp.CompletionItem
+	test.Ok(t, json.Unmarshal(replies[inside].Result, &items))
+
+	labels := make(map[string]string)
+	for _, item := range items {
+		labels[item.Label] = item.Detail
+	}
+
+	test.Equal(t, labels["base"], "https://api.com (global variable)")
+	test.Equal(t, labels["token"], "prompt: Your API token")
+	test.Equal(t, labels["id"], "123 (request variable)")
+	test.Equal(t, labels["version"], "v1 (dev environment)")
+	test.Equal(t, labels["login"], "response to POST {{base}}/login")
+	test.Equal(t, labels["$uuid"], "dynamic variable")
+
+	// Unnamed requests can't be referenced
+	_, ok := labels["#2"]
+	test.False(t, ok)
+
+	test.Ok(t, json.Unmarshal(replies[outside].Result, &items))
+	test.Equal(t, len(items), 0)
+}
+
+func TestHover(t *testing.T) {
+	uri, _ := setup(t)
+
+	tests := []struct {
+		name    string       // Name of the test case
+		want    string       // Expected hover contents, empty for no hover
+		options []lsp.Option // Options for the server
+		line    int          // Line to hover over
+		char    int          // Character to hover over
+	}{
+		{
+			name: "global",
+			line: 9,
+			char: 7,
+			want: "**base** = `https://api.com`\n\nglobal variable",
+		},
+		{
+			name: "request variable",
+			line: 9,
+			char: 22,
+			want: "**id** = `123`\n\nrequest variable",
+		},
+		{
+			name: "every environment",
+			line: 9,
+			char: 32,
+			want: "**version** = `v1`\n\ndev environment\n- `v2` (prod environment)",
+		},
+		{
+			name:    "selected environment",
+			options: []lsp.Option{lsp.WithEnv("prod")},
+			line:    9,
+			char:    32,
+			want:    "**version** = `v2`\n\nprod environment",
+		},
+		{
+			name: "response",
+			line: 10,
+			char: 30,
+			want: "The body of the response to `login`, sent first if it hasn't been already",
+		},
+		{
+			name: "not a tag",
+			line: 9,
+			char: 1,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{}
+			c.open(t, uri, demo)
+
+			id := c.request(t, "textDocument/hover", at(uri, tt.line, tt.char))
+
+			replies, _ := serve(t, c, tt.options...)
+
+			if tt.want == "" {
+				test.Equal(t, string(replies[id].Result), "null")
+				return
+			}
+
+			var hover lsp.Hover
+			test.Ok(t, json.Unmarshal(replies[id].Result, &hover))
+			test.Diff(t, hover.Contents.Value, tt.want)
+		})
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	uri, envFile := setup(t)
+
+	c := &client{}
+	c.open(t, uri, demo)
+
+	global := c.request(t, "textDocument/definition", at(uri, 9, 7))
+	local := c.request(t, "textDocument/definition", at(uri, 9, 22))
+	environment := c.request(t, "textDocument/definition", at(uri, 9, 32))
+	response := c.request(t, "textDocument/definition", at(uri, 10, 30))
+
+	replies, _ := serve(t, c)
+
+	tests := []struct {
+		name string // Name of the test case
+		uri  string // Expected URI
+		want string // Expected range as "line:char-line:char"
+		id   int    // ID of the definition request
+	}{
+		{name: "global", id: global, uri: uri, want: "1:0-1:23"},
+		{name: "request variable", id: local, uri: uri, want: "8:0-8:11"},
+		{name: "environment", id: environment, uri: "file://" + filepath.ToSlash(envFile), want: "0:9-0:18"},
+		{name: "response", id: response, uri: uri, want: "4:0-4:13"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var location lsp.Location
+			test.Ok(t, json.Unmarshal(replies[tt.id].Result, &location))
+
+			test.Equal(t, location.URI, tt.uri)
+
+			got := fmt.Sprintf(
+				"%d:%d-%d:%d",
+				location.Range.Start.Line,
+				location.Range.Start.Character,
+				location.Range.End.Line,
+				location.Range.End.Character,
+			)
+			test.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	uri, _ := setup(t)
+
+	c := &client{}
+	c.open(t, uri, demo)
+
+	id := c.request(t, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}})
+
+	replies, _ := serve(t, c)
+
+	var symbols []lsp.DocumentSymbol
+	test.Ok(t, json.Unmarshal(replies[id].Result, &symbols))
+
+	test.Equal(t, len(symbols), 2)
+
+	test.Equal(t, symbols[0].Name, "login")
+	test.Equal(t, symbols[0].Detail, "POST {{base}}/login")
+	test.Equal(t, symbols[0].Range.Start, lsp.Position{Line: 3, Character: 0})
+	test.Equal(t, symbols[0].SelectionRange.Start, lsp.Position{Line: 4, Character: 0})
+
+	test.Equal(t, symbols[1].Name, "#2")
+	test.Equal(t, symbols[1].Detail, "GET {{base}}/items/{{id}}?v={{version}}")
+	test.Equal(t, symbols[1].SelectionRange, lsp.Range{
+		Start: lsp.Position{Line: 7, Character: 0},
+		End:   lsp.Position{Line: 7, Character: 3},
+	})
+}
+
+func TestSend(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	uri, _ := setup(t)
+
+	// The document in the editor has unsaved changes
+	edited := strings.Replace(demo, "/login", "/sign-in", 1)
+
+	var (
+		gotPath string
+		gotSrc  string
+		gotName string
+	)
+
+	execute := func(ctx context.Context, path string, src []byte, name string) (string, error) {
+		gotPath, gotSrc, gotName = path, string(src), name
+		return "HTTP 200 OK", nil
+	}
+
+	c := &client{}
+	c.open(t, uri, edited)
+
+	action := c.request(t, "textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        map[string]any{"start": map[string]any{"line": 5, "character": 0}, "end": map[string]any{"line": 5, "character": 0}},
+		"context":      map[string]any{"diagnostics": []any{}},
+	})
+	send := c.request(t, "workspace/executeCommand", map[string]any{
+		"command":   lsp.SendCommand,
+		"arguments": []any{uri, "login"},
+	})
+
+	replies, notifications := serve(t, c, lsp.WithExecutor(execute))
+
+	var actions []lsp.CodeAction
+	test.Ok(t, json.Unmarshal(replies[action].Result, &actions))
+
+	test.Equal(t, len(actions), 1)
+	test.Equal(t, actions[0].Title, "Send login")
+	test.Equal(t, actions[0].Command.Command, lsp.SendCommand)
+	test.Equal(t, fmt.Sprint(actions[0].Command.Arguments), fmt.Sprint([]any{uri, "login"}))
+
+	var result string
+	test.Ok(t, json.Unmarshal(replies[send].Result, &result))
+	test.Equal(t, result, "HTTP 200 OK")
+
+	test.Equal(t, gotPath, strings.TrimPrefix(uri, "file://"))
+	test.Equal(t, gotSrc, edited)
+	test.Equal(t, gotName, "login")
+
+	// The response is shown to the user
+	last := notifications[len(notifications)-1]
+	test.Equal(t, last.Method, "window/showMessage")
+	test.True(t, strings.Contains(string(last.Params), "HTTP 200 OK"))
+}
@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC error codes, see https://www.jsonrpc.org/specification#error_object.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// LSP enum values used by the server.
+const (
+	syncFull           = 1 // TextDocumentSyncKind.Full
+	severityError      = 1 // DiagnosticSeverity.Error
+	completionFunction = 3 // CompletionItemKind.Function
+	completionVariable = 6 // CompletionItemKind.Variable
+	completionModule   = 9 // CompletionItemKind.Module
+	symbolMethod       = 6 // SymbolKind.Method
+	messageError       = 1 // MessageType.Error
+	messageInfo        = 3 // MessageType.Info
+
+	markupMarkdown = "markdown" // MarkupKind.Markdown
+	actionSource   = "source"   // CodeActionKind.Source
+)
+
+// message is an incoming JSON-RPC 2.0 message, either a request or a notification (a
+// request without an ID).
+type message struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+// isNotification reports whether the message is a notification, which must not be
+// responded to.
+func (m message) isNotification() bool {
+	return m.ID == nil
+}
+
+// response is the successful response to a request, Result may be nil.
+type response struct {
+	ID      *json.RawMessage `json:"id"`
+	Result  any              `json:"result"`
+	JSONRPC string           `json:"jsonrpc"`
+}
+
+// errorResponse is the response to a request that failed.
+type errorResponse struct {
+	ID      *json.RawMessage `json:"id"`
+	Error   *responseError   `json:"error"`
+	JSONRPC string           `json:"jsonrpc"`
+}
+
+// notification is a message from the server that expects no response.
+type notification struct {
+	Params  any    `json:"params"`
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+}
+
+// responseError is the error in a JSON-RPC response.
+type responseError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// Error implements the error interface for a [responseError].
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// read reads a single message, framed with a Content-Length header as described in
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#baseProtocol.
+func read(r *bufio.Reader) (message, error) {
+	headers, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return message{}, io.EOF
+		}
+
+		return message{}, fmt.Errorf("could not read message headers: %w", err)
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(headers.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return message{}, fmt.Errorf("bad Content-Length header %q", headers.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return message{}, fmt.Errorf("could not read message body: %w", err)
+	}
+
+	var msg message
+	if err = json.Unmarshal(body, &msg); err != nil {
+		return message{}, &responseError{Code: codeParseError, Message: err.Error()}
+	}
+
+	return msg, nil
+}
+
+// write writes a single message (a [response], [errorResponse] or [notification]) with
+// it's Content-Length header.
+func write(w io.Writer, msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode message: %w", err)
+	}
+
+	if _, err = fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("could not write message: %w", err)
+	}
+
+	return nil
+}
+
+// Position is a zero based line and character offset, in UTF-16 code units.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a range of text between two positions, End is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a particular document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic is a problem in a document, shown inline by the editor.
+type Diagnostic struct {
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+}
+
+// CompletionItem is a single completion suggestion.
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Detail string `json:"detail,omitempty"`
+	Kind   int    `json:"kind"`
+}
+
+// Hover is the information shown when hovering over some text.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    Range         `json:"range"`
+}
+
+// MarkupContent is text in some markup language, the server only uses markdown.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// DocumentSymbol is a symbol in a document e.g. a request, shown in outlines and
+// symbol pickers.
+type DocumentSymbol struct {
+	Name           string `json:"name"`
+	Detail         string `json:"detail,omitempty"`
+	Range          Range  `json:"range"`
+	SelectionRange Range  `json:"selectionRange"`
+	Kind           int    `json:"kind"`
+}
+
+// Command is a command the editor can ask the server to execute.
+type Command struct {
+	Title     string `json:"title"`
+	Command   string `json:"command"`
+	Arguments []any  `json:"arguments,omitempty"`
+}
+
+// CodeAction is an action offered at a position in a document.
+type CodeAction struct {
+	Command *Command `json:"command,omitempty"`
+	Title   string   `json:"title"`
+	Kind    string   `json:"kind"`
+}
+
+// textDocumentIdentifier identifies a document by it's URI.
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// textDocumentItem is a document opened in the editor.
+type textDocumentItem struct {
+	URI     string `json:"uri"`
+	Text    string `json:"text"`
+	Version int    `json:"version"`
+}
+
+// didOpenParams are the params of the textDocument/didOpen notification.
+type didOpenParams struct {
+	TextDocument textDocumentItem `json:"textDocument"`
+}
+
+// didChangeParams are the params of the textDocument/didChange notification, with
+// full document sync each change is the entire new text.
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// didCloseParams are the params of the textDocument/didClose notification.
+type didCloseParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+// positionParams are the params of any request about a position in a document e.g.
+// textDocument/hover.
+type positionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// documentParams are the params of any request about a whole document e.g.
+// textDocument/documentSymbol.
+type documentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+// codeActionParams are the params of the textDocument/codeAction request.
+type codeActionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// executeCommandParams are the params of the workspace/executeCommand request.
+type executeCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+// publishDiagnosticsParams are the params of the textDocument/publishDiagnostics notification.
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// showMessageParams are the params of the window/showMessage notification.
+type showMessageParams struct {
+	Message string `json:"message"`
+	Type    int    `json:"type"`
+}
@@ -0,0 +1,133 @@
+// Package prompt provides implementations of [spec.Prompter], used to provide the values
+// of variables declared with '@prompt' in .http files.
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"go.followtheprocess.codes/hue"
+	"go.followtheprocess.codes/req/internal/spec"
+)
+
+// EnvPrefix is the prefix for environment variables that provide values for prompts, the
+// rest of the variable name is the name of the prompt in upper case.
+const EnvPrefix = "REQ_PROMPT_"
+
+// Styles.
+const (
+	label = hue.Bold
+)
+
+// Static is a non-interactive [spec.Prompter] that answers prompts from a fixed set of
+// values (e.g. passed on the command line), falling back to environment variables.
+//
+// It's intended for use where a user can't answer prompts, e.g. CI. A prompt
+// with no available value is an error.
+type Static struct {
+	values map[string]string // Prompt name to value
+}
+
+// NewStatic returns a new [Static] prompter that answers with the given values.
+func NewStatic(values map[string]string) Static {
+	return Static{values: values}
+}
+
+// Prompt implements [spec.Prompter] for [Static].
+func (s Static) Prompt(prompt spec.Prompt) (string, error) {
+	if value, ok := s.lookup(prompt.Name); ok {
+		return value, nil
+	}
+
+	return "", fmt.Errorf(
+		"no value provided for prompt %q, pass '--prompt %s=<value>' or set $%s",
+		prompt.Name,
+		prompt.Name,
+		EnvVar(prompt.Name),
+	)
+}
+
+// lookup looks for the value of a prompt, first in the explicit values then
+// in the environment.
+func (s Static) lookup(name string) (string, bool) {
+	if value, ok := s.values[name]; ok {
+		return value, true
+	}
+
+	return os.LookupEnv(EnvVar(name))
+}
+
+// Terminal is an interactive [spec.Prompter] that asks the user for each value on
+// the terminal.
+//
+// Any value available to a [Static] prompter is used without asking.
+type Terminal struct {
+	in     *bufio.Reader // Where to read answers from
+	out    io.Writer     // Where to write the prompt text
+	static Static        // Values available without asking
+}
+
+// NewTerminal returns a new [Terminal] prompter that writes prompts to out and
+// reads answers from in, values already known may be passed as values.
+func NewTerminal(in io.Reader, out io.Writer, values map[string]string) Terminal {
+	return Terminal{
+		in:     bufio.NewReader(in),
+		out:    out,
+		static: NewStatic(values),
+	}
+}
+
+// Prompt implements [spec.Prompter] for [Terminal].
+func (t Terminal) Prompt(prompt spec.Prompt) (string, error) {
+	if value, ok := t.static.lookup(prompt.Name); ok {
+		return value, nil
+	}
+
+	text := prompt.Name
+	if prompt.Description != "" {
+		text = fmt.Sprintf("%s (%s)", prompt.Description, prompt.Name)
+	}
+
+	fmt.Fprintf(t.out, "%s: ", label.Text(text))
+
+	answer, err := t.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || answer == "") {
+		return "", fmt.Errorf("could not read answer for prompt %q: %w", prompt.Name, err)
+	}
+
+	return strings.TrimRight(answer, "\r\n"), nil
+}
+
+// EnvVar returns the name of the environment variable that provides the value
+// for the prompt with the given name e.g. "user-id" -> "REQ_PROMPT_USER_ID".
+func EnvVar(name string) string {
+	return EnvPrefix + strings.Map(func(r rune) rune {
+		if r == '-' {
+			return '_'
+		}
+
+		return unicode.ToUpper(r)
+	}, name)
+}
+
+// ParseValues parses a list of "name=value" pairs e.g. from command line flags
+// into a map of prompt name to value.
+func ParseValues(pairs []string) (map[string]string, error) {
+	values := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("bad prompt value %q, expected 'name=value'", pair)
+		}
+
+		values[name] = value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,159 @@
+package prompt_test
+
+import (
+	"bytes"
+	"maps"
+	"strings"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/prompt"
+	"go.followtheprocess.codes/req/internal/spec"
+	"go.followtheprocess.codes/test"
+)
+
+func TestStatic(t *testing.T) {
+	t.Setenv("REQ_PROMPT_FROM_ENV", "env value")
+	t.Setenv("REQ_PROMPT_BOTH", "env value")
+
+	prompter := prompt.NewStatic(map[string]string{
+		"from_flag": "flag value",
+		"both":      "flag value",
+	})
+
+	tests := []struct {
+		name    string // Name of the prompt
+		want    string // Expected value
+		wantErr bool   // Whether we want an error
+	}{
+		{name: "from_flag", want: "flag value"},
+		{name: "from_env", want: "env value"},
+		{name: "from-env", want: "env value"},
+		{name: "both", want: "flag value"},
+		{name: "missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prompter.Prompt(spec.Prompt{Name: tt.name})
+			test.WantErr(t, err, tt.wantErr)
+			test.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	t.Run("asks", func(t *testing.T) {
+		in := strings.NewReader("123\nbob\r\nlast")
+		out := &bytes.Buffer{}
+
+		prompter := prompt.NewTerminal(in, out, nil)
+
+		got, err := prompter.Prompt(spec.Prompt{Name: "id", Description: "The ID of the user"})
+		test.Ok(t, err)
+		test.Equal(t, got, "123")
+
+		got, err = prompter.Prompt(spec.Prompt{Name: "name"})
+		test.Ok(t, err)
+		test.Equal(t, got, "bob")
+
+		// No trailing newline
+		got, err = prompter.Prompt(spec.Prompt{Name: "last"})
+		test.Ok(t, err)
+		test.Equal(t, got, "last")
+
+		test.Equal(t, out.String(), "The ID of the user (id): name: last: ")
+
+		// Nothing left to read
+		_, err = prompter.Prompt(spec.Prompt{Name: "eof"})
+		test.Err(t, err)
+	})
+
+	t.Run("known values", func(t *testing.T) {
+		t.Setenv("REQ_PROMPT_TOKEN", "secret")
+
+		in := strings.NewReader("")
+		out := &bytes.Buffer{}
+
+		prompter := prompt.NewTerminal(in, out, map[string]string{"id": "123"})
+
+		got, err := prompter.Prompt(spec.Prompt{Name: "id"})
+		test.Ok(t, err)
+		test.Equal(t, got, "123")
+
+		got, err = prompter.Prompt(spec.Prompt{Name: "token"})
+		test.Ok(t, err)
+		test.Equal(t, got, "secret")
+
+		// Should not have asked
+		test.Equal(t, out.String(), "")
+	})
+}
+
+func TestEnvVar(t *testing.T) {
+	tests := []struct {
+		name string // Prompt name
+		want string // Expected env var
+	}{
+		{name: "id", want: "REQ_PROMPT_ID"},
+		{name: "user_id", want: "REQ_PROMPT_USER_ID"},
+		{name: "user-id", want: "REQ_PROMPT_USER_ID"},
+		{name: "apiKey", want: "REQ_PROMPT_APIKEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.Equal(t, prompt.EnvVar(tt.name), tt.want)
+		})
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		want    map[string]string // Expected values
+		name    string            // Name of the test case
+		pairs   []string          // Input pairs
+		wantErr bool              // Whether we want an error
+	}{
+		{
+			name:  "empty",
+			pairs: nil,
+			want:  map[string]string{},
+		},
+		{
+			name:  "valid",
+			pairs: []string{"id=123", "name=bob"},
+			want:  map[string]string{"id": "123", "name": "bob"},
+		},
+		{
+			name:  "equals in value",
+			pairs: []string{"query=a=b"},
+			want:  map[string]string{"query": "a=b"},
+		},
+		{
+			name:  "empty value",
+			pairs: []string{"id="},
+			want:  map[string]string{"id": ""},
+		},
+		{
+			name:    "missing equals",
+			pairs:   []string{"id"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			pairs:   []string{"=123"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prompt.ParseValues(tt.pairs)
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.EqualFunc(t, got, tt.want, maps.Equal)
+			}
+		})
+	}
+}
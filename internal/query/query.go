@@ -0,0 +1,241 @@
+// Package query implements the selectors used to pick values out of HTTP response bodies,
+// a subset of JSONPath for JSON and of XPath for XML.
+//
+// The supported JSONPath syntax is:
+//
+//	$              // The root value
+//	$.name         // A member of an object
+//	$['name']      // A member of an object, for names that aren't identifiers
+//	$.items[0]     // An element of an array, negative indices count from the end
+//	$.items[*]     // Every member or element, the result is a JSON array of the values selected
+//	$.items.length // The length of an array, object or string, if there isn't a member called "length"
+//
+// The supported XPath syntax is:
+//
+//	/root/child    // An element by absolute path
+//	//child        // The first matching element anywhere in the document
+//	/root/child[2] // The nth (1 indexed) matching element
+//	/root/@attr    // An attribute of an element
+//	/root/text()   // The text of an element, as is the element itself
+//
+// Selected values are returned as strings, JSON strings are unquoted and anything else
+// is compact JSON. For XML, the result is the text content of the selected element with
+// surrounding whitespace removed.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JSONPath selects a value from the JSON document src using path, returning it as a string.
+func JSONPath(src []byte, path string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+
+	var root any
+	if err := decoder.Decode(&root); err != nil {
+		return "", fmt.Errorf("body is not valid JSON: %w", err)
+	}
+
+	rest, ok := strings.CutPrefix(strings.TrimSpace(path), "$")
+	if !ok {
+		return "", fmt.Errorf("JSONPath %q must start with '$'", path)
+	}
+
+	steps, err := parseJSONPath(rest)
+	if err != nil {
+		return "", fmt.Errorf("bad JSONPath %q: %w", path, err)
+	}
+
+	// After a wildcard, each step applies to every selected value
+	nodes := []any{root}
+	multiple := false
+
+	for _, step := range steps {
+		next := make([]any, 0, len(nodes))
+
+		for _, node := range nodes {
+			selected, err := step.apply(node)
+			if err != nil {
+				return "", fmt.Errorf("JSONPath %q: %w", path, err)
+			}
+
+			next = append(next, selected...)
+		}
+
+		nodes = next
+		multiple = multiple || step.wildcard
+	}
+
+	if multiple {
+		return stringify(nodes)
+	}
+
+	return stringify(nodes[0])
+}
+
+// jsonStep is a single step in a JSONPath e.g. '.name' or '[0]'.
+type jsonStep struct {
+	name     string // Member name, if this step selects a member
+	index    int    // Array index, if this step selects an element
+	isIndex  bool   // Whether this step selects an array element
+	wildcard bool   // Whether this step selects everything
+}
+
+// apply applies the step to value, returning the selected values. Only a wildcard
+// may select more than one value.
+func (s jsonStep) apply(value any) ([]any, error) {
+	switch {
+	case s.wildcard:
+		switch v := value.(type) {
+		case []any:
+			return v, nil
+		case map[string]any:
+			// Sorted by key so the result is deterministic
+			values := make([]any, 0, len(v))
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				values = append(values, v[key])
+			}
+
+			return values, nil
+		default:
+			return nil, fmt.Errorf("cannot use '*' on %s", kind(value))
+		}
+	case s.isIndex:
+		array, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot index %s", kind(value))
+		}
+
+		index := s.index
+		if index < 0 {
+			index += len(array)
+		}
+
+		if index < 0 || index >= len(array) {
+			return nil, fmt.Errorf("index %d out of range for array of length %d", s.index, len(array))
+		}
+
+		return []any{array[index]}, nil
+	default:
+		if object, ok := value.(map[string]any); ok {
+			if member, ok := object[s.name]; ok {
+				return []any{member}, nil
+			}
+		}
+
+		if s.name == "length" {
+			switch v := value.(type) {
+			case []any:
+				return []any{json.Number(strconv.Itoa(len(v)))}, nil
+			case map[string]any:
+				return []any{json.Number(strconv.Itoa(len(v)))}, nil
+			case string:
+				return []any{json.Number(strconv.Itoa(len(v)))}, nil
+			}
+		}
+
+		if _, ok := value.(map[string]any); !ok {
+			return nil, fmt.Errorf("cannot select member %q of %s", s.name, kind(value))
+		}
+
+		return nil, fmt.Errorf("no member %q", s.name)
+	}
+}
+
+// parseJSONPath parses the steps in a JSONPath, after the leading '$'.
+func parseJSONPath(path string) ([]jsonStep, error) {
+	var steps []jsonStep
+
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+
+			name := path[:end]
+			if name == "" {
+				return nil, errors.New("empty member name")
+			}
+
+			if name == "*" {
+				steps = append(steps, jsonStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonStep{name: name})
+			}
+
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return nil, errors.New("unterminated '['")
+			}
+
+			inner := strings.TrimSpace(path[1:end])
+			path = path[end+1:]
+
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonStep{name: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("bad index %q", inner)
+				}
+
+				steps = append(steps, jsonStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q, expected '.' or '['", path[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// stringify converts a selected JSON value to it's string form.
+func stringify(value any) (string, error) {
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode selected value: %w", err)
+	}
+
+	return string(encoded), nil
+}
+
+// kind returns a description of the JSON type of value, for error messages.
+func kind(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
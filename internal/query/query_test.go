@@ -0,0 +1,110 @@
+package query_test
+
+import (
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/query"
+	"go.followtheprocess.codes/test"
+)
+
+func TestJSONPath(t *testing.T) {
+	src := []byte(`{
+  "token": "s3cr3t",
+  "count": 3,
+  "ok": true,
+  "missing": null,
+  "odd key": "spaces",
+  "user": {"id": 42, "name": "Tom", "tags": ["a", "b"]},
+  "items": [{"id": 1, "price": 1.50}, {"id": 2, "price": 10}, {"id": 3, "price": 100}]
+}`)
+
+	tests := []struct {
+		name    string // Name of the test case
+		path    string // The JSONPath
+		want    string // Expected selected value
+		wantErr bool   // Whether we want an error
+	}{
+		{name: "root", path: "$", want: `{"count":3,"items":[{"id":1,"price":1.50},{"id":2,"price":10},{"id":3,"price":100}],"missing":null,"odd key":"spaces","ok":true,"token":"s3cr3t","user":{"id":42,"name":"Tom","tags":["a","b"]}}`},
+		{name: "string", path: "$.token", want: "s3cr3t"},
+		{name: "number", path: "$.count", want: "3"},
+		{name: "bool", path: "$.ok", want: "true"},
+		{name: "null", path: "$.missing", want: "null"},
+		{name: "nested", path: "$.user.name", want: "Tom"},
+		{name: "object", path: "$.user.tags", want: `["a","b"]`},
+		{name: "bracket name", path: "$['odd key']", want: "spaces"},
+		{name: "index", path: "$.items[1].id", want: "2"},
+		{name: "negative index", path: "$.items[-1].id", want: "3"},
+		{name: "numbers preserved", path: "$.items[0].price", want: "1.50"},
+		{name: "wildcard", path: "$.items[*].id", want: "[1,2,3]"},
+		{name: "dot wildcard", path: "$.user.tags.*", want: `["a","b"]`},
+		{name: "length", path: "$.items.length", want: "3"},
+		{name: "string length", path: "$.token.length", want: "6"},
+		{name: "surrounding space", path: "  $.token ", want: "s3cr3t"},
+		{name: "no dollar", path: "token", wantErr: true},
+		{name: "no member", path: "$.nope", wantErr: true},
+		{name: "member of array", path: "$.items.id", wantErr: true},
+		{name: "index of object", path: "$.user[0]", wantErr: true},
+		{name: "out of range", path: "$.items[3]", wantErr: true},
+		{name: "bad index", path: "$.items[one]", wantErr: true},
+		{name: "unterminated", path: "$.items[0", wantErr: true},
+		{name: "empty name", path: "$..token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := query.JSONPath(src, tt.path)
+			test.WantErr(t, err, tt.wantErr)
+			test.Equal(t, got, tt.want)
+		})
+	}
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := query.JSONPath([]byte("<not>json</not>"), "$")
+		test.Err(t, err)
+	})
+}
+
+func TestXPath(t *testing.T) {
+	src := []byte(`<?xml version="1.0"?>
+<response status="ok">
+  <token>s3cr3t</token>
+  <users>
+    <user id="1"><name>Tom</name></user>
+    <user id="2"><name> Alice </name></user>
+  </users>
+</response>`)
+
+	tests := []struct {
+		name    string // Name of the test case
+		path    string // The XPath
+		want    string // Expected selected value
+		wantErr bool   // Whether we want an error
+	}{
+		{name: "absolute", path: "/response/token", want: "s3cr3t"},
+		{name: "text", path: "/response/token/text()", want: "s3cr3t"},
+		{name: "descendant", path: "//name", want: "Tom"},
+		{name: "position", path: "/response/users/user[2]/name", want: "Alice"},
+		{name: "attribute", path: "/response/@status", want: "ok"},
+		{name: "nested attribute", path: "//user[2]/@id", want: "2"},
+		{name: "wildcard", path: "/response/*", want: "s3cr3t"},
+		{name: "relative", path: "response/token", wantErr: true},
+		{name: "no element", path: "/response/nope", wantErr: true},
+		{name: "out of range", path: "//user[3]", wantErr: true},
+		{name: "bad position", path: "//user[0]", wantErr: true},
+		{name: "no attribute", path: "/response/@nope", wantErr: true},
+		{name: "attribute not last", path: "/response/@status/token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := query.XPath(src, tt.path)
+			test.WantErr(t, err, tt.wantErr)
+			test.Equal(t, got, tt.want)
+		})
+	}
+
+	t.Run("invalid xml", func(t *testing.T) {
+		_, err := query.XPath([]byte(`{"not": "xml"}`), "/not")
+		test.Err(t, err)
+	})
+}
@@ -0,0 +1,218 @@
+package query
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// element is a single element in a parsed XML document.
+type element struct {
+	attrs    map[string]string // Attributes by local name
+	name     string            // Local name of the element
+	text     strings.Builder   // Text content, including that of all descendants
+	children []*element        // Child elements in document order
+}
+
+// XPath selects a value from the XML document src using path, returning the text
+// content of the selected element or the value of the selected attribute.
+func XPath(src []byte, path string) (string, error) {
+	root, err := parseXML(src)
+	if err != nil {
+		return "", err
+	}
+
+	steps, err := parseXPath(strings.TrimSpace(path))
+	if err != nil {
+		return "", fmt.Errorf("bad XPath %q: %w", path, err)
+	}
+
+	// The document node, whose only child is the root element
+	nodes := []*element{{children: []*element{root}}}
+
+	for index, step := range steps {
+		last := index == len(steps)-1
+
+		switch {
+		case step.attr != "":
+			if !last {
+				return "", fmt.Errorf("bad XPath %q: attributes must be the last step", path)
+			}
+
+			value, ok := nodes[0].attrs[step.attr]
+			if !ok {
+				return "", fmt.Errorf("XPath %q: element %s has no attribute %q", path, nodes[0].name, step.attr)
+			}
+
+			return value, nil
+		case step.text:
+			if !last {
+				return "", fmt.Errorf("bad XPath %q: text() must be the last step", path)
+			}
+
+			return strings.TrimSpace(nodes[0].text.String()), nil
+		}
+
+		var matches []*element
+		for _, node := range nodes {
+			matches = append(matches, step.match(node)...)
+		}
+
+		if step.position > 0 {
+			if step.position > len(matches) {
+				return "", fmt.Errorf("XPath %q: no element %s[%d]", path, step.name, step.position)
+			}
+
+			matches = matches[step.position-1 : step.position]
+		}
+
+		if len(matches) == 0 {
+			return "", fmt.Errorf("XPath %q: no element %s", path, step.name)
+		}
+
+		nodes = matches
+	}
+
+	return strings.TrimSpace(nodes[0].text.String()), nil
+}
+
+// xpathStep is a single step in an XPath e.g. '/name', '//name[2]' or '/@attr'.
+type xpathStep struct {
+	name       string // The element name to match, '*' matches any element
+	attr       string // The attribute to select, if this is an attribute step
+	position   int    // The 1 indexed position of the element to select, 0 means all
+	descendant bool   // Whether the step matches any descendant ('//'), rather than just children
+	text       bool   // Whether this is a 'text()' step
+}
+
+// match returns the elements matched by the step, starting from node.
+func (s xpathStep) match(node *element) []*element {
+	var matches []*element
+
+	for _, child := range node.children {
+		if s.name == "*" || child.name == s.name {
+			matches = append(matches, child)
+		}
+
+		if s.descendant {
+			matches = append(matches, s.match(child)...)
+		}
+	}
+
+	return matches
+}
+
+// parseXPath parses an absolute XPath into it's steps.
+func parseXPath(path string) ([]xpathStep, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("must be an absolute path starting with '/'")
+	}
+
+	var steps []xpathStep
+
+	for path != "" {
+		step := xpathStep{}
+
+		switch {
+		case strings.HasPrefix(path, "//"):
+			step.descendant = true
+			path = path[2:]
+		case strings.HasPrefix(path, "/"):
+			path = path[1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q, expected '/'", path)
+		}
+
+		end := strings.IndexByte(path, '/')
+		if end == -1 {
+			end = len(path)
+		}
+
+		part := path[:end]
+		path = path[end:]
+
+		switch {
+		case part == "":
+			return nil, errors.New("empty step")
+		case part == "text()":
+			step.text = true
+		case strings.HasPrefix(part, "@"):
+			step.attr = part[1:]
+		default:
+			name, predicate, hasPredicate := strings.Cut(part, "[")
+			step.name = name
+
+			if hasPredicate {
+				position, err := strconv.Atoi(strings.TrimSuffix(predicate, "]"))
+				if err != nil || !strings.HasSuffix(predicate, "]") || position < 1 {
+					return nil, fmt.Errorf("bad position in %q, expected e.g. [1]", part)
+				}
+
+				step.position = position
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// parseXML parses an XML document, returning it's root element.
+func parseXML(src []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(src))
+
+	var (
+		root  *element
+		stack []*element
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("body is not valid XML: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			elem := &element{name: token.Name.Local, attrs: make(map[string]string, len(token.Attr))}
+			for _, attr := range token.Attr {
+				elem.attrs[attr.Name.Local] = attr.Value
+			}
+
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, errors.New("body is not valid XML: multiple root elements")
+				}
+
+				root = elem
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, elem)
+			}
+
+			stack = append(stack, elem)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			// Text belongs to the element it's in and all of it's ancestors
+			for _, elem := range stack {
+				elem.text.Write(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("body is not valid XML: no root element")
+	}
+
+	return root, nil
+}
@@ -0,0 +1,87 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.followtheprocess.codes/log"
+	"go.followtheprocess.codes/req/internal/grpc"
+	"go.followtheprocess.codes/req/internal/spec"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// doGRPC implements `req do` for a gRPC request.
+//
+// The request's headers are sent as metadata and it's JSON body as the request message,
+// described by the server with reflection or by the request's '@proto' file. The status,
+// header metadata, response message as JSON and then any trailers are printed.
+func (r Req) doGRPC(ctx context.Context, file string, request spec.Request, logger *log.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, request.Timeout)
+	defer cancel()
+
+	target, err := grpc.ParseTarget(request.URL)
+	if err != nil {
+		return err
+	}
+
+	md := make(metadata.MD, len(request.Headers))
+	for _, header := range request.Headers {
+		md.Append(header.Name, header.Value)
+	}
+
+	call := grpc.Request{
+		Target:            target,
+		Metadata:          md,
+		Body:              request.Body,
+		ConnectionTimeout: request.ConnectionTimeout,
+	}
+
+	if request.Proto != "" {
+		call.Proto = resolvePath(file, request.Proto)
+	}
+
+	logger.Debug("Calling gRPC method", "method", target.FullMethod(), "address", target.Address, "proto", call.Proto)
+
+	response, err := grpc.Invoke(ctx, call)
+	if err != nil {
+		return fmt.Errorf("gRPC: %w", err)
+	}
+
+	status := fmt.Sprintf("%d %s", response.Status.Code(), response.Status.Code())
+	if message := response.Status.Message(); message != "" {
+		status += ": " + message
+	}
+
+	if response.Status.Code() == codes.OK {
+		fmt.Fprintln(r.stdout, success.Text(status))
+	} else {
+		fmt.Fprintln(r.stdout, failure.Text(status))
+	}
+
+	r.printMetadata(response.Header)
+	fmt.Fprintln(r.stdout) // Line space
+
+	// A failed call has no response message, only trailers
+	if response.Body != nil {
+		fmt.Fprintln(r.stdout, string(response.Body))
+
+		if len(response.Trailer) > 0 {
+			fmt.Fprintln(r.stdout) // Line space
+		}
+	}
+
+	r.printMetadata(response.Trailer)
+
+	return nil
+}
+
+// printMetadata prints gRPC metadata sorted by key, like HTTP headers.
+func (r Req) printMetadata(md metadata.MD) {
+	for _, key := range slices.Sorted(maps.Keys(md)) {
+		fmt.Fprintf(r.stdout, "%s: %s\n", headerName.Text(key), strings.Join(md[key], ", "))
+	}
+}
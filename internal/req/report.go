@@ -0,0 +1,277 @@
+package req
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/spec"
+)
+
+// Report formats supported by `req run --report`.
+const (
+	reportJUnit = "junit"
+	reportJSON  = "json"
+)
+
+// reportTarget is a single '--report format=path'.
+type reportTarget struct {
+	format string // One of the report formats e.g. "junit"
+	path   string // Path to write the report to
+}
+
+// parseReports parses the '--report' flags, so a typo is reported before any
+// requests are sent.
+func parseReports(reports []string) ([]reportTarget, error) {
+	targets := make([]reportTarget, 0, len(reports))
+
+	for _, report := range reports {
+		format, path, ok := strings.Cut(report, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid --report %q, expected format=path e.g. junit=report.xml", report)
+		}
+
+		switch format {
+		case reportJUnit, reportJSON:
+			targets = append(targets, reportTarget{format: format, path: path})
+		default:
+			return nil, fmt.Errorf("unknown --report format %q, expected %q or %q", format, reportJUnit, reportJSON)
+		}
+	}
+
+	return targets, nil
+}
+
+// report is the machine readable result of `req run`.
+type report struct {
+	Start      time.Time    `json:"start"`
+	File       string       `json:"file"`
+	Cases      []reportCase `json:"cases"`
+	DurationMS int64        `json:"durationMs"`
+	Passed     int          `json:"passed"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+}
+
+// reportCase is the result of a single request in a [report].
+type reportCase struct {
+	Name       string   `json:"name"`
+	Result     string   `json:"result"`             // "passed", "failed" or "skipped"
+	Error      string   `json:"error,omitempty"`    // Why the request couldn't be resolved or sent
+	Request    string   `json:"request,omitempty"`  // The resolved request, only captured on failure
+	Response   string   `json:"response,omitempty"` // The response, only captured on failure
+	Failures   []string `json:"failures,omitempty"` // The assertions that failed
+	DurationMS int64    `json:"durationMs"`
+	StatusCode int      `json:"statusCode,omitempty"`
+}
+
+// newReport builds the report for a run of the named requests.
+func newReport(file string, start time.Time, names []string, results []*runResult) report {
+	rep := report{
+		Start:      start,
+		File:       file,
+		Cases:      make([]reportCase, 0, len(names)),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+
+	for index, name := range names {
+		result := results[index]
+
+		c := reportCase{
+			Name:       name,
+			DurationMS: result.duration.Milliseconds(),
+			StatusCode: result.response.StatusCode,
+			Failures:   result.failures,
+		}
+
+		switch {
+		case result.skipped:
+			c.Result = "skipped"
+			rep.Skipped++
+		case result.failed():
+			c.Result = "failed"
+			rep.Failed++
+
+			if result.err != nil {
+				c.Error = result.err.Error()
+			}
+
+			if result.request.Method != "" {
+				c.Request = result.request.String()
+			}
+
+			if result.err == nil {
+				c.Response = formatResponse(result.response)
+			}
+		default:
+			c.Result = "passed"
+			rep.Passed++
+		}
+
+		rep.Cases = append(rep.Cases, c)
+	}
+
+	return rep
+}
+
+// write writes the report in the target's format, creating any parent directories as needed.
+func (r report) write(target reportTarget) error {
+	var (
+		contents []byte
+		err      error
+	)
+
+	switch target.format {
+	case reportJUnit:
+		contents, err = r.junit()
+	default:
+		contents, err = json.MarshalIndent(r, "", "  ")
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not encode %s report: %w", target.format, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target.path), 0o755); err != nil {
+		return fmt.Errorf("could not create directory for %s: %w", target.path, err)
+	}
+
+	if err := os.WriteFile(target.path, append(contents, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write %s report: %w", target.format, err)
+	}
+
+	return nil
+}
+
+// junitSuites is the root of a JUnit XML report, as understood by GitHub, GitLab etc.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+}
+
+// junitSuite is a JUnit test suite, one per .http file.
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+}
+
+// junitCase is a JUnit test case, one per request.
+type junitCase struct {
+	Skipped   *struct{}     `xml:"skipped"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitProblem is a JUnit failure (the request got the wrong response) or error (the
+// request couldn't be sent).
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit encodes the report as JUnit XML.
+func (r report) junit() ([]byte, error) {
+	suite := junitSuite{
+		Name:      r.File,
+		Timestamp: r.Start.Format(time.RFC3339),
+		Time:      seconds(r.DurationMS),
+		Cases:     make([]junitCase, 0, len(r.Cases)),
+		Tests:     len(r.Cases),
+		Skipped:   r.Skipped,
+	}
+
+	for _, c := range r.Cases {
+		testCase := junitCase{
+			Name:      c.Name,
+			ClassName: r.File,
+			Time:      seconds(c.DurationMS),
+		}
+
+		switch {
+		case c.Result == "skipped":
+			testCase.Skipped = &struct{}{}
+		case c.Error != "":
+			suite.Errors++
+			testCase.Error = &junitProblem{Message: c.Error, Type: "error"}
+			testCase.SystemOut = c.Request
+		case c.Result == "failed":
+			suite.Failures++
+
+			message := fmt.Sprintf("%d %s", c.StatusCode, http.StatusText(c.StatusCode))
+			if len(c.Failures) > 0 {
+				message = fmt.Sprintf("%d of the assertions failed", len(c.Failures))
+			}
+
+			testCase.Failure = &junitProblem{Message: message, Type: "assertion", Text: strings.Join(c.Failures, "\n")}
+			testCase.SystemOut = c.Request + "\n" + c.Response
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	suites := junitSuites{
+		Name:     "req",
+		Suites:   []junitSuite{suite},
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+	}
+
+	contents, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), contents...), nil
+}
+
+// formatResponse formats a response as it would appear on the wire, for a report.
+func formatResponse(response spec.Response) string {
+	builder := &strings.Builder{}
+
+	fmt.Fprintf(builder, "HTTP %d %s\n", response.StatusCode, http.StatusText(response.StatusCode))
+
+	for _, key := range slices.Sorted(maps.Keys(response.Header)) {
+		for _, value := range response.Header[key] {
+			fmt.Fprintf(builder, "%s: %s\n", key, value)
+		}
+	}
+
+	if len(response.Body) > 0 {
+		builder.WriteString("\n")
+		builder.Write(response.Body)
+	}
+
+	return builder.String()
+}
+
+// seconds formats a duration in milliseconds as seconds, as JUnit expects.
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
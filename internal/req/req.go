@@ -3,25 +3,41 @@ package req
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"maps"
+	"mime/multipart"
 	"net"
 	"net/http"
+	"net/textproto"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.followtheprocess.codes/hue"
 	"go.followtheprocess.codes/log"
 	"go.followtheprocess.codes/msg"
+	"go.followtheprocess.codes/req/internal/diff"
+	"go.followtheprocess.codes/req/internal/env"
+	"go.followtheprocess.codes/req/internal/graphql"
+	"go.followtheprocess.codes/req/internal/lsp"
+	"go.followtheprocess.codes/req/internal/prompt"
 	"go.followtheprocess.codes/req/internal/spec"
 	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/format"
 	"go.followtheprocess.codes/req/internal/syntax/parser"
+	"go.followtheprocess.codes/req/internal/vars"
+	"golang.org/x/term"
 )
 
 // Styles.
@@ -50,13 +66,14 @@ const (
 
 // Req holds the state of the program.
 type Req struct {
+	stdin  io.Reader   // Input typed by the user e.g. messages in an interactive WebSocket session
 	stdout io.Writer   // Normal program output is written here
 	stderr io.Writer   // Errors, logs and debug info written here
 	logger *log.Logger // The logger, passed around the whole program
 }
 
 // New returns a new instance of [Req].
-func New(stdout, stderr io.Writer, debug bool) Req {
+func New(stdin io.Reader, stdout, stderr io.Writer, debug bool) Req {
 	level := log.LevelInfo
 	if debug {
 		level = log.LevelDebug
@@ -65,6 +82,7 @@ func New(stdout, stderr io.Writer, debug bool) Req {
 	logger := log.New(stderr, log.WithLevel(level))
 
 	return Req{
+		stdin:  stdin,
 		stdout: stdout,
 		stderr: stderr,
 		logger: logger,
@@ -91,17 +109,40 @@ func (r Req) Check(files []string, options CheckOptions) error {
 			return err
 		}
 
-		parser, err := parser.New(file, f, syntax.PrettyConsoleHandler(r.stderr))
+		// Errors are reported all together once parsing is finished, rather than
+		// as they're found, so they're in order
+		parser, err := parser.New(file, f, nil)
 		if err != nil {
 			return err
 		}
 
-		_, err = parser.Parse()
+		raw, err := parser.Parse()
+
+		f.Close()
+
 		if err != nil {
-			return fmt.Errorf("%w: %s is not valid http syntax", err, file)
+			var errs syntax.ErrorList
+			if !errors.As(err, &errs) {
+				return fmt.Errorf("%w: %s is not valid http syntax", err, file)
+			}
+
+			errs.Report(syntax.PrettyConsoleHandler(r.stderr))
+
+			noun := "errors"
+			if len(errs) == 1 {
+				noun = "error"
+			}
+
+			return fmt.Errorf("%w: %s is not valid http syntax, found %d %s", err, file, len(errs), noun)
 		}
 
-		f.Close()
+		// Both are checked so that every problem is reported at once
+		referenceErr := spec.CheckReferences(raw, syntax.PrettyConsoleHandler(r.stderr))
+		graphqlErr := spec.CheckGraphQL(raw, syntax.PrettyConsoleHandler(r.stderr))
+
+		if err = cmp.Or(referenceErr, graphqlErr); err != nil {
+			return fmt.Errorf("%w: %s", err, file)
+		}
 
 		msg.Fsuccess(r.stdout, "%s is valid", file)
 		logger.Debug("Took", "duration", time.Since(start))
@@ -112,11 +153,82 @@ func (r Req) Check(files []string, options CheckOptions) error {
 	return nil
 }
 
+// FmtOptions are the flags passed to the `req fmt` subcommand.
+type FmtOptions struct {
+	Check   bool // Don't write the files, error if any aren't formatted
+	Diff    bool // Don't write the files, print a diff of the changes instead
+	Verbose bool // Enable debug logs
+}
+
+// Fmt implements the `req fmt` subcommand.
+//
+// Each file is formatted in place, unless options.Check or options.Diff are set in which
+// case the files are left alone.
+func (r Req) Fmt(files []string, options FmtOptions) error {
+	logger := r.logger.Prefixed("fmt")
+	unformatted := 0
+
+	for _, file := range files {
+		logger.Debug("Formatting", "file", file)
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		formatted, err := format.Source(file, src, syntax.PrettyConsoleHandler(r.stderr))
+		if err != nil {
+			return fmt.Errorf("%w: %s is not valid http syntax", err, file)
+		}
+
+		if bytes.Equal(src, formatted) {
+			logger.Debug("Already formatted", "file", file)
+			continue
+		}
+
+		unformatted++
+
+		if options.Diff {
+			fmt.Fprintf(r.stdout, "%s", diff.Unified(file+".orig", file, src, formatted))
+		}
+
+		if options.Check {
+			msg.Fwarn(r.stderr, "%s is not formatted", file)
+		}
+
+		if options.Check || options.Diff {
+			continue
+		}
+
+		if err := os.WriteFile(file, formatted, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("could not write %s: %w", file, err)
+		}
+
+		msg.Fsuccess(r.stdout, "Formatted %s", file)
+	}
+
+	if options.Check && unformatted > 0 {
+		return fmt.Errorf("%d of %d files are not formatted, run req fmt to fix", unformatted, len(files))
+	}
+
+	return nil
+}
+
 // ShowOptions are the flags passed to the `req show` subcommand.
 type ShowOptions struct {
-	Resolve bool // Resolve variables and do replacements
-	JSON    bool // Output the file in JSON
-	Verbose bool // Enable debug logs
+	Env     string   // Name of the environment to use, only used with Resolve
+	VarFile string   // Path to a dotenv or JSON file of variable overrides, only used with Resolve
+	Vars    []string // Variable overrides as "name=value", only used with Resolve
+	Prompts []string // Values for prompts as "name=value", only used with Resolve
+	Seed    string   // If set, seeds dynamic variables e.g. '$uuid' so they're reproducible, only used with Resolve
+	Resolve bool     // Resolve variables and do replacements
+	JSON    bool     // Output the file in JSON
+	Verbose bool     // Enable debug logs
 }
 
 // Show implements the `req show` subcommand.
@@ -138,7 +250,27 @@ func (r Req) Show(file string, options ShowOptions) error {
 	}
 
 	if options.Resolve {
-		resolved, err := spec.ResolveFile(raw)
+		envVars, err := r.environment(file, options.Env)
+		if err != nil {
+			return err
+		}
+
+		overrides, err := r.overrides(options.VarFile, options.Vars)
+		if err != nil {
+			return err
+		}
+
+		prompter, err := r.prompter(options.Prompts)
+		if err != nil {
+			return err
+		}
+
+		resolveOptions, err := r.resolveOptions(file, envVars, overrides, prompter, options.Seed)
+		if err != nil {
+			return err
+		}
+
+		resolved, err := spec.ResolveFile(raw, resolveOptions...)
 		if err != nil {
 			return err
 		}
@@ -164,9 +296,17 @@ func (r Req) Show(file string, options ShowOptions) error {
 // DoOptions are the flags passed to the `req do` subcommand.
 type DoOptions struct {
 	Output            string
+	Env               string
+	VarFile           string
+	Vars              []string
+	Prompts           []string
 	Timeout           time.Duration
 	ConnectionTimeout time.Duration
+	Seed              string
 	NoRedirect        bool
+	FailOnDiff        bool
+	Interactive       bool
+	Stream            bool
 	Verbose           bool
 }
 
@@ -194,36 +334,68 @@ func (r Req) Do(file, name string, options DoOptions) error {
 		return fmt.Errorf("%w: %s is not valid http syntax", err, file)
 	}
 
-	resolved, err := spec.ResolveFile(raw)
+	envVars, err := r.environment(file, options.Env)
 	if err != nil {
 		return err
 	}
 
-	request, ok := resolved.GetRequest(name)
-	if !ok {
-		return fmt.Errorf("%s does not contain request %s", file, name)
+	overrides, err := r.overrides(options.VarFile, options.Vars)
+	if err != nil {
+		return err
 	}
 
-	logger.Debug("Parsed file", "duration", time.Since(parseStart))
+	prompter, err := r.prompter(options.Prompts)
+	if err != nil {
+		return err
+	}
 
-	httpRequest, err := http.NewRequestWithContext(
-		ctx,
-		request.Method,
-		request.URL,
-		bytes.NewReader(request.Body),
-	)
+	// Only the request we're sending (and any it references) is resolved, so the
+	// user isn't prompted for values that aren't needed
+	run := newRunner(ctx, file, logger)
+	defer run.Close()
+
+	resolveOptions, err := r.resolveOptions(file, envVars, overrides, prompter, options.Seed)
+	if err != nil {
+		return err
+	}
+
+	resolveOptions = append(resolveOptions, spec.WithResponses(run))
+
+	run.resolver, err = spec.NewResolver(raw, resolveOptions...)
 	if err != nil {
 		return err
 	}
 
-	for key, value := range request.Headers {
-		httpRequest.Header.Add(key, value)
+	request, err := run.Request(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
 	}
 
-	client := httpClient(request)
+	logger.Debug("Resolved request", "duration", time.Since(parseStart))
+
+	// A WebSocket request isn't sent over HTTP, it's a session of messages
+	if request.Method == spec.MethodWebSocket {
+		return r.doWebSocket(request, options, logger)
+	}
+
+	// Nor is a gRPC request, it's a call to a unary gRPC method
+	if request.Method == spec.MethodGRPC {
+		return r.doGRPC(ctx, file, request, logger)
+	}
 
 	requestStart := time.Now()
 
+	// The timeouts cover sending the request and reading the response, unless it's
+	// streamed in which case they stop once it's headers arrive so it can run for as
+	// long as it likes, until Ctrl-C
+	sendCtx, cancelSend := context.WithCancelCause(context.Background())
+	defer cancelSend(nil)
+
+	stopTimeout := context.AfterFunc(ctx, func() { cancelSend(context.Cause(ctx)) })
+	requestTimeout := time.AfterFunc(request.Timeout, func() { cancelSend(context.DeadlineExceeded) })
+
+	defer requestTimeout.Stop()
+
 	logger.Debug(
 		"Sending HTTP request",
 		"method",
@@ -234,9 +406,9 @@ func (r Req) Do(file, name string, options DoOptions) error {
 		request.Headers,
 	)
 
-	response, err := client.Do(httpRequest)
+	response, err := run.sendUntimed(sendCtx, request)
 	if err != nil {
-		return fmt.Errorf("HTTP: %w", err)
+		return err
 	}
 
 	if response == nil {
@@ -247,11 +419,94 @@ func (r Req) Do(file, name string, options DoOptions) error {
 
 	logger.Debug("Response", "status", response.Status, "duration", time.Since(requestStart))
 
+	r.printResponseHead(response)
+
+	// The --output flag takes precedence over any response redirect in the file, and
+	// is relative to cwd like any other command line path
+	output, unique := options.Output, false
+	if output == "" && request.ResponseFile != "" {
+		output = resolvePath(file, request.ResponseFile)
+		unique = request.UniqueResponseFile
+	}
+
+	// Without a response reference, there's no need to hold the body in memory
+	if output != "" && request.ResponseReference == "" {
+		written, err := writeResponse(output, unique, response.Body)
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("Wrote response body", "file", written)
+		msg.Fsuccess(r.stdout, "Response body written to %s", written)
+
+		return nil
+	}
+
+	// A streaming response is printed as it arrives, only keeping it in memory if it's
+	// compared against a reference once it's finished
+	if output == "" && (options.Stream || isStreaming(response.Header.Get("Content-Type"))) {
+		stopTimeout()
+		requestTimeout.Stop()
+
+		// Ctrl-C stops the stream cleanly, rather than killing req part way through
+		interrupted, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		context.AfterFunc(interrupted, func() { cancelSend(context.Canceled) })
+
+		body := &bytes.Buffer{}
+
+		var reader io.Reader = response.Body
+		if request.ResponseReference != "" {
+			reader = io.TeeReader(response.Body, body)
+		}
+
+		err = r.stream(reader, response.Header.Get("Content-Type"))
+		if interrupted.Err() != nil {
+			logger.Debug("Interrupted, stopped streaming response")
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if request.ResponseReference != "" {
+			return r.compareReference(resolvePath(file, request.ResponseReference), body.Bytes(), options.FailOnDiff)
+		}
+
+		return nil
+	}
+
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		return err
 	}
 
+	if output != "" {
+		written, err := writeResponse(output, unique, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("Wrote response body", "file", written)
+		msg.Fsuccess(r.stdout, "Response body written to %s", written)
+	} else if request.GraphQL {
+		r.printGraphQL(body)
+	} else {
+		fmt.Fprintln(r.stdout, string(body))
+	}
+
+	if request.ResponseReference != "" {
+		return r.compareReference(resolvePath(file, request.ResponseReference), body, options.FailOnDiff)
+	}
+
+	return nil
+}
+
+// printResponseHead prints the status and sorted headers of a response, followed by
+// a blank line ready for the body.
+func (r Req) printResponseHead(response *http.Response) {
 	if response.StatusCode >= http.StatusBadRequest {
 		fmt.Fprintln(r.stdout, failure.Text(response.Status))
 	} else {
@@ -263,12 +518,749 @@ func (r Req) Do(file, name string, options DoOptions) error {
 	}
 
 	fmt.Fprintln(r.stdout) // Line space
+}
+
+// printGraphQL prints the body of a GraphQL response, the data as indented JSON to stdout
+// then any errors to stderr. Anything that isn't a GraphQL response is printed as is.
+func (r Req) printGraphQL(body []byte) {
+	var response graphql.Response
+	if err := json.Unmarshal(body, &response); err != nil || (response.Data == nil && len(response.Errors) == 0) {
+		fmt.Fprintln(r.stdout, string(body))
+		return
+	}
+
+	// Data is null if the errors meant nothing could be returned
+	if response.Data != nil && string(response.Data) != "null" {
+		data := &bytes.Buffer{}
+		if err := json.Indent(data, response.Data, "", "  "); err != nil {
+			data = bytes.NewBuffer(response.Data)
+		}
+
+		fmt.Fprintln(r.stdout, data.String())
+	}
+
+	for _, err := range response.Errors {
+		msg.Ferror(r.stderr, "%s", err)
+	}
+}
+
+// compareReference compares a response body to the copy saved at path by the previous
+// run, printing any differences, then saves body in it's place for the next one. If
+// there is no saved copy, body is simply saved.
+//
+// JSON bodies are compared structurally, so only changes to the data are shown, anything
+// else gets a line based diff.
+//
+// If failOnDiff is true and they differ, an error is returned and the saved copy is left
+// as it was, so it continues to be what later runs are compared against.
+func (r Req) compareReference(path string, body []byte, failOnDiff bool) error {
+	previous, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if _, err = writeResponse(path, false, bytes.NewReader(body)); err != nil {
+			return err
+		}
+
+		msg.Fsuccess(r.stdout, "Response saved to %s", path)
+
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not read response reference: %w", err)
+	}
+
+	var changes []byte
+
+	if json.Valid(previous) && json.Valid(body) {
+		changes, err = diff.JSON(previous, body)
+		if err != nil {
+			return err
+		}
+	} else {
+		changes = diff.Unified(path, "response", previous, body)
+	}
+
+	if changes == nil {
+		msg.Fsuccess(r.stdout, "Response matches %s", path)
+		return nil
+	}
+
+	fmt.Fprintf(r.stdout, "\nResponse differs from %s:\n%s", path, changes)
+
+	if failOnDiff {
+		return fmt.Errorf("response differs from %s", path)
+	}
+
+	if _, err = writeResponse(path, false, bytes.NewReader(body)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RunOptions are the flags passed to the `req run` subcommand.
+type RunOptions struct {
+	Filter   string   // Only run requests whose name matches this regular expression
+	Env      string   // Name of the environment to use
+	VarFile  string   // Path to a dotenv or JSON file of variable overrides
+	Vars     []string // Variable overrides as "name=value"
+	Prompts  []string // Values for prompts as "name=value"
+	Seed     string   // If set, seeds dynamic variables e.g. '$uuid' so they're reproducible
+	Reports  []string // Reports to write as "format=path", format is "junit" or "json"
+	Parallel int      // Maximum number of requests to send at once, less than 1 means 1
+	FailFast bool     // Stop at the first failed request
+	Verbose  bool     // Enable debug logs
+}
+
+// Run implements the `req run` subcommand.
+//
+// Every request in the file (or those matching the filter) is sent in order, sharing
+// cookies and responses so later requests may use earlier ones. A request fails if it
+// can't be resolved or sent, if any of it's assertions fail or, if it has none, if the
+// response has an error status (>= 400).
+//
+// With options.Parallel > 1, up to that many requests are sent at once but the results
+// are still reported in file order.
+func (r Req) Run(file string, options RunOptions) error {
+	logger := r.logger.Prefixed("run").With("file", file)
+	start := time.Now()
+
+	var filter *regexp.Regexp
+	if options.Filter != "" {
+		var err error
+
+		filter, err = regexp.Compile(options.Filter)
+		if err != nil {
+			return fmt.Errorf("invalid --filter: %w", err)
+		}
+	}
+
+	reports, err := parseReports(options.Reports)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	parser, err := parser.New(file, f, syntax.PrettyConsoleHandler(r.stderr))
+	if err != nil {
+		return err
+	}
+
+	raw, err := parser.Parse()
+	if err != nil {
+		return fmt.Errorf("%w: %s is not valid http syntax", err, file)
+	}
+
+	envVars, err := r.environment(file, options.Env)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := r.overrides(options.VarFile, options.Vars)
+	if err != nil {
+		return err
+	}
+
+	prompter, err := r.prompter(options.Prompts)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels any requests in flight and stops any more being sent
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	run := newRunner(ctx, file, logger)
+	defer run.Close()
+
+	resolveOptions, err := r.resolveOptions(file, envVars, overrides, prompter, options.Seed)
+	if err != nil {
+		return err
+	}
+
+	resolveOptions = append(resolveOptions, spec.WithResponses(run))
+
+	run.resolver, err = spec.NewResolver(raw, resolveOptions...)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+
+	for _, request := range raw.Requests {
+		if filter == nil || filter.MatchString(request.Name) {
+			names = append(names, request.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("no requests in %s match --filter %q", file, options.Filter)
+	}
+
+	parallel := max(options.Parallel, 1)
+
+	logger.Debug("Running requests", "count", len(names), "parallel", parallel)
+
+	results, wait := runAll(ctx, run, names, parallel, options.FailFast)
+	defer wait()
+
+	passed, failed, skipped := 0, 0, 0
+
+	// Results are reported in file order, regardless of the order they complete in
+	for index, name := range names {
+		result := results[index]
+		<-result.done
+
+		switch {
+		case result.skipped:
+			skipped++
+		case result.err != nil:
+			failed++
+
+			fmt.Fprintf(r.stdout, "%s %s: %v\n", failure.Text("FAIL"), name, result.err)
+		case result.failed():
+			failed++
+
+			fmt.Fprintf(r.stdout, "%s %s: %s (%s)\n", failure.Text("FAIL"), name, result.status(), result.duration)
+
+			for _, message := range result.failures {
+				fmt.Fprintf(r.stdout, "    %s\n", message)
+			}
+		default:
+			passed++
+
+			fmt.Fprintf(r.stdout, "%s %s: %s (%s)\n", success.Text("PASS"), name, result.status(), result.duration)
+		}
+	}
+
+	logger.Debug("Took", "duration", time.Since(start))
+
+	fmt.Fprintf(r.stdout, "\n%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+
+	if len(reports) > 0 {
+		report := newReport(file, start, names, results)
+		for _, target := range reports {
+			if err := report.write(target); err != nil {
+				return err
+			}
+
+			logger.Debug("Wrote report", "format", target.format, "path", target.path)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted, %d of %d requests failed", failed, len(names))
+	}
 
-	fmt.Fprintln(r.stdout, string(body))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, len(names))
+	}
 
 	return nil
 }
 
+// runResult is the outcome of a single request in `req run`.
+type runResult struct {
+	done       chan struct{} // Closed once the result is available
+	err        error         // The error resolving or sending the request
+	response   spec.Response // The response
+	request    spec.Request  // The resolved request, empty if it couldn't be resolved
+	failures   []string      // Descriptions of the assertions that failed, prefixed with their position
+	duration   time.Duration // How long the request took
+	assertions int           // The number of assertions checked against the response
+	skipped    bool          // The request was never sent, because of --fail-fast or an interrupt
+}
+
+// status returns the HTTP status of the response e.g. "200 OK".
+func (r *runResult) status() string {
+	return fmt.Sprintf("%d %s", r.response.StatusCode, http.StatusText(r.response.StatusCode))
+}
+
+// failed reports whether the request failed.
+//
+// A request with assertions fails if any of them do, so e.g. '?? status == 404' may
+// expect an error status, a request without fails on any error status.
+func (r *runResult) failed() bool {
+	if r.err != nil || len(r.failures) > 0 {
+		return true
+	}
+
+	return r.assertions == 0 && r.response.StatusCode >= http.StatusBadRequest
+}
+
+// runAll sends the named requests with up to parallel workers, returning their results
+// in the same order as names. Each result's done channel is closed once it's available.
+//
+// Requests are started in order, once a request fails with failFast or ctx is cancelled
+// no more are started and the rest are skipped. The caller must call wait, which returns
+// once all the workers have exited.
+func runAll(ctx context.Context, run *runner, names []string, parallel int, failFast bool) (results []*runResult, wait func()) {
+	results = make([]*runResult, len(names))
+	for index := range results {
+		results[index] = &runResult{done: make(chan struct{})}
+	}
+
+	// Cancelled to stop starting new requests, in flight ones carry on
+	stop, cancel := context.WithCancel(ctx)
+
+	jobs := make(chan int)
+
+	go func() {
+		defer close(jobs)
+
+		for index := range names {
+			jobs <- index
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for range min(parallel, len(names)) {
+		wg.Go(func() {
+			for index := range jobs {
+				result := results[index]
+
+				if stop.Err() != nil {
+					result.skipped = true
+					close(result.done)
+
+					continue
+				}
+
+				c := run.Result(names[index])
+				result.request, result.response, result.err = c.request, c.response, c.err
+				result.duration = c.duration.Round(time.Millisecond)
+
+				if c.err == nil {
+					result.assertions = len(c.request.Assertions)
+
+					for _, assertion := range c.request.Assertions {
+						if err := assertion.Check(c.response); err != nil {
+							result.failures = append(result.failures, fmt.Sprintf("%s: %v", assertion.Pos, err))
+						}
+					}
+				}
+
+				if failFast && result.failed() {
+					cancel()
+				}
+
+				close(result.done)
+			}
+		})
+	}
+
+	wait = func() {
+		wg.Wait()
+		cancel()
+	}
+
+	return results, wait
+}
+
+// LSPOptions are the flags passed to the `req lsp` subcommand.
+type LSPOptions struct {
+	Env     string // Name of the environment to use
+	Verbose bool   // Enable debug logs
+}
+
+// LSP implements the `req lsp` subcommand, serving the language server on stdin and
+// stdout until the editor exits.
+func (r Req) LSP(options LSPOptions) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	server := lsp.New(
+		os.Stdin,
+		r.stdout,
+		lsp.WithEnv(options.Env),
+		lsp.WithExecutor(r.executor(options.Env)),
+		lsp.WithLogger(r.logger.Prefixed("lsp")),
+	)
+
+	return server.Serve(ctx)
+}
+
+// executor returns the [lsp.Executor] that sends requests for the language server,
+// with the variables from the named environment.
+//
+// There's no terminal to answer prompts on, so requests that need one can't be sent.
+func (r Req) executor(envName string) lsp.Executor {
+	return func(ctx context.Context, file string, src []byte, name string) (string, error) {
+		ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+
+		logger := r.logger.Prefixed("lsp").With("file", file, "request", name)
+
+		parser, err := parser.New(file, bytes.NewReader(src), nil)
+		if err != nil {
+			return "", err
+		}
+
+		raw, err := parser.Parse()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s is not valid http syntax", err, file)
+		}
+
+		envVars, err := r.environment(file, envName)
+		if err != nil {
+			return "", err
+		}
+
+		run := newRunner(ctx, file, logger)
+		defer run.Close()
+
+		resolveOptions, err := r.resolveOptions(file, envVars, nil, prompt.NewStatic(nil), "")
+		if err != nil {
+			return "", err
+		}
+
+		resolveOptions = append(resolveOptions, spec.WithResponses(run))
+
+		run.resolver, err = spec.NewResolver(raw, resolveOptions...)
+		if err != nil {
+			return "", err
+		}
+
+		result := run.Result(name)
+		if result.err != nil {
+			return "", result.err
+		}
+
+		return fmt.Sprintf(
+			"%s %s (%s)\n\n%s",
+			result.request.Method,
+			result.request.URL,
+			result.duration.Round(time.Millisecond),
+			formatResponse(result.response),
+		), nil
+	}
+}
+
+// writeResponse streams the response body to the file at path, creating any parent
+// directories as needed and returning the path of the file that was written.
+//
+// If unique is true and path already exists, a numeric suffix is added to the filename
+// to find one that does not e.g. "response.json" -> "response-1.json", otherwise the
+// file is overwritten.
+func writeResponse(path string, unique bool, body io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("could not create directory for response file: %w", err)
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if unique {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+
+	for suffix := 1; ; suffix++ {
+		f, err := os.OpenFile(candidate, flag, 0o644)
+		if err != nil {
+			if unique && errors.Is(err, os.ErrExist) {
+				candidate = fmt.Sprintf("%s-%d%s", stem, suffix, ext)
+				continue
+			}
+
+			return "", fmt.Errorf("could not create response file: %w", err)
+		}
+
+		if _, err = io.Copy(f, body); err != nil {
+			f.Close()
+			return "", fmt.Errorf("could not write response file: %w", err)
+		}
+
+		if err = f.Close(); err != nil {
+			return "", fmt.Errorf("could not write response file: %w", err)
+		}
+
+		return candidate, nil
+	}
+}
+
+// environment loads the variables for the named environment from the environment files
+// alongside the .http file. If name is empty, no environment is loaded.
+func (r Req) environment(file, name string) (map[string]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	logger := r.logger.Prefixed("env").With("env", name)
+
+	values, err := env.Load(filepath.Dir(file), name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Values may be secret so only log where they came from
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		logger.Debug("Loaded variable", "name", key, "source", values[key].Source)
+	}
+
+	return env.Strings(values), nil
+}
+
+// resolveOptions returns the [spec.Option]s used to resolve the given .http file.
+//
+// An empty seed means dynamic variables are not seeded, otherwise it must be an unsigned
+// integer, 0 included.
+func (r Req) resolveOptions(
+	file string,
+	envVars, overrides map[string]string,
+	prompter spec.Prompter,
+	seed string,
+) ([]spec.Option, error) {
+	options := []spec.Option{
+		spec.WithDir(filepath.Dir(file)),
+		spec.WithEnv(envVars),
+		spec.WithOverrides(overrides),
+		spec.WithPrompter(prompter),
+		spec.WithErrorHandler(syntax.PrettyConsoleHandler(r.stderr)),
+	}
+
+	if seed != "" {
+		n, err := strconv.ParseUint(seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --seed: %w", err)
+		}
+
+		options = append(options, spec.WithSeed(n))
+	}
+
+	return options, nil
+}
+
+// overrides builds the variable overrides from a variable file and "name=value" pairs,
+// the pairs taking precedence over the file. Either may be empty.
+func (r Req) overrides(file string, pairs []string) (map[string]string, error) {
+	logger := r.logger.Prefixed("vars")
+	overrides := make(map[string]string, len(pairs))
+
+	if file != "" {
+		values, err := vars.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			logger.Debug("Loaded variable", "name", key, "source", file)
+		}
+
+		maps.Copy(overrides, values)
+	}
+
+	values, err := vars.Parse(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		logger.Debug("Loaded variable", "name", key, "source", "--var")
+	}
+
+	maps.Copy(overrides, values)
+
+	return overrides, nil
+}
+
+// prompter returns the [spec.Prompter] used to answer '@prompt' variables, values
+// are given as "name=value" pairs.
+//
+// If stdin is a terminal, the user is asked for any values not provided, otherwise
+// the prompter is non-interactive and a missing value is an error.
+func (r Req) prompter(values []string) (spec.Prompter, error) {
+	parsed, err := prompt.ParseValues(values)
+	if err != nil {
+		return nil, err
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return prompt.NewTerminal(os.Stdin, r.stderr, parsed), nil
+	}
+
+	return prompt.NewStatic(parsed), nil
+}
+
+// newRequest builds the [http.Request] described by request.
+//
+// file is the path to the .http file containing the request, any relative file paths
+// e.g. a '< ./body.json' are resolved relative to the directory it's in.
+//
+// Headers are added in the order they were declared, but net/http decides the order they're
+// written in on the wire, only the values of a repeated header stay in order.
+func newRequest(ctx context.Context, file string, request spec.Request) (*http.Request, error) {
+	if len(request.Parts) > 0 {
+		return newMultipartRequest(ctx, file, request)
+	}
+
+	if request.BodyFile == "" {
+		httpRequest, err := http.NewRequestWithContext(
+			ctx,
+			request.Method,
+			request.URL,
+			bytes.NewReader(request.Body),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, header := range request.Headers {
+			httpRequest.Header.Add(header.Name, header.Value)
+		}
+
+		return httpRequest, nil
+	}
+
+	// The body is to be read from a file, which could be arbitrarily large so rather
+	// than reading it all into memory we stream it straight from disk
+	path := resolvePath(file, request.BodyFile)
+
+	body, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open request body file: %w", err)
+	}
+
+	info, err := body.Stat()
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("could not stat request body file: %w", err)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, request.URL, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+
+	// The http client closes the body once the request is sent, even on error
+	httpRequest.ContentLength = info.Size()
+	if info.Size() == 0 {
+		body.Close()
+		httpRequest.Body = http.NoBody
+	}
+
+	// So that the body can be sent again if we're redirected
+	httpRequest.GetBody = func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	for _, header := range request.Headers {
+		httpRequest.Header.Add(header.Name, header.Value)
+	}
+
+	return httpRequest, nil
+}
+
+// newMultipartRequest builds the [http.Request] for a request with a multipart body.
+//
+// The body is written part by part as it's sent, so files in it are streamed from disk
+// rather than read into memory.
+func newMultipartRequest(ctx context.Context, file string, request spec.Request) (*http.Request, error) {
+	boundary, _ := spec.Boundary(request.Header("Content-Type"))
+
+	// Catch a bad boundary or a missing file now, rather than halfway through sending
+	if err := multipart.NewWriter(io.Discard).SetBoundary(boundary); err != nil {
+		return nil, fmt.Errorf("invalid multipart boundary %q: %w", boundary, err)
+	}
+
+	for _, part := range request.Parts {
+		if part.File == "" {
+			continue
+		}
+
+		if _, err := os.Stat(resolvePath(file, part.File)); err != nil {
+			return nil, fmt.Errorf("could not open multipart file: %w", err)
+		}
+	}
+
+	body := func() io.ReadCloser {
+		reader, writer := io.Pipe()
+
+		go func() {
+			writer.CloseWithError(writeParts(writer, file, boundary, request.Parts))
+		}()
+
+		return reader
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, request.Method, request.URL, body())
+	if err != nil {
+		return nil, err
+	}
+
+	// So that the body can be sent again if we're redirected
+	httpRequest.GetBody = func() (io.ReadCloser, error) {
+		return body(), nil
+	}
+
+	for _, header := range request.Headers {
+		httpRequest.Header.Add(header.Name, header.Value)
+	}
+
+	return httpRequest, nil
+}
+
+// writeParts writes a multipart body to w, separating the parts with boundary. Any
+// files are resolved relative to the directory containing the .http file.
+func writeParts(w io.Writer, file, boundary string, parts []spec.Part) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader, len(part.Headers))
+		for _, h := range part.Headers {
+			header.Add(h.Name, h.Value)
+		}
+
+		content, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		if part.File == "" {
+			if _, err = content.Write(part.Body); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		f, err := os.Open(resolvePath(file, part.File))
+		if err != nil {
+			return fmt.Errorf("could not open multipart file: %w", err)
+		}
+
+		_, err = io.Copy(content, f)
+		f.Close()
+
+		if err != nil {
+			return fmt.Errorf("could not read multipart file: %w", err)
+		}
+	}
+
+	return writer.Close()
+}
+
+// resolvePath resolves path relative to the directory containing the .http file,
+// absolute paths are returned unchanged.
+func resolvePath(file, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(filepath.Dir(file), path)
+}
+
 // construct a HTTP client customised for the request with timeouts, no redirect policies etc.
 func httpClient(request spec.Request) *http.Client {
 	var checkRedirect func(req *http.Request, via []*http.Request) error
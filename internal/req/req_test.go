@@ -1,30 +1,52 @@
 package req_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.followtheprocess.codes/req/internal/req"
+	"go.followtheprocess.codes/req/internal/websocket"
 	"go.followtheprocess.codes/test"
+	"go.uber.org/goleak"
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 )
 
 func TestCheck(t *testing.T) {
 	good := filepath.Join("testdata", "check", "good.http")
 	bad := filepath.Join("testdata", "check", "bad.http")
+	cycle := filepath.Join("testdata", "check", "cycle.http")
+	many := filepath.Join("testdata", "check", "many.http")
+	graphql := filepath.Join("testdata", "check", "graphql.http")
 
 	t.Run("good", func(t *testing.T) {
 		stdout := &bytes.Buffer{}
 		stderr := &bytes.Buffer{}
 
-		app := req.New(stdout, stderr, false)
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
 
 		err := app.Check([]string{good}, req.CheckOptions{})
 		test.Ok(t, err)
@@ -41,7 +63,7 @@ func TestCheck(t *testing.T) {
 		stdout := &bytes.Buffer{}
 		stderr := &bytes.Buffer{}
 
-		app := req.New(stdout, stderr, false)
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
 
 		err := app.Check([]string{bad}, req.CheckOptions{})
 		test.Err(t, err)
@@ -62,9 +84,192 @@ func TestCheck(t *testing.T) {
 			),
 		)
 
+		test.True(t, strings.HasSuffix(err.Error(), "found 1 error"), test.Context("got %q", err.Error()))
+
+		// Stdout should be empty
+		test.Equal(t, stdout.String(), "")
+	})
+
+	t.Run("many", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		err := app.Check([]string{many}, req.CheckOptions{})
+		test.Err(t, err)
+		test.True(t, strings.HasSuffix(err.Error(), "found 3 errors"), test.Context("got %q", err.Error()))
+
+		got := stderr.String()
+
+		// Replace \ with / on windows
+		if runtime.GOOS == "windows" {
+			got = strings.ReplaceAll(got, `\`, "/")
+		}
+
+		// Every error should be reported, in the order they appear in the file
+		want := []string{
+			`testdata/check/many.http:2:14-27: bad timeout value: time: invalid duration "amillionyears"`,
+			`testdata/check/many.http:10:7: expected ':' got ' '`,
+			`testdata/check/many.http:15:4-11: unknown assertion subject "latency", expected status, header or body`,
+		}
+
+		last := -1
+
+		for _, line := range want {
+			index := strings.Index(got, line)
+			test.True(t, index > last, test.Context("%q missing or out of order in:\n%s", line, got))
+
+			last = index
+		}
+
 		// Stdout should be empty
 		test.Equal(t, stdout.String(), "")
 	})
+
+	t.Run("graphql", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		err := app.Check([]string{graphql}, req.CheckOptions{})
+		test.Err(t, err)
+
+		got := stderr.String()
+
+		// Replace \ with / on windows
+		if runtime.GOOS == "windows" {
+			got = strings.ReplaceAll(got, `\`, "/")
+		}
+
+		want := []string{
+			`testdata/check/graphql.http:16:12: bad GraphQL query: expected a value, got ')'`,
+			`testdata/check/graphql.http:24:1: bad GraphQL variables: invalid character '}' looking for beginning of value`,
+		}
+
+		last := -1
+
+		for _, line := range want {
+			index := strings.Index(got, line)
+			test.True(t, index > last, test.Context("%q missing or out of order in:\n%s", line, got))
+
+			last = index
+		}
+
+		test.Equal(t, stdout.String(), "")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		err := app.Check([]string{cycle}, req.CheckOptions{})
+		test.Err(t, err)
+
+		got := stderr.String()
+
+		// Replace \ with / on windows
+		if runtime.GOOS == "windows" {
+			got = strings.ReplaceAll(got, `\`, "/")
+		}
+
+		test.True(
+			t,
+			strings.Contains(
+				got,
+				`testdata/check/cycle.http:9:23-54: dependency cycle: Login -> Refresh -> Login`,
+			),
+			test.Context("unexpected stderr:\n%s", got),
+		)
+
+		test.Equal(t, stdout.String(), "")
+	})
+}
+
+func TestFmt(t *testing.T) {
+	const (
+		messy     = "@base=https://api.com\n###   Get\nGET   {{base}}/items\nAccept:application/json\n"
+		formatted = "@base = https://api.com\n\n### Get\nGET {{base}}/items\nAccept: application/json\n"
+	)
+
+	tests := []struct {
+		name       string         // Name of the test case
+		src        string         // Contents of the file to format
+		want       string         // Expected contents of the file afterwards
+		wantStdout string         // Expected to be in stdout
+		options    req.FmtOptions // Options to format with
+		wantErr    bool           // Whether we want an error
+	}{
+		{
+			name:       "write",
+			src:        messy,
+			options:    req.FmtOptions{},
+			want:       formatted,
+			wantStdout: "Formatted",
+			wantErr:    false,
+		},
+		{
+			name:    "already formatted",
+			src:     formatted,
+			options: req.FmtOptions{},
+			want:    formatted,
+			wantErr: false,
+		},
+		{
+			name:    "check",
+			src:     messy,
+			options: req.FmtOptions{Check: true},
+			want:    messy,
+			wantErr: true,
+		},
+		{
+			name:    "check formatted",
+			src:     formatted,
+			options: req.FmtOptions{Check: true},
+			want:    formatted,
+			wantErr: false,
+		},
+		{
+			name:       "diff",
+			src:        messy,
+			options:    req.FmtOptions{Diff: true},
+			want:       messy,
+			wantStdout: "-@base=https://api.com\n-###   Get\n-GET   {{base}}/items\n-Accept:application/json\n+@base = https://api.com\n+\n+### Get\n",
+			wantErr:    false,
+		},
+		{
+			name:    "invalid",
+			src:     "###\nGET not a url\n",
+			options: req.FmtOptions{},
+			want:    "###\nGET not a url\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "fmt.http")
+			test.Ok(t, os.WriteFile(file, []byte(tt.src), 0o644))
+
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+			err := app.Fmt([]string{file}, tt.options)
+			t.Log(stderr.String())
+			test.WantErr(t, err, tt.wantErr)
+
+			got, err := os.ReadFile(file)
+			test.Ok(t, err)
+
+			test.Diff(t, string(got), tt.want)
+			test.True(t, strings.Contains(stdout.String(), tt.wantStdout), test.Context("missing %q in stdout:\n%s", tt.wantStdout, stdout))
+		})
+	}
 }
 
 func TestShow(t *testing.T) {
@@ -73,7 +278,7 @@ func TestShow(t *testing.T) {
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
 
-	app := req.New(stdout, stderr, false)
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
 
 	err := app.Show(good, req.ShowOptions{})
 	test.Ok(t, err)
@@ -82,6 +287,60 @@ func TestShow(t *testing.T) {
 	test.True(t, strings.Contains(stdout.String(), "### Body"))
 }
 
+func TestShowSeed(t *testing.T) {
+	httpFile := `### Seeded
+GET https://api.com/items
+X-Timestamp: {{$timestamp}}
+`
+
+	file := filepath.Join(t.TempDir(), "seed.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	tests := []struct {
+		name    string // Name of the test case
+		seed    string // The --seed flag
+		want    string // Substring expected in the resolved output
+		errMsg  string // If we wanted an error, what should it say
+		wantErr bool   // Whether we want an error
+	}{
+		{
+			name: "zero",
+			seed: "0",
+			want: "X-Timestamp: 0\n",
+		},
+		{
+			name: "non zero",
+			seed: "42",
+			want: "X-Timestamp: 42\n",
+		},
+		{
+			name:    "negative",
+			seed:    "-1",
+			wantErr: true,
+			errMsg:  `invalid --seed: strconv.ParseUint: parsing "-1": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+			err := app.Show(file, req.ShowOptions{Resolve: true, Seed: tt.seed})
+			test.WantErr(t, err, tt.wantErr)
+
+			if err != nil {
+				test.Equal(t, err.Error(), tt.errMsg)
+				return
+			}
+
+			test.True(t, strings.Contains(stdout.String(), tt.want), test.Context("unexpected output:\n%s", stdout.String()))
+		})
+	}
+}
+
 func TestDo(t *testing.T) {
 	testHandler := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Date", "fixed")
@@ -99,7 +358,7 @@ Accept: application/json
 	stdout := &bytes.Buffer{}
 	stderr := &bytes.Buffer{}
 
-	app := req.New(stdout, stderr, false)
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
 
 	file, err := os.CreateTemp(t.TempDir(), "test*.http")
 	test.Ok(t, err)
@@ -126,3 +385,1655 @@ Date: fixed
 
 	test.Diff(t, stdout.String(), want)
 }
+
+func TestDoBodyFile(t *testing.T) {
+	// Responds with the size and hash of the request body it received
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		hash := sha256.New()
+
+		n, err := io.Copy(hash, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprintf(w, "%d %x", n, hash.Sum(nil))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	large := bytes.Repeat([]byte("abcdefghijklmnopqrstuvwxyz\n"), 1<<18) // ~7MB
+
+	tests := []struct {
+		files   map[string]string // Files to create in the same directory as the .http file
+		name    string            // Name of the test case
+		src     string            // The .http file src, %s will be replaced with the server URL
+		body    string            // The body we expect the server to have received
+		wantErr bool              // Whether we want an error
+	}{
+		{
+			name:  "relative",
+			src:   "###\nPOST %s\n\n< ./body.json\n",
+			files: map[string]string{"body.json": `{"hello": "world"}`},
+			body:  `{"hello": "world"}`,
+		},
+		{
+			name:  "nested",
+			src:   "###\nPOST %s\n\n< ./bodies/body.json\n",
+			files: map[string]string{"bodies/body.json": `{"nested": true}`},
+			body:  `{"nested": true}`,
+		},
+		{
+			name:  "interpolated",
+			src:   "@who = world\n\n###\n# @greeting hello\nPOST %s\n\n<@ ./body.json\n",
+			files: map[string]string{"body.json": `{"{{.Local.greeting}}": "{{.Global.who}}"}`},
+			body:  `{"hello": "world"}`,
+		},
+		{
+			name:  "not interpolated",
+			src:   "###\nPOST %s\n\n< ./body.json\n",
+			files: map[string]string{"body.json": `{"hello": "{{.Global.who}}"}`},
+			body:  `{"hello": "{{.Global.who}}"}`,
+		},
+		{
+			name:  "empty",
+			src:   "###\nPOST %s\n\n< ./body.json\n",
+			files: map[string]string{"body.json": ""},
+			body:  "",
+		},
+		{
+			name:  "large",
+			src:   "###\nPOST %s\n\n< ./large.txt\n",
+			files: map[string]string{"large.txt": string(large)},
+			body:  string(large),
+		},
+		{
+			name:    "missing",
+			src:     "###\nPOST %s\n\n< ./missing.json\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing interpolated",
+			src:     "###\nPOST %s\n\n<@ ./missing.json\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for name, contents := range tt.files {
+				path := filepath.Join(dir, name)
+				test.Ok(t, os.MkdirAll(filepath.Dir(path), 0o755))
+				test.Ok(t, os.WriteFile(path, []byte(contents), 0o644))
+			}
+
+			file := filepath.Join(dir, "test.http")
+			test.Ok(t, os.WriteFile(file, fmt.Appendf(nil, tt.src, server.URL), 0o644))
+
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+			options := req.DoOptions{
+				Timeout:           5 * time.Second,
+				ConnectionTimeout: 500 * time.Millisecond,
+			}
+
+			err := app.Do(file, "#1", options)
+			test.WantErr(t, err, tt.wantErr)
+
+			if tt.wantErr {
+				return
+			}
+
+			want := fmt.Sprintf("%d %x", len(tt.body), sha256.Sum256([]byte(tt.body)))
+			test.True(
+				t,
+				strings.Contains(stdout.String(), want),
+				test.Context("server did not receive the expected body, got:\n%s", stdout.String()),
+			)
+		})
+	}
+}
+
+func TestDoResponseFile(t *testing.T) {
+	tests := []struct {
+		want   map[string]string // Expected files (relative to the .http file dir) and their contents after all runs
+		name   string            // Name of the test case
+		src    string            // The .http file src, %s will be replaced with the server URL
+		output string            // The --output flag, relative to the .http file dir
+		runs   int               // Number of times to run the request
+	}{
+		{
+			name: "redirect",
+			src:  "###\nGET %s\n\n> ./response.json\n",
+			runs: 1,
+			want: map[string]string{"response.json": "response 1"},
+		},
+		{
+			name: "redirect nested",
+			src:  "###\nGET %s\n\n> ./responses/response.json\n",
+			runs: 1,
+			want: map[string]string{"responses/response.json": "response 1"},
+		},
+		{
+			name: "redirect overwrites",
+			src:  "###\nGET %s\n\n> ./response.json\n",
+			runs: 2,
+			want: map[string]string{"response.json": "response 2"},
+		},
+		{
+			name: "redirect force overwrites",
+			src:  "###\nGET %s\n\n>>! ./response.json\n",
+			runs: 2,
+			want: map[string]string{"response.json": "response 2"},
+		},
+		{
+			name: "redirect unique",
+			src:  "###\nGET %s\n\n>> ./response.json\n",
+			runs: 3,
+			want: map[string]string{
+				"response.json":   "response 1",
+				"response-1.json": "response 2",
+				"response-2.json": "response 3",
+			},
+		},
+		{
+			name:   "output flag",
+			src:    "###\nGET %s\n",
+			output: "output.json",
+			runs:   1,
+			want:   map[string]string{"output.json": "response 1"},
+		},
+		{
+			name:   "output flag overrides redirect",
+			src:    "###\nGET %s\n\n>> ./response.json\n",
+			output: "output.json",
+			runs:   2,
+			want:   map[string]string{"output.json": "response 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count atomic.Int64
+
+			testHandler := func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "response %d", count.Add(1))
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(testHandler))
+			defer server.Close()
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "test.http")
+			test.Ok(t, os.WriteFile(file, fmt.Appendf(nil, tt.src, server.URL), 0o644))
+
+			options := req.DoOptions{
+				Timeout:           1 * time.Second,
+				ConnectionTimeout: 500 * time.Millisecond,
+			}
+
+			if tt.output != "" {
+				options.Output = filepath.Join(dir, tt.output)
+			}
+
+			for range tt.runs {
+				stdout := &bytes.Buffer{}
+				stderr := &bytes.Buffer{}
+
+				app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+				test.Ok(t, app.Do(file, "#1", options))
+
+				// The body should not have gone to stdout
+				test.False(t, strings.Contains(stdout.String(), "response "), test.Context("body written to stdout"))
+			}
+
+			entries, err := os.ReadDir(dir)
+			test.Ok(t, err)
+
+			// The .http file + the top level of any wanted files
+			top := make(map[string]bool)
+			for name := range tt.want {
+				top[strings.Split(name, "/")[0]] = true
+			}
+
+			test.Equal(t, len(entries), len(top)+1, test.Context("unexpected number of files in %s", dir))
+
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				test.Ok(t, err)
+				test.Equal(t, string(got), want)
+			}
+		})
+	}
+}
+
+func TestDoMultipart(t *testing.T) {
+	// Parses the upload, responding with each part's form name, filename, content
+	// type and content
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			content, err := io.ReadAll(part)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			fmt.Fprintf(w, "%s %q %s %q\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@owner = thing
+
+### Upload
+POST %s/upload
+Content-Type: multipart/form-data; boundary=WebAppBoundary
+
+--WebAppBoundary
+Content-Disposition: form-data; name="owner"
+
+{{owner}}
+--WebAppBoundary
+Content-Disposition: form-data; name="photo"; filename="photo.png"
+Content-Type: image/png
+
+< ./photo.png
+--WebAppBoundary
+Content-Disposition: form-data; name="meta"; filename="meta.json"
+Content-Type: application/json
+
+<@ ./meta.json
+--WebAppBoundary--
+`, server.URL)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "upload.http")
+
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+	test.Ok(t, os.WriteFile(filepath.Join(dir, "photo.png"), []byte("\x89PNG\r\n\x1a\nnot really"), 0o644))
+	test.Ok(t, os.WriteFile(filepath.Join(dir, "meta.json"), []byte(`{"owner": "{{owner}}"}`), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	err := app.Do(file, "#1", options)
+	t.Log(stderr.String())
+	test.Ok(t, err)
+
+	want := `owner ""  "thing"
+photo "photo.png" image/png "\x89PNG\r\n\x1a\nnot really"
+meta "meta.json" application/json "{\"owner\": \"thing\"}"
+`
+
+	test.True(t, strings.Contains(stdout.String(), want), test.Context("unexpected response:\n%s", stdout.String()))
+
+	t.Run("missing file", func(t *testing.T) {
+		test.Ok(t, os.Remove(filepath.Join(dir, "photo.png")))
+
+		err := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false).Do(file, "#1", options)
+		test.Err(t, err)
+		test.True(t, strings.Contains(err.Error(), "photo.png"), test.Context("unexpected error: %v", err))
+	})
+}
+
+func TestDoForm(t *testing.T) {
+	// Parses the form, responding with the content type then each field's value
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintln(w, r.Header.Get("Content-Type"))
+
+		for _, key := range slices.Sorted(maps.Keys(r.PostForm)) {
+			fmt.Fprintf(w, "%s %q\n", key, r.PostForm[key])
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@password = s&cret=1+2%%
+
+### Log in
+POST %s/login
+Content-Type: application/x-www-form-urlencoded
+
+name=foo+bar&discount=100%%25
+&password={{password}}
+&tag=one
+&tag=two
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "login.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	err := app.Do(file, "#1", options)
+	t.Log(stderr.String())
+	test.Ok(t, err)
+
+	want := `application/x-www-form-urlencoded
+discount ["100%"]
+name ["foo bar"]
+password ["s&cret=1+2%"]
+tag ["one" "two"]
+`
+
+	test.True(t, strings.Contains(stdout.String(), want), test.Context("unexpected response:\n%s", stdout.String()))
+}
+
+func TestDoGraphQL(t *testing.T) {
+	// Echoes the query and variables back as data, unless asked for something that
+	// doesn't exist
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]any `json:"variables"`
+			Query     string         `json:"query"`
+		}
+
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Request-Type") != "" {
+			http.Error(w, "not a GraphQL request", http.StatusBadRequest)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Date", "fixed")
+
+		if strings.Contains(request.Query, "missing") {
+			fmt.Fprint(w, `{"data": null, "errors": [{"message": "Cannot query field \"missing\"", "locations": [{"line": 1, "column": 3}]}]}`)
+			return
+		}
+
+		response := map[string]any{"data": map[string]any{"query": request.Query, "variables": request.Variables}}
+		test.Ok(t, json.NewEncoder(w).Encode(response))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@id = 123
+
+### Header
+POST %[1]s/graphql
+X-Request-Type: GraphQL
+
+query User($id: ID!) {
+  user(id: $id) { name }
+}
+
+{"id": "{{id}}"}
+
+### Method
+GRAPHQL %[1]s/graphql
+
+{ missing }
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "graphql.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	t.Run("data", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		err := req.New(&bytes.Buffer{}, stdout, stderr, false).Do(file, "#1", options)
+		test.Ok(t, err)
+
+		want := `{
+  "query": "query User($id: ID!) {\n  user(id: $id) { name }\n}",
+  "variables": {
+    "id": "123"
+  }
+}
+`
+
+		test.True(t, strings.HasSuffix(stdout.String(), want), test.Context("unexpected response:\n%s", stdout.String()))
+		test.Equal(t, stderr.String(), "")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		err := req.New(&bytes.Buffer{}, stdout, stderr, false).Do(file, "#2", options)
+		test.Ok(t, err)
+
+		test.True(t, strings.HasSuffix(stdout.String(), "Date: fixed\n\n"), test.Context("unexpected response:\n%s", stdout.String()))
+		test.True(
+			t,
+			strings.Contains(stderr.String(), `Cannot query field "missing" (line 1:3)`),
+			test.Context("unexpected stderr:\n%s", stderr.String()),
+		)
+	})
+}
+
+func TestDoWebSocket(t *testing.T) {
+	// Echoes every message back, twice for "twice", says nothing to "silent" and
+	// closes the connection on "bye"
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			message, err := conn.Read()
+			if err != nil {
+				return
+			}
+
+			switch text := string(message.Data); text {
+			case "silent":
+			case "bye":
+				return
+			case "twice":
+				test.Ok(t, conn.Write(websocket.Text, []byte("echo: "+text)))
+				test.Ok(t, conn.Write(websocket.Text, []byte("echo: "+text)))
+			default:
+				test.Ok(t, conn.Write(websocket.Text, []byte("echo: "+text)))
+			}
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@base = ws%s
+
+### Script
+WEBSOCKET {{base}}/ws
+
+===
+hello
+=== wait-for-server
+twice
+=== wait-for-server
+=== wait-for-server
+
+### Interactive
+WEBSOCKET {{base}}/ws
+
+### Closed
+WEBSOCKET {{base}}/ws
+
+===
+bye
+=== wait-for-server
+
+### Silent
+# @timeout = 100ms
+WEBSOCKET {{base}}/ws
+
+===
+silent
+=== wait-for-server
+`, strings.TrimPrefix(server.URL, "http"))
+
+	file := filepath.Join(t.TempDir(), "websocket.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	// Every message is printed with the time it was sent or received
+	timestamp := regexp.MustCompile(`^\d{2}:\d{2}:\d{2}\.\d{3} `)
+
+	// messages returns the messages printed after the response head, without their timestamps
+	messages := func(t *testing.T, stdout string) []string {
+		t.Helper()
+
+		status, rest, ok := strings.Cut(stdout, "\n")
+		test.True(t, ok)
+		test.Equal(t, status, "101 Switching Protocols")
+
+		_, rest, ok = strings.Cut(rest, "\n\n")
+		test.True(t, ok, test.Context("no blank line after the headers:\n%s", stdout))
+
+		lines := strings.Split(strings.TrimSpace(rest), "\n")
+		for i, line := range lines {
+			test.True(t, timestamp.MatchString(line), test.Context("line %q has no timestamp", line))
+			lines[i] = timestamp.ReplaceAllString(line, "")
+		}
+
+		return lines
+	}
+
+	t.Run("script", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		err := req.New(&bytes.Buffer{}, stdout, stderr, false).Do(file, "#1", options)
+		test.Ok(t, err)
+
+		want := "> hello\n< echo: hello\n> twice\n< echo: twice\n< echo: twice"
+
+		test.Diff(t, strings.Join(messages(t, stdout.String()), "\n"), want)
+		test.Equal(t, stderr.String(), "")
+	})
+
+	t.Run("interactive", func(t *testing.T) {
+		stdin := strings.NewReader("one\n\ntwo\n")
+		stdout := &bytes.Buffer{}
+
+		err := req.New(stdin, stdout, &bytes.Buffer{}, false).Do(file, "#2", options)
+		test.Ok(t, err)
+
+		// Replies may come before or after the next line is sent, but every one is printed
+		// before the connection is closed
+		got := messages(t, stdout.String())
+		slices.Sort(got)
+
+		want := "< echo: one\n< echo: two\n> one\n> two"
+
+		test.Diff(t, strings.Join(got, "\n"), want)
+	})
+
+	t.Run("closed by server", func(t *testing.T) {
+		err := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false).Do(file, "#3", options)
+		test.Err(t, err)
+		test.Equal(t, err.Error(), "the server closed the connection before the script finished")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		err := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false).Do(file, "#4", options)
+		test.Err(t, err)
+		test.Equal(t, err.Error(), "timed out after 100ms waiting for a message from the server")
+	})
+
+	t.Run("run", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := req.New(&bytes.Buffer{}, stdout, &bytes.Buffer{}, false).Run(file, req.RunOptions{Filter: "#1"})
+		test.Err(t, err)
+
+		want := "WebSocket request #1 can only be sent with 'req do'"
+		test.True(t, strings.Contains(stdout.String(), want), test.Context("unexpected output:\n%s", stdout.String()))
+	})
+}
+
+func TestDoGRPC(t *testing.T) {
+	// Two servers, only one with reflection, both add a trailer to every call
+	serve := func(reflect bool) string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		test.Ok(t, err)
+
+		trailer := func(ctx context.Context, req any, _ *grpcgo.UnaryServerInfo, handler grpcgo.UnaryHandler) (any, error) {
+			test.Ok(t, grpcgo.SetTrailer(ctx, metadata.Pairs("x-served-by", "test")))
+			return handler(ctx, req)
+		}
+
+		server := grpcgo.NewServer(grpcgo.UnaryInterceptor(trailer))
+
+		checker := health.NewServer()
+		checker.SetServingStatus("api", healthpb.HealthCheckResponse_SERVING)
+		healthpb.RegisterHealthServer(server, checker)
+
+		if reflect {
+			reflection.Register(server)
+		}
+
+		go server.Serve(listener) //nolint:errcheck // Returns when the server is stopped
+
+		t.Cleanup(server.Stop)
+
+		return listener.Addr().String()
+	}
+
+	httpFile := fmt.Sprintf(`### Reflection
+GRPC %s/grpc.health.v1.Health/Check
+
+{"service": "api"}
+
+### Proto file
+# @proto = protos/health.proto
+GRPC grpc://%s/grpc.health.v1.Health/Check
+
+{"service": "api"}
+
+### Unknown service
+GRPC %[1]s/grpc.health.v1.Health/Check
+
+{"service": "missing"}
+`, serve(true), serve(false))
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "grpc.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	// The .proto file is relative to the .http file
+	proto, err := os.ReadFile(filepath.Join("..", "grpc", "testdata", "health.proto"))
+	test.Ok(t, err)
+	test.Ok(t, os.MkdirAll(filepath.Join(dir, "protos"), 0o755))
+	test.Ok(t, os.WriteFile(filepath.Join(dir, "protos", "health.proto"), proto, 0o644))
+
+	options := req.DoOptions{
+		Timeout:           5 * time.Second,
+		ConnectionTimeout: 1 * time.Second,
+	}
+
+	serving := "0 OK\ncontent-type: application/grpc\n\n{\n  \"status\": \"SERVING\"\n}\n\nx-served-by: test\n"
+
+	tests := []struct {
+		name    string // Name of the test case
+		request string // The request to send
+		want    string // The expected stdout
+	}{
+		{
+			name:    "reflection",
+			request: "#1",
+			want:    serving,
+		},
+		{
+			name:    "proto file",
+			request: "#2",
+			want:    serving,
+		},
+		{
+			name:    "error status",
+			request: "#3",
+			want:    "5 NotFound: unknown service\n\ncontent-type: application/grpc\nx-served-by: test\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			err := req.New(&bytes.Buffer{}, stdout, stderr, false).Do(file, tt.request, options)
+			test.Ok(t, err)
+
+			test.Diff(t, stdout.String(), tt.want)
+			test.Equal(t, stderr.String(), "")
+		})
+	}
+
+	t.Run("run", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := req.New(&bytes.Buffer{}, stdout, &bytes.Buffer{}, false).Run(file, req.RunOptions{Filter: "#1"})
+		test.Err(t, err)
+
+		want := "gRPC request #1 can only be sent with 'req do'"
+		test.True(t, strings.Contains(stdout.String(), want), test.Context("unexpected output:\n%s", stdout.String()))
+	})
+}
+
+func TestDoStream(t *testing.T) {
+	tests := []struct {
+		name        string // Name of the test case
+		contentType string // The Content-Type of the response
+		first       string // The first part of the response
+		second      string // The rest of the response, sent once the first has been printed
+		wantFirst   string // The output once the first part has been sent
+		wantSecond  string // The output after the second part
+		stream      bool   // Whether to pass --stream
+	}{
+		{
+			name:        "server-sent events",
+			contentType: "text/event-stream",
+			first:       ": connected\nevent: delta\nid: 1\ndata: Hel\n\n",
+			second:      "data: lo\ndata: world\n\n",
+			wantFirst:   "event: delta\nid: 1\ndata: Hel\n\n",
+			wantSecond:  "event: message\nid: 1\ndata: lo\ndata: world\n\n",
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			first:       `{"delta": "Hel"}` + "\n",
+			second:      `{"delta": "lo"}` + "\n",
+			wantFirst:   `{"delta": "Hel"}` + "\n",
+			wantSecond:  `{"delta": "lo"}` + "\n",
+		},
+		{
+			name:        "stream flag",
+			contentType: "text/plain",
+			first:       "Hel",
+			second:      "lo",
+			wantFirst:   "Hel",
+			wantSecond:  "lo\n",
+			stream:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The server sends the first part then waits for the test to have seen it printed
+			// before sending the rest. If the response weren't streamed, nothing would be
+			// printed until it gave up waiting and the second part would be missing.
+			next := make(chan struct{})
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, tt.first)
+				test.Ok(t, http.NewResponseController(w).Flush())
+
+				select {
+				case <-next:
+					fmt.Fprint(w, tt.second)
+				case <-time.After(5 * time.Second):
+				}
+			}))
+			defer server.Close()
+
+			file := filepath.Join(t.TempDir(), "stream.http")
+			test.Ok(t, os.WriteFile(file, []byte("### Stream\nGET "+server.URL+"\n"), 0o644))
+
+			stdout, w := io.Pipe()
+			errs := make(chan error, 1)
+
+			go func() {
+				options := req.DoOptions{Timeout: 10 * time.Second, ConnectionTimeout: time.Second, Stream: tt.stream}
+				errs <- req.New(&bytes.Buffer{}, w, &bytes.Buffer{}, false).Do(file, "#1", options)
+				w.Close()
+			}()
+
+			// Skip the status and headers
+			reader := bufio.NewReader(stdout)
+			for {
+				line, err := reader.ReadString('\n')
+				test.Ok(t, err)
+
+				if line == "\n" {
+					break
+				}
+			}
+
+			first := make([]byte, len(tt.wantFirst))
+			_, err := io.ReadFull(reader, first)
+			test.Ok(t, err)
+			test.Diff(t, string(first), tt.wantFirst)
+
+			close(next)
+
+			rest, err := io.ReadAll(reader)
+			test.Ok(t, err)
+			test.Diff(t, string(rest), tt.wantSecond)
+
+			test.Ok(t, <-errs)
+		})
+	}
+}
+
+func TestDoStreamTimeout(t *testing.T) {
+	tests := []struct {
+		name        string // Name of the test case
+		contentType string // The Content-Type of the response
+		want        string // What should be printed of the body
+		wantErr     bool   // Whether the request should time out
+	}{
+		{
+			name:        "streamed",
+			contentType: "text/event-stream",
+			want:        "event: message\ndata: one\n\nevent: message\ndata: two\n\n",
+		},
+		{
+			name:        "not streamed",
+			contentType: "text/plain",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The headers arrive straight away but the body takes longer than the timeouts
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, "data: one\n\n")
+				test.Ok(t, http.NewResponseController(w).Flush())
+
+				time.Sleep(time.Second)
+				fmt.Fprint(w, "data: two\n\n")
+			}))
+			defer server.Close()
+
+			file := filepath.Join(t.TempDir(), "stream.http")
+			test.Ok(t, os.WriteFile(file, []byte("### Stream\n# @timeout = 300ms\nGET "+server.URL+"\n"), 0o644))
+
+			stdout := &bytes.Buffer{}
+			options := req.DoOptions{Timeout: 300 * time.Millisecond, ConnectionTimeout: 100 * time.Millisecond}
+
+			err := req.New(&bytes.Buffer{}, stdout, &bytes.Buffer{}, false).Do(file, "#1", options)
+			test.WantErr(t, err, tt.wantErr)
+
+			if err != nil {
+				test.True(t, errors.Is(err, context.DeadlineExceeded), test.Context("wrong error: %v", err))
+				return
+			}
+
+			test.True(t, strings.HasSuffix(stdout.String(), tt.want), test.Context("unexpected output:\n%s", stdout.String()))
+		})
+	}
+}
+
+func TestDoResponseReference(t *testing.T) {
+	var count atomic.Int64
+
+	// The count changes every time, the rest doesn't
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": "thing", "count": %d}`, count.Add(1))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.http")
+	reference := filepath.Join(dir, "previous.json")
+
+	test.Ok(t, os.WriteFile(file, fmt.Appendf(nil, "###\nGET %s\n\n<> ./previous.json\n", server.URL), 0o644))
+
+	// do sends the request, returning what it printed
+	do := func(failOnDiff bool) (string, error) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		options := req.DoOptions{
+			Timeout:           1 * time.Second,
+			ConnectionTimeout: 500 * time.Millisecond,
+			FailOnDiff:        failOnDiff,
+		}
+
+		err := req.New(&bytes.Buffer{}, stdout, stderr, false).Do(file, "#1", options)
+
+		return stdout.String(), err
+	}
+
+	// The first run just saves the response
+	stdout, err := do(false)
+	test.Ok(t, err)
+	test.True(t, strings.Contains(stdout, "Response saved to "+reference), test.Context("unexpected output:\n%s", stdout))
+
+	saved, err := os.ReadFile(reference)
+	test.Ok(t, err)
+	test.Equal(t, string(saved), `{"name": "thing", "count": 1}`)
+
+	// The next shows what changed and saves the new one
+	stdout, err = do(false)
+	test.Ok(t, err)
+	test.True(t, strings.Contains(stdout, "~ $.count: 1 => 2\n"), test.Context("unexpected output:\n%s", stdout))
+
+	saved, err = os.ReadFile(reference)
+	test.Ok(t, err)
+	test.Equal(t, string(saved), `{"name": "thing", "count": 2}`)
+
+	// With --fail-on-diff, it's an error and the saved response is left alone
+	stdout, err = do(true)
+	test.Err(t, err)
+	test.True(t, strings.Contains(stdout, "~ $.count: 2 => 3\n"), test.Context("unexpected output:\n%s", stdout))
+
+	saved, err = os.ReadFile(reference)
+	test.Ok(t, err)
+	test.Equal(t, string(saved), `{"name": "thing", "count": 2}`)
+}
+
+func TestDoPrompts(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.Header.Get("Authorization"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@prompt token The API token
+
+### Get a user
+# @name GetUser
+# @prompt id The user ID
+GET %s/users/{{.Local.id}}
+Authorization: Bearer {{.Global.token}}
+
+### Get an item, shouldn't be prompted for this one
+# @name GetItem
+# @prompt item
+GET %s/items/{{.Local.item}}
+`, server.URL, server.URL)
+
+	file := filepath.Join(t.TempDir(), "test.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	t.Run("flags and env", func(t *testing.T) {
+		t.Setenv("REQ_PROMPT_TOKEN", "secret")
+
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		options := req.DoOptions{
+			Timeout:           1 * time.Second,
+			ConnectionTimeout: 500 * time.Millisecond,
+			Prompts:           []string{"id=123"},
+		}
+
+		err := app.Do(file, "GetUser", options)
+		test.Ok(t, err)
+
+		test.True(
+			t,
+			strings.Contains(stdout.String(), "/users/123 Bearer secret"),
+			test.Context("unexpected response:\n%s", stdout.String()),
+		)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		options := req.DoOptions{
+			Timeout:           1 * time.Second,
+			ConnectionTimeout: 500 * time.Millisecond,
+			Prompts:           []string{"id=123"},
+		}
+
+		// No value for token, and stdin is not a terminal so can't ask
+		err := app.Do(file, "GetUser", options)
+		test.Err(t, err)
+	})
+}
+
+func TestDoEnv(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.Header.Get("Authorization"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := `### Get a user
+# @name GetUser
+GET {{.Global.base}}/{{.Global.version}}/users/1
+Authorization: Bearer {{.Global.token}}
+`
+
+	public := fmt.Sprintf(`{
+  "$shared": {"version": "v1"},
+  "dev": {"base": %q, "token": "placeholder"},
+  "prod": {"base": "https://api.com"}
+}`, server.URL)
+
+	private := `{"dev": {"token": "secret"}}`
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+	test.Ok(t, os.WriteFile(filepath.Join(dir, "http-client.env.json"), []byte(public), 0o644))
+	test.Ok(t, os.WriteFile(filepath.Join(dir, "http-client.private.env.json"), []byte(private), 0o644))
+
+	t.Run("dev", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, true)
+
+		options := req.DoOptions{
+			Env:               "dev",
+			Timeout:           1 * time.Second,
+			ConnectionTimeout: 500 * time.Millisecond,
+		}
+
+		err := app.Do(file, "GetUser", options)
+		test.Ok(t, err)
+
+		test.True(
+			t,
+			strings.Contains(stdout.String(), "/v1/users/1 Bearer secret"),
+			test.Context("unexpected response:\n%s", stdout.String()),
+		)
+
+		// Debug logs should say where each variable came from
+		test.True(
+			t,
+			strings.Contains(stderr.String(), "http-client.private.env.json"),
+			test.Context("source not logged:\n%s", stderr.String()),
+		)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+		options := req.DoOptions{
+			Env:               "staging",
+			Timeout:           1 * time.Second,
+			ConnectionTimeout: 500 * time.Millisecond,
+		}
+
+		err := app.Do(file, "GetUser", options)
+		test.Err(t, err)
+	})
+}
+
+func TestDoVars(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.Header.Get("Authorization"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := `@token = placeholder
+@base = https://api.com
+
+### Get a user
+# @name GetUser
+# @id 1
+GET {{.Global.base}}/users/{{.Local.id}}
+Authorization: Bearer {{.Global.token}}
+`
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.http")
+	varFile := filepath.Join(dir, "ci.env")
+
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+	test.Ok(t, os.WriteFile(varFile, []byte(fmt.Sprintf("base=%s\ntoken=from-file\nid=2\n", server.URL)), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	options := req.DoOptions{
+		VarFile:           varFile,
+		Vars:              []string{"token=from-flag"},
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	err := app.Do(file, "GetUser", options)
+	t.Log(stderr.String())
+	test.Ok(t, err)
+
+	// --var beats --var-file, which beats everything in the file
+	test.True(
+		t,
+		strings.Contains(stdout.String(), "/users/2 Bearer from-flag"),
+		test.Context("unexpected response:\n%s", stdout.String()),
+	)
+}
+
+func TestDoHeaders(t *testing.T) {
+	// Only the order of values within a single header name is checked, net/http sorts
+	// different names (HTTP/1) or sends them in map order (HTTP/2) so theirs can't be.
+	//
+	// Echoes back every value of the headers it cares about, in the order received
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%q %q", r.Header.Values("Cookie"), r.Header.Values("Accept"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`@theme = dark
+
+### Repeated headers
+GET %s
+Cookie: session=123
+Accept: application/json
+Cookie: theme={{.Global.theme}}
+Accept: text/plain
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "headers.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	err := app.Do(file, "#1", options)
+	t.Log(stderr.String())
+	test.Ok(t, err)
+
+	test.True(
+		t,
+		strings.Contains(stdout.String(), `["session=123" "theme=dark"] ["application/json" "text/plain"]`),
+		test.Context("unexpected response:\n%s", stdout.String()),
+	)
+}
+
+func TestDoChain(t *testing.T) {
+	var logins atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Session", "abc")
+		fmt.Fprint(w, `{"token": "s3cr3t", "user": {"id": 42}}`)
+	})
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.PathValue("id"), r.Header.Get("Authorization"), r.Header.Get("X-Session"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`### Log in
+# @name Login
+POST %[1]s/login
+
+### Get the user that logged in
+# @name GetUser
+GET %[1]s/users/{{Login.response.body.$.user.id}}
+Authorization: Bearer {{Login.response.body.$.token}}
+X-Session: {{Login.response.headers.X-Session}}
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "chain.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	options := req.DoOptions{
+		Timeout:           1 * time.Second,
+		ConnectionTimeout: 500 * time.Millisecond,
+	}
+
+	err := app.Do(file, "GetUser", options)
+	t.Log(stderr.String())
+	test.Ok(t, err)
+
+	test.True(
+		t,
+		strings.Contains(stdout.String(), "42 Bearer s3cr3t abc"),
+		test.Context("unexpected response:\n%s", stdout.String()),
+	)
+
+	// The dependency is only sent once, no matter how many times it's referenced
+	test.Equal(t, logins.Load(), 1)
+}
+
+func TestRun(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		fmt.Fprint(w, `{"token": "s3cr3t"}`)
+	})
+	mux.HandleFunc("GET /me", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+	mux.HandleFunc("GET /broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`### Log in
+# @name Login
+POST %[1]s/login
+
+### Uses the session cookie and token from Login
+# @name Me
+GET %[1]s/me
+Authorization: Bearer {{Login.response.body.$.token}}
+
+### Always fails
+# @name Broken
+GET %[1]s/broken
+
+### After the failure
+# @name After
+GET %[1]s/me
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "run.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	tests := []struct {
+		name    string         // Name of the test case
+		options req.RunOptions // Options to run with
+		want    []string       // Lines expected in stdout
+		wantErr bool           // Whether we want an error
+	}{
+		{
+			name:    "all",
+			options: req.RunOptions{},
+			want: []string{
+				"PASS Login: 200 OK",
+				"PASS Me: 200 OK",
+				"FAIL Broken: 500 Internal Server Error",
+				"PASS After: 200 OK",
+				"3 passed, 1 failed, 0 skipped",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "fail fast",
+			options: req.RunOptions{FailFast: true},
+			want: []string{
+				"PASS Login: 200 OK",
+				"PASS Me: 200 OK",
+				"FAIL Broken: 500 Internal Server Error",
+				"2 passed, 1 failed, 1 skipped",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "filter",
+			options: req.RunOptions{Filter: "^Me$"},
+			want: []string{
+				"PASS Me: 200 OK",
+				"1 passed, 0 failed, 0 skipped",
+			},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+			err := app.Run(file, tt.options)
+			t.Log(stderr.String())
+			test.WantErr(t, err, tt.wantErr)
+
+			got := stdout.String()
+			for _, line := range tt.want {
+				test.True(t, strings.Contains(got, line), test.Context("missing %q in output:\n%s", line, got))
+			}
+
+			// Only the requests we asked for get a line in the summary
+			if tt.options.Filter != "" {
+				test.False(t, strings.Contains(got, "Login"), test.Context("dependency reported in output:\n%s", got))
+			}
+		})
+	}
+
+	t.Run("bad filter", func(t *testing.T) {
+		app := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false)
+		test.Err(t, app.Run(file, req.RunOptions{Filter: "("}))
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		app := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false)
+		test.Err(t, app.Run(file, req.RunOptions{Filter: "Nope"}))
+	})
+}
+
+func TestRunAssertions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"items": [1, 2, 3]}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`### All pass
+# @name Items
+GET %[1]s/items
+
+?? status == 200
+?? header Content-Type contains json
+?? body $.items.length > 0
+
+### An expected error status passes
+# @name Missing
+GET %[1]s/missing
+
+?? status == 404
+
+### Failing assertions
+# @name Wrong
+GET %[1]s/items
+
+?? status == 201
+?? body $.items.length == 3
+?? body $.total exists
+`, server.URL)
+
+	file := filepath.Join(t.TempDir(), "assertions.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+	err := app.Run(file, req.RunOptions{})
+	t.Log(stderr.String())
+	test.Err(t, err)
+
+	got := stdout.String()
+
+	want := []string{
+		"PASS Items: 200 OK",
+		"PASS Missing: 404 Not Found",
+		"FAIL Wrong: 200 OK",
+		`assertions.http:19:1-17: expected status == 201, got "200"`,
+		"assertions.http:21:1-23: expected body $.total to exist",
+		"2 passed, 1 failed, 0 skipped",
+	}
+
+	for _, line := range want {
+		test.True(t, strings.Contains(got, line), test.Context("missing %q in output:\n%s", line, got))
+	}
+
+	test.False(t, strings.Contains(got, "$.items.length == 3"), test.Context("passing assertion reported:\n%s", got))
+}
+
+func TestRunReport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	mux.HandleFunc("GET /broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "oh no")
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	httpFile := fmt.Sprintf(`### Passes
+# @name OK
+GET %[1]s/ok
+
+### Fails an assertion
+# @name Wrong
+GET %[1]s/ok
+
+?? body == nope
+
+### Error status
+# @name Broken
+GET %[1]s/broken
+
+### Can't be sent
+# @name Unreachable
+GET http://127.0.0.1:0/nothing
+`, server.URL)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "report.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	junitPath := filepath.Join(dir, "reports", "junit.xml")
+	jsonPath := filepath.Join(dir, "reports", "report.json")
+
+	app := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false)
+
+	err := app.Run(file, req.RunOptions{Reports: []string{"junit=" + junitPath, "json=" + jsonPath}})
+	test.Err(t, err)
+
+	t.Run("json", func(t *testing.T) {
+		contents, err := os.ReadFile(jsonPath)
+		test.Ok(t, err)
+
+		type reportCase struct {
+			Name       string   `json:"name"`
+			Result     string   `json:"result"`
+			Error      string   `json:"error"`
+			Request    string   `json:"request"`
+			Response   string   `json:"response"`
+			Failures   []string `json:"failures"`
+			StatusCode int      `json:"statusCode"`
+		}
+
+		var report struct {
+			File    string       `json:"file"`
+			Cases   []reportCase `json:"cases"`
+			Passed  int          `json:"passed"`
+			Failed  int          `json:"failed"`
+			Skipped int          `json:"skipped"`
+		}
+
+		test.Ok(t, json.Unmarshal(contents, &report))
+
+		test.Equal(t, report.File, file)
+		test.Equal(t, report.Passed, 1)
+		test.Equal(t, report.Failed, 3)
+		test.Equal(t, report.Skipped, 0)
+		test.Equal(t, len(report.Cases), 4)
+
+		ok, wrong, broken, unreachable := report.Cases[0], report.Cases[1], report.Cases[2], report.Cases[3]
+
+		test.Equal(t, ok.Result, "passed")
+		test.Equal(t, ok.StatusCode, http.StatusOK)
+		test.Equal(t, ok.Request, "") // Only captured on failure
+
+		test.Equal(t, wrong.Result, "failed")
+		test.Equal(t, len(wrong.Failures), 1)
+		test.True(t, strings.Contains(wrong.Request, "GET "+server.URL+"/ok"), test.Context("request: %s", wrong.Request))
+		test.True(t, strings.HasPrefix(wrong.Response, "HTTP 200 OK\n"), test.Context("response: %s", wrong.Response))
+
+		test.Equal(t, broken.Result, "failed")
+		test.Equal(t, broken.StatusCode, http.StatusInternalServerError)
+		test.True(t, strings.HasSuffix(broken.Response, "\n\noh no"), test.Context("response: %s", broken.Response))
+
+		test.Equal(t, unreachable.Result, "failed")
+		test.True(t, unreachable.Error != "", test.Context("no error for unreachable request"))
+		test.Equal(t, unreachable.Response, "")
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		contents, err := os.ReadFile(junitPath)
+		test.Ok(t, err)
+
+		type problem struct {
+			Message string `xml:"message,attr"`
+		}
+
+		var report struct {
+			Suites []struct {
+				Cases []struct {
+					Failure *problem `xml:"failure"`
+					Error   *problem `xml:"error"`
+					Name    string   `xml:"name,attr"`
+				} `xml:"testcase"`
+				Tests    int `xml:"tests,attr"`
+				Failures int `xml:"failures,attr"`
+				Errors   int `xml:"errors,attr"`
+			} `xml:"testsuite"`
+		}
+
+		test.Ok(t, xml.Unmarshal(contents, &report))
+
+		test.Equal(t, len(report.Suites), 1)
+
+		suite := report.Suites[0]
+		test.Equal(t, suite.Tests, 4)
+		test.Equal(t, suite.Failures, 2)
+		test.Equal(t, suite.Errors, 1)
+
+		test.Equal(t, suite.Cases[0].Name, "OK")
+		test.True(t, suite.Cases[0].Failure == nil, test.Context("OK has a failure"))
+		test.Equal(t, suite.Cases[1].Failure.Message, "1 of the assertions failed")
+		test.Equal(t, suite.Cases[2].Failure.Message, "500 Internal Server Error")
+		test.True(t, suite.Cases[3].Error != nil, test.Context("Unreachable has no error"))
+	})
+
+	t.Run("bad report", func(t *testing.T) {
+		app := req.New(&bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}, false)
+		test.Err(t, app.Run(file, req.RunOptions{Reports: []string{"html=report.html"}}))
+		test.Err(t, app.Run(file, req.RunOptions{Reports: []string{"junit"}}))
+	})
+}
+
+func TestRunParallel(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+
+		// Earlier requests take longer, so they finish last
+		delay, err := time.ParseDuration(r.PathValue("delay"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		time.Sleep(delay)
+		fmt.Fprint(w, "ok")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sleep/{delay}", testHandler)
+
+	httpFile := `### First
+# @name First
+GET {{base}}/sleep/150ms
+
+### Second
+# @name Second
+GET {{base}}/sleep/100ms
+
+### Third
+# @name Third
+GET {{base}}/sleep/50ms
+
+### Fourth
+# @name Fourth
+GET {{base}}/sleep/0s
+`
+
+	file := filepath.Join(t.TempDir(), "parallel.http")
+	test.Ok(t, os.WriteFile(file, []byte(httpFile), 0o644))
+
+	tests := []struct {
+		name     string // Name of the test case
+		parallel int    // The --parallel flag
+	}{
+		{name: "sequential", parallel: 1},
+		{name: "two", parallel: 2},
+		{name: "more than requests", parallel: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t)
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			maxInFlight.Store(0)
+
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			app := req.New(&bytes.Buffer{}, stdout, stderr, false)
+
+			options := req.RunOptions{
+				Vars:     []string{"base=" + server.URL},
+				Parallel: tt.parallel,
+			}
+
+			err := app.Run(file, options)
+			t.Log(stderr.String())
+			test.Ok(t, err)
+
+			// Results are always reported in file order
+			var names []string
+
+			for line := range strings.Lines(stdout.String()) {
+				if name, ok := strings.CutPrefix(line, "PASS "); ok {
+					names = append(names, name[:strings.Index(name, ":")])
+				}
+			}
+
+			test.EqualFunc(t, names, []string{"First", "Second", "Third", "Fourth"}, slices.Equal)
+			test.True(
+				t,
+				strings.Contains(stdout.String(), "4 passed, 0 failed, 0 skipped"),
+				test.Context("unexpected output:\n%s", stdout.String()),
+			)
+
+			// Never more than the limit at once, but more than one if allowed
+			limit := int32(min(tt.parallel, 4))
+			test.True(t, maxInFlight.Load() <= limit, test.Context("%d requests in flight, limit was %d", maxInFlight.Load(), limit))
+
+			if limit > 1 {
+				test.True(t, maxInFlight.Load() > 1, test.Context("requests were not sent concurrently"))
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package req
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.followtheprocess.codes/log"
+	"go.followtheprocess.codes/req/internal/spec"
+)
+
+// runner sends the requests in a single .http file.
+//
+// Requests may reference the responses of others e.g. '{{login.response.body.$.token}}',
+// the runner implements [spec.ResponseSource] so that any such dependencies are sent on
+// demand as the request is resolved. Their responses are cached so each is sent at
+// most once.
+//
+// All requests sent by a runner share a cookie jar, so e.g. a session cookie set by
+// a login request is sent with every request after it.
+//
+// A runner is safe for concurrent use. Requests are resolved one at a time (the resolver,
+// prompts and dynamic variables aren't safe for concurrent use) but are sent concurrently.
+type runner struct {
+	ctx       context.Context               //nolint:containedctx // The runner only lives as long as a single command
+	logger    *log.Logger                   // The logger
+	resolver  *spec.Resolver                // Resolves requests, set after construction as it needs the runner itself
+	jar       http.CookieJar                // Cookies shared between all the requests
+	clients   map[clientConfig]*http.Client // HTTP clients, shared by all requests with the same config
+	calls     map[string]*call              // Requests that have been sent (or are being sent), by request name
+	file      string                        // Path to the .http file
+	resolving []string                      // Names of the requests currently being resolved, to detect cycles
+	mu        sync.Mutex                    // Guards resolver, calls and resolving
+	clientsMu sync.Mutex                    // Guards clients
+}
+
+// call is a single request sent by a [runner].
+type call struct {
+	done     chan struct{} // Closed when the response has been received (or the request failed)
+	err      error         // The error resolving or sending the request
+	response spec.Response // The response
+	request  spec.Request  // The resolved request
+	duration time.Duration // How long the request took
+}
+
+// clientConfig is the request configuration that needs it's own [http.Client].
+type clientConfig struct {
+	httpVersion       string
+	timeout           time.Duration
+	connectionTimeout time.Duration
+	noRedirect        bool
+	untimed           bool
+}
+
+// newRunner returns a new runner for the .http file, the resolver must be set
+// before it is used.
+func newRunner(ctx context.Context, file string, logger *log.Logger) *runner {
+	// cookiejar.New only errors for bad options and we have none
+	jar, _ := cookiejar.New(nil)
+
+	return &runner{
+		ctx:     ctx,
+		logger:  logger,
+		jar:     jar,
+		clients: make(map[clientConfig]*http.Client),
+		calls:   make(map[string]*call),
+		file:    file,
+	}
+}
+
+// Request resolves the named request, sending any requests it depends on.
+func (r *runner) Request(name string) (spec.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.resolve(name)
+}
+
+// Result sends the named request if it hasn't been already, waiting for it to complete
+// and returning the call with the resolved request, it's response and how long it took.
+func (r *runner) Result(name string) *call {
+	r.mu.Lock()
+	c, created := r.start(name)
+	r.mu.Unlock()
+
+	if created {
+		r.call(name, c)
+	}
+
+	<-c.done
+
+	return c
+}
+
+// Response implements [spec.ResponseSource], sending the named request if it
+// hasn't been already. The body is read in full and the response cached.
+//
+// It's only called by the resolver, so r.mu is already held.
+func (r *runner) Response(name string) (spec.Response, error) {
+	c, created := r.start(name)
+	if created {
+		// Dependencies are sent while holding the lock, anything else waiting for them
+		// is waiting to resolve a request that needs them anyway
+		r.call(name, c)
+	}
+
+	<-c.done
+
+	return c.response, c.err
+}
+
+// Close closes any idle connections held by the runner's HTTP clients.
+func (r *runner) Close() {
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
+	for _, client := range r.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// start returns the call for the named request, resolving it and registering a new call
+// if there isn't one already. If created is true, the caller must send the request with
+// [runner.call].
+//
+// r.mu must be held.
+func (r *runner) start(name string) (c *call, created bool) {
+	if existing, ok := r.calls[name]; ok {
+		return existing, false
+	}
+
+	request, err := r.resolve(name)
+
+	c = &call{done: make(chan struct{}), request: request}
+	r.calls[name] = c
+
+	if err != nil {
+		c.err = err
+		close(c.done)
+
+		return c, false
+	}
+
+	return c, true
+}
+
+// resolve resolves the named request, detecting cycles of requests that depend on each other.
+//
+// r.mu must be held.
+func (r *runner) resolve(name string) (spec.Request, error) {
+	if index := slices.Index(r.resolving, name); index != -1 {
+		cycle := append(slices.Clone(r.resolving[index:]), name)
+		return spec.Request{}, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	r.resolving = append(r.resolving, name)
+	defer func() { r.resolving = r.resolving[:len(r.resolving)-1] }()
+
+	return r.resolver.Request(name)
+}
+
+// call sends the request for c, reading the entire response and closing c.done
+// once it's complete.
+func (r *runner) call(name string, c *call) {
+	defer close(c.done)
+
+	start := time.Now()
+
+	r.logger.Debug("Sending request", "request", name, "method", c.request.Method, "url", c.request.URL)
+
+	response, err := r.send(c.request)
+	if err != nil {
+		c.err = fmt.Errorf("could not send %s: %w", name, err)
+		return
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		c.err = fmt.Errorf("could not read response to %s: %w", name, err)
+		return
+	}
+
+	c.duration = time.Since(start)
+
+	r.logger.Debug("Response", "request", name, "status", response.Status, "duration", c.duration)
+
+	c.response = spec.Response{
+		Header:     response.Header,
+		Body:       body,
+		StatusCode: response.StatusCode,
+	}
+}
+
+// send sends a resolved request, the caller is responsible for closing the
+// response body.
+//
+// The request's timeout covers the whole exchange, including reading the body.
+func (r *runner) send(request spec.Request) (*http.Response, error) {
+	return r.sendContext(r.ctx, request, false)
+}
+
+// sendUntimed is like send but the request's timeout isn't applied by the client, ctx
+// must enforce it instead.
+//
+// This is so the caller can lift it once the response headers arrive, e.g. so a
+// streamed response isn't cut off part way through.
+func (r *runner) sendUntimed(ctx context.Context, request spec.Request) (*http.Response, error) {
+	return r.sendContext(ctx, request, true)
+}
+
+// sendContext implements send and sendUntimed.
+func (r *runner) sendContext(ctx context.Context, request spec.Request, untimed bool) (*http.Response, error) {
+	if request.Method == spec.MethodWebSocket {
+		return nil, fmt.Errorf("WebSocket request %s can only be sent with 'req do'", request.Name)
+	}
+
+	if request.Method == spec.MethodGRPC {
+		return nil, fmt.Errorf("gRPC request %s can only be sent with 'req do'", request.Name)
+	}
+
+	httpRequest, err := newRequest(ctx, r.file, request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.client(request, untimed).Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP: %w", err)
+	}
+
+	return response, nil
+}
+
+// client returns the [http.Client] for the request, requests with the same timeouts,
+// redirect policy and HTTP version share a client and so it's connection pool.
+//
+// An untimed client has no overall timeout, only the timeouts of the connection itself.
+func (r *runner) client(request spec.Request, untimed bool) *http.Client {
+	config := clientConfig{
+		httpVersion:       request.HTTPVersion,
+		timeout:           request.Timeout,
+		connectionTimeout: request.ConnectionTimeout,
+		noRedirect:        request.NoRedirect,
+		untimed:           untimed,
+	}
+
+	r.clientsMu.Lock()
+	defer r.clientsMu.Unlock()
+
+	client, ok := r.clients[config]
+	if !ok {
+		client = httpClient(request)
+		client.Jar = r.jar
+
+		if untimed {
+			client.Timeout = 0
+		}
+
+		r.clients[config] = client
+	}
+
+	return client
+}
@@ -0,0 +1,99 @@
+package req
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/sse"
+)
+
+// streamBufferSize is the size of the buffer a streaming response is read into, anything
+// that arrives is printed straight away so it only limits how much is printed at once.
+const streamBufferSize = 4096
+
+// streamingTypes are the media types of responses that are printed as they arrive, rather
+// than once they're complete.
+var streamingTypes = []string{
+	sse.ContentType,
+	"application/x-ndjson",
+	"application/ndjson",
+	"application/jsonl",
+	"application/x-jsonlines",
+	"application/stream+json",
+}
+
+// isStreaming reports whether a response with the given Content-Type is a stream.
+func isStreaming(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && slices.Contains(streamingTypes, mediaType)
+}
+
+// stream prints a response body as it arrives. Server-sent events are printed one
+// at a time, parsed into their fields, and anything else in whatever chunks it's
+// received in.
+func (r Req) stream(body io.Reader, contentType string) error {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == sse.ContentType {
+		reader := sse.NewReader(body)
+
+		for {
+			event, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			r.printEvent(event)
+		}
+	}
+
+	buf := make([]byte, streamBufferSize)
+
+	var last byte
+
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := r.stdout.Write(buf[:n]); err != nil {
+				return err
+			}
+
+			last = buf[n-1]
+		}
+
+		if errors.Is(err, io.EOF) {
+			// Finish on a new line, as a complete body would be printed
+			if last != '\n' {
+				fmt.Fprintln(r.stdout)
+			}
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// printEvent prints a server-sent event, each field on it's own line followed by a
+// blank line.
+func (r Req) printEvent(event sse.Event) {
+	fmt.Fprintf(r.stdout, "%s: %s\n", headerName.Text("event"), event.Type)
+
+	if event.ID != "" {
+		fmt.Fprintf(r.stdout, "%s: %s\n", headerName.Text("id"), event.ID)
+	}
+
+	for line := range strings.SplitSeq(event.Data, "\n") {
+		fmt.Fprintf(r.stdout, "%s: %s\n", headerName.Text("data"), line)
+	}
+
+	fmt.Fprintln(r.stdout) // Line space
+}
@@ -0,0 +1,288 @@
+package req
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"go.followtheprocess.codes/log"
+	"go.followtheprocess.codes/req/internal/spec"
+	"go.followtheprocess.codes/req/internal/websocket"
+)
+
+// timestampFormat is the format of the time printed next to every WebSocket message.
+const timestampFormat = "15:04:05.000"
+
+// doWebSocket implements `req do` for a WebSocket request.
+//
+// It opens the connection and sends the request's scripted messages, waiting for the
+// server where the script says to. If the session is interactive, or there is no script,
+// each line typed on stdin is then sent until EOF. Every message sent and received is
+// printed with the time, and Ctrl-C closes the connection cleanly.
+func (r Req) doWebSocket(request spec.Request, options DoOptions, logger *log.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	header := make(http.Header, len(request.Headers))
+	for _, h := range request.Headers {
+		header.Add(h.Name, h.Value)
+	}
+
+	dialCtx, cancelDial := context.WithTimeout(ctx, cmp.Or(request.ConnectionTimeout, options.ConnectionTimeout, DefaultConnectionTimeout))
+	defer cancelDial()
+
+	logger.Debug("Opening WebSocket", "url", request.URL, "headers", request.Headers)
+
+	conn, response, err := websocket.Dial(dialCtx, request.URL, header)
+	if err != nil {
+		return err
+	}
+
+	r.printResponseHead(response)
+
+	session := &wsSession{
+		req:         r,
+		conn:        conn,
+		logger:      logger,
+		messages:    slices.Clone(request.Messages),
+		timeout:     cmp.Or(request.Timeout, options.Timeout, DefaultTimeout),
+		interactive: options.Interactive || len(request.Messages) == 0,
+		incoming:    make(chan websocket.Message),
+		readErr:     make(chan error, 1),
+		closed:      make(chan error, 1),
+		done:        make(chan struct{}),
+	}
+
+	return session.run(ctx)
+}
+
+// wsSession is the state of a single WebSocket session in `req do`.
+type wsSession struct {
+	conn        *websocket.Conn
+	logger      *log.Logger
+	waitTimer   *time.Timer            // Running while waiting for the server, nil otherwise
+	lines       <-chan string          // Lines typed on stdin, nil until the script has finished
+	incoming    chan websocket.Message // Messages from the server
+	readErr     chan error             // The error that stopped reading from the server
+	closed      chan error             // The result of closing the connection, once we've started to
+	done        chan struct{}          // Closed when the session ends, stopping any goroutines
+	req         Req
+	messages    []spec.Message // The rest of the script
+	timeout     time.Duration  // How long to wait for the server when the script says to
+	waiting     int            // How many messages from the server are still to be waited for
+	interactive bool           // Whether to send lines typed on stdin after the script
+	closing     bool           // Whether we've started closing the connection
+}
+
+// run runs the session until the connection is closed.
+func (s *wsSession) run(ctx context.Context) error {
+	defer close(s.done)
+
+	go s.read()
+
+	if err := s.advance(); err != nil {
+		s.conn.Close()
+		return err
+	}
+
+	interrupted := ctx.Done()
+
+	for {
+		var timeout <-chan time.Time
+		if s.waitTimer != nil {
+			timeout = s.waitTimer.C
+		}
+
+		select {
+		case message := <-s.incoming:
+			s.req.printMessage("<", message)
+
+			if s.waiting > 0 {
+				s.waiting--
+				if s.waiting == 0 {
+					s.stopWaiting()
+
+					if err := s.advance(); err != nil {
+						s.conn.Close()
+						return err
+					}
+				}
+			}
+
+		case line, ok := <-s.lines:
+			if !ok {
+				s.close()
+				continue
+			}
+
+			if line == "" {
+				continue
+			}
+
+			if err := s.send(line); err != nil {
+				s.conn.Close()
+				return err
+			}
+
+		case <-timeout:
+			s.conn.Close()
+			return fmt.Errorf("timed out after %s waiting for a message from the server", s.timeout)
+
+		case <-interrupted:
+			interrupted = nil
+
+			s.logger.Debug("Interrupted, closing WebSocket")
+			s.close()
+
+		case err := <-s.readErr:
+			return s.finish(err)
+		}
+	}
+}
+
+// advance sends the scripted messages, up to the next one that must wait for the server.
+// Once the script is finished, it moves on to reading stdin if interactive or otherwise
+// closes the connection.
+func (s *wsSession) advance() error {
+	for len(s.messages) > 0 {
+		message := &s.messages[0]
+
+		if message.Wait > 0 {
+			s.waiting, message.Wait = message.Wait, 0
+			s.waitTimer = time.NewTimer(s.timeout)
+
+			return nil
+		}
+
+		if message.Body != "" {
+			if err := s.send(message.Body); err != nil {
+				return err
+			}
+		}
+
+		s.messages = s.messages[1:]
+	}
+
+	if s.interactive {
+		s.lines = s.req.readLines(s.done)
+	} else {
+		s.close()
+	}
+
+	return nil
+}
+
+// send sends a text message to the server and prints it.
+func (s *wsSession) send(text string) error {
+	message := websocket.Message{Type: websocket.Text, Data: []byte(text)}
+	if err := s.conn.Write(message.Type, message.Data); err != nil {
+		return err
+	}
+
+	s.req.printMessage(">", message)
+
+	return nil
+}
+
+// read reads messages from the server until the connection is closed.
+func (s *wsSession) read() {
+	for {
+		message, err := s.conn.Read()
+		if err != nil {
+			s.readErr <- err
+			return
+		}
+
+		select {
+		case s.incoming <- message:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close starts closing the connection, any messages the server sends in the meantime
+// are still printed.
+func (s *wsSession) close() {
+	if s.closing {
+		return
+	}
+
+	s.closing = true
+	s.lines = nil
+	s.stopWaiting()
+
+	go func() {
+		s.closed <- s.conn.Close()
+	}()
+}
+
+// stopWaiting stops waiting for the server.
+func (s *wsSession) stopWaiting() {
+	if s.waitTimer != nil {
+		s.waitTimer.Stop()
+		s.waitTimer = nil
+	}
+}
+
+// finish ends the session once reading has stopped with err.
+func (s *wsSession) finish(err error) error {
+	if s.closing {
+		s.logger.Debug("Closed WebSocket")
+		return <-s.closed
+	}
+
+	s.conn.Close()
+
+	if !errors.Is(err, websocket.ErrClosed) {
+		return err
+	}
+
+	if s.waiting > 0 || len(s.messages) > 0 {
+		return errors.New("the server closed the connection before the script finished")
+	}
+
+	s.logger.Debug("Server closed WebSocket")
+
+	return nil
+}
+
+// readLines reads lines from stdin in the background, the returned channel is closed
+// at EOF or when done is closed.
+func (r Req) readLines(done <-chan struct{}) <-chan string {
+	lines := make(chan string)
+
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(r.stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return lines
+}
+
+// printMessage prints a WebSocket message sent (>) or received (<), with the time.
+func (r Req) printMessage(direction string, message websocket.Message) {
+	timestamp := time.Now().Format(timestampFormat)
+
+	if message.Type == websocket.Binary {
+		fmt.Fprintf(r.stdout, "%s %s (binary, %d bytes)\n", timestamp, direction, len(message.Data))
+		return
+	}
+
+	fmt.Fprintf(r.stdout, "%s %s %s\n", timestamp, direction, message.Data)
+}
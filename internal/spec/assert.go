@@ -0,0 +1,219 @@
+package spec
+
+import (
+	"cmp"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+// maxActualLength is the longest actual value shown in full in an assertion failure,
+// anything longer (most likely an entire body) is truncated.
+const maxActualLength = 80
+
+// Assertion is a check on the response to a request, declared in the form
+// '?? <subject> [selector] <operator> [value]' e.g. '?? status == 200'.
+type Assertion struct {
+	// What is being checked, one of "status", "header" or "body"
+	Subject string `json:"subject"`
+
+	// The header name for a "header" subject, or an optional JSONPath ('$...') or
+	// XPath ('/...') into the response body for a "body" subject
+	Selector string `json:"selector,omitempty"`
+
+	// The comparison e.g. "==" or "contains"
+	Operator string `json:"operator"`
+
+	// The expected value with any variable interpolation evaluated, may be wrapped
+	// in double quotes to preserve whitespace
+	Value string `json:"value,omitempty"`
+
+	// Source position of the assertion in the .http file
+	Pos syntax.Position `json:"-"`
+}
+
+// String implements [fmt.Stringer] for an [Assertion].
+func (a Assertion) String() string {
+	return syntax.Assertion{
+		Subject:  a.Subject,
+		Selector: a.Selector,
+		Operator: a.Operator,
+		Value:    a.Value,
+	}.String()
+}
+
+// Check checks the assertion against a response, returning an error describing
+// why if it fails.
+//
+// Equality ('==' and '!=') compares numerically if both sides are numbers and as
+// strings otherwise. The ordering operators ('<', '<=', '>' and '>=') need both
+// sides to be numbers.
+func (a Assertion) Check(response Response) error {
+	actual, found, err := a.actual(response)
+
+	switch a.Operator {
+	case "exists":
+		if !found {
+			return fmt.Errorf("expected %s to exist", a.target())
+		}
+
+		return nil
+	case "!exists":
+		if found {
+			return fmt.Errorf("expected %s not to exist, got %s", a.target(), truncate(actual))
+		}
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("expected %s %s %s, but %s is missing", a.target(), a.Operator, a.Value, a.target())
+	}
+
+	ok, err := compare(actual, a.Operator, unquote(a.Value))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("expected %s %s %s, got %s", a.target(), a.Operator, a.Value, truncate(actual))
+	}
+
+	return nil
+}
+
+// target describes what's being checked, for error messages e.g. "header Content-Type".
+func (a Assertion) target() string {
+	if a.Selector == "" {
+		return a.Subject
+	}
+
+	return a.Subject + " " + a.Selector
+}
+
+// actual returns the value from response that's being checked, and whether it exists.
+func (a Assertion) actual(response Response) (value string, found bool, err error) {
+	switch a.Subject {
+	case "status":
+		return strconv.Itoa(response.StatusCode), true, nil
+	case "header":
+		values := response.Header.Values(a.Selector)
+		if len(values) == 0 {
+			return "", false, nil
+		}
+
+		return strings.Join(values, ", "), true, nil
+	case "body":
+		if a.Selector == "" {
+			return string(response.Body), true, nil
+		}
+
+		value, err := selectBody(response.Body, a.Selector)
+		if err != nil {
+			return "", false, err
+		}
+
+		return value, true, nil
+	default:
+		return "", false, fmt.Errorf("unknown assertion subject %q", a.Subject)
+	}
+}
+
+// compare compares actual against expected using the assertion operator.
+func compare(actual, operator, expected string) (bool, error) {
+	switch operator {
+	case "==", "!=":
+		equal := actual == expected
+		if order, ok := numbers(actual, expected); ok {
+			equal = order == 0
+		}
+
+		return equal == (operator == "=="), nil
+	case "<", "<=", ">", ">=":
+		order, ok := numbers(actual, expected)
+		if !ok {
+			return false, fmt.Errorf("cannot use %s on %s and %s, both must be numbers", operator, truncate(actual), expected)
+		}
+
+		switch operator {
+		case "<":
+			return order < 0, nil
+		case "<=":
+			return order <= 0, nil
+		case ">":
+			return order > 0, nil
+		default:
+			return order >= 0, nil
+		}
+	case "contains":
+		return strings.Contains(actual, expected), nil
+	case "!contains":
+		return !strings.Contains(actual, expected), nil
+	case "matches":
+		re, err := regexp.Compile(expected)
+		if err != nil {
+			return false, fmt.Errorf("bad regular expression %q: %w", expected, err)
+		}
+
+		return re.MatchString(actual), nil
+	default:
+		return false, fmt.Errorf("unknown assertion operator %q", operator)
+	}
+}
+
+// numbers compares a and b as numbers, returning -1, 0 or +1 as a is less than, equal
+// to or greater than b. The ok is false unless both are numbers.
+//
+// They are compared by their exact value, so integers too big for a float64 are still
+// told apart.
+func numbers(a, b string) (order int, ok bool) {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+
+	if errA != nil || errB != nil {
+		return 0, false
+	}
+
+	// big.Rat has no infinity and refuses absurd exponents, in which case the floats will do
+	var ratA, ratB big.Rat
+
+	_, okA := ratA.SetString(a)
+	_, okB := ratB.SetString(b)
+
+	if okA && okB {
+		return ratA.Cmp(&ratB), true
+	}
+
+	return cmp.Compare(x, y), true
+}
+
+// unquote removes the double quotes from a quoted assertion value, anything else
+// is returned as is.
+func unquote(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+
+	return value
+}
+
+// truncate shortens a long actual value for display in an error message.
+func truncate(value string) string {
+	if len(value) <= maxActualLength {
+		return strconv.Quote(value)
+	}
+
+	return strconv.Quote(value[:maxActualLength]) + "..."
+}
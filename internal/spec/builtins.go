@@ -0,0 +1,144 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+	"go.followtheprocess.codes/req/internal/vars"
+)
+
+const (
+	defaultRandomIntMax = 1000   // Upper bound (exclusive) of '$randomInt' with no arguments
+	dotenvFile          = ".env" // Name of the file read by '$dotenv', in the same directory as the .http file
+)
+
+// Builtins returns the names of the dynamic variables available to interpolation,
+// without their leading '$', in sorted order.
+func Builtins() []string {
+	return slices.Sorted(maps.Keys(builtins(config{})))
+}
+
+// builtins returns the dynamic variables available to interpolation e.g. '{{$uuid}}'.
+//
+// The random source and clock are shared by every variable and every request, so
+// each reference to e.g. '$uuid' produces a new value but the sequence of values is
+// reproducible for a given seed.
+func builtins(cfg config) map[string]interp.Builtin {
+	uuid := func(args []string) (string, error) {
+		if err := noArgs(args); err != nil {
+			return "", err
+		}
+
+		return newUUID(cfg.rand), nil
+	}
+
+	// Only read the .env file if something asks for it, and then only once
+	var dotenv map[string]string
+
+	return map[string]interp.Builtin{
+		"uuid":        uuid,
+		"guid":        uuid,
+		"random.uuid": uuid,
+		"timestamp": func(args []string) (string, error) {
+			if err := noArgs(args); err != nil {
+				return "", err
+			}
+
+			return strconv.FormatInt(cfg.now().Unix(), 10), nil
+		},
+		"isoTimestamp": func(args []string) (string, error) {
+			if err := noArgs(args); err != nil {
+				return "", err
+			}
+
+			return cfg.now().UTC().Format(time.RFC3339), nil
+		},
+		"randomInt": func(args []string) (string, error) {
+			low, high := 0, defaultRandomIntMax
+
+			switch len(args) {
+			case 0:
+			case 2:
+				var err error
+				if low, err = strconv.Atoi(args[0]); err != nil {
+					return "", fmt.Errorf("bad min %q: %w", args[0], err)
+				}
+
+				if high, err = strconv.Atoi(args[1]); err != nil {
+					return "", fmt.Errorf("bad max %q: %w", args[1], err)
+				}
+
+				if high <= low {
+					return "", fmt.Errorf("max (%d) must be greater than min (%d)", high, low)
+				}
+			default:
+				return "", fmt.Errorf("expected either no arguments or 'min max', got %d arguments", len(args))
+			}
+
+			return strconv.Itoa(low + cfg.rand.IntN(high-low)), nil
+		},
+		"processEnv": func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("expected the name of an environment variable, got %d arguments", len(args))
+			}
+
+			value, ok := os.LookupEnv(args[0])
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", args[0])
+			}
+
+			return value, nil
+		},
+		"dotenv": func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("expected the name of a variable, got %d arguments", len(args))
+			}
+
+			if dotenv == nil {
+				values, err := vars.ReadFile(filepath.Join(cfg.dir, dotenvFile))
+				if err != nil {
+					return "", err
+				}
+
+				dotenv = values
+			}
+
+			value, ok := dotenv[args[0]]
+			if !ok {
+				return "", fmt.Errorf("%s is not set in %s", args[0], dotenvFile)
+			}
+
+			return value, nil
+		},
+	}
+}
+
+// noArgs returns an error if a dynamic variable that takes no arguments was given some.
+func noArgs(args []string) error {
+	if len(args) != 0 {
+		return errors.New("takes no arguments")
+	}
+
+	return nil
+}
+
+// newUUID returns a new random (version 4) UUID drawn from r.
+func newUUID(r *rand.Rand) string {
+	var uuid [16]byte
+	for i := range uuid {
+		uuid[i] = byte(r.UintN(256))
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40 // Version 4
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // Variant is 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
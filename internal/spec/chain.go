@@ -0,0 +1,219 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/query"
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+)
+
+// ErrReference is a generic error for invalid references between requests, details on the
+// error are passed to the [syntax.ErrorHandler] given to [CheckReferences].
+var ErrReference = errors.New("invalid request reference")
+
+// Response is the response to a request, as made available to other requests that
+// reference it e.g. with '{{login.response.body.$.token}}'.
+type Response struct {
+	Header     http.Header // The response headers
+	Body       []byte      // The entire response body
+	StatusCode int         // The HTTP status code
+}
+
+// A ResponseSource provides the responses to requests referenced by other requests,
+// typically by sending them.
+type ResponseSource interface {
+	// Response returns the response to the named request.
+	Response(name string) (Response, error)
+}
+
+// WithResponses sets the [ResponseSource] used to resolve references to the responses
+// of other requests e.g. '{{login.response.body.$.token}}'.
+//
+// If not set, such references are left in place unresolved, so a file can be resolved
+// without sending any requests.
+func WithResponses(source ResponseSource) Option {
+	return func(cfg *config) {
+		cfg.responses = source
+	}
+}
+
+// responses returns the [interp.ResponseFunc] that resolves references to other
+// request's responses.
+func responses(cfg config) interp.ResponseFunc {
+	return func(ref interp.Reference) (string, error) {
+		if cfg.responses == nil {
+			return "{{" + ref.String() + "}}", nil
+		}
+
+		response, err := cfg.responses.Response(ref.Request)
+		if err != nil {
+			return "", err
+		}
+
+		return selectFrom(response, ref)
+	}
+}
+
+// selectFrom selects the part of response described by ref.
+func selectFrom(response Response, ref interp.Reference) (string, error) {
+	if ref.Part == interp.Headers {
+		values := response.Header.Values(ref.Path)
+		if len(values) == 0 {
+			return "", fmt.Errorf("response to %s has no header %s", ref.Request, ref.Path)
+		}
+
+		return strings.Join(values, ", "), nil
+	}
+
+	if ref.Path == "" {
+		return string(response.Body), nil
+	}
+
+	value, err := selectBody(response.Body, ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("response to %s: %w", ref.Request, err)
+	}
+
+	return value, nil
+}
+
+// selectBody selects a value from a response body with a JSONPath ('$...') or XPath ('/...').
+func selectBody(body []byte, path string) (string, error) {
+	switch {
+	case strings.HasPrefix(path, "$"):
+		return query.JSONPath(body, path)
+	case strings.HasPrefix(path, "/"):
+		return query.XPath(body, path)
+	default:
+		return "", fmt.Errorf("bad selector %q, expected JSONPath ('$...') or XPath ('/...')", path)
+	}
+}
+
+// edge is a reference from one request to another.
+type edge struct {
+	to  string          // Name of the referenced request
+	pos syntax.Position // Position of the reference
+}
+
+// CheckReferences checks the references between the requests in a file e.g. '{{login.response.body.$.token}}',
+// without sending anything.
+//
+// References to requests that don't exist and cycles of requests that depend on each other
+// are reported to handler, the returned error will simply signify whether there were any.
+func CheckReferences(in syntax.File, handler syntax.ErrorHandler) error {
+	names := make(map[string]bool, len(in.Requests))
+	for _, request := range in.Requests {
+		names[request.Name] = true
+	}
+
+	hadErrors := false
+	report := func(pos syntax.Position, msg string) {
+		hadErrors = true
+
+		if handler != nil {
+			handler(pos, msg)
+		}
+	}
+
+	graph := make(map[string][]edge, len(in.Requests))
+
+	for _, request := range in.Requests {
+		for _, tag := range requestTags(request) {
+			ref, ok, err := interp.ParseReference(tag.Expr)
+			if !ok {
+				continue
+			}
+
+			if err != nil {
+				report(tag.Pos, err.Error())
+				continue
+			}
+
+			if !names[ref.Request] {
+				report(tag.Pos, fmt.Sprintf("reference to unknown request %q", ref.Request))
+				continue
+			}
+
+			graph[request.Name] = append(graph[request.Name], edge{to: ref.Request, pos: tag.Pos})
+		}
+	}
+
+	// Depth first search for cycles, a reference to a request already on the path
+	// we're exploring closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(in.Requests))
+
+	var path []string
+
+	var visit func(name string)
+
+	visit = func(name string) {
+		state[name] = visiting
+
+		path = append(path, name)
+
+		for _, edge := range graph[name] {
+			switch state[edge.to] {
+			case visiting:
+				start := slices.Index(path, edge.to)
+				cycle := append(slices.Clone(path[start:]), edge.to)
+				report(edge.pos, "dependency cycle: "+strings.Join(cycle, " -> "))
+			case unvisited:
+				visit(edge.to)
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+
+	for _, request := range in.Requests {
+		if state[request.Name] == unvisited {
+			visit(request.Name)
+		}
+	}
+
+	if hadErrors {
+		return ErrReference
+	}
+
+	return nil
+}
+
+// requestTags returns all the '{{...}}' tags in a request, in a deterministic order.
+func requestTags(request syntax.Request) []interp.Tag {
+	var tags []interp.Tag
+
+	for _, key := range slices.Sorted(maps.Keys(request.Vars)) {
+		tags = append(tags, interp.Tags(request.Vars[key], request.Positions.Vars[key])...)
+	}
+
+	tags = append(tags, interp.Tags(request.URL, request.Positions.URL)...)
+
+	for _, header := range request.Headers {
+		tags = append(tags, interp.Tags(header.Value, header.Pos)...)
+	}
+
+	tags = append(tags, interp.Tags(string(request.Body), request.Positions.Body)...)
+
+	for _, field := range request.Form {
+		tags = append(tags, interp.Tags(field.Value, field.Pos)...)
+	}
+
+	for _, assertion := range request.Assertions {
+		tags = append(tags, interp.Tags(assertion.Value, assertion.ValuePos)...)
+	}
+
+	return tags
+}
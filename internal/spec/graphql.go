@@ -0,0 +1,166 @@
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/graphql"
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+// ErrGraphQL is a generic error for invalid GraphQL requests, details on the error are
+// passed to the [syntax.ErrorHandler] given to [CheckGraphQL].
+var ErrGraphQL = errors.New("invalid GraphQL request")
+
+// requestTypeHeader is the header that marks a request as GraphQL in the VSCode REST Client.
+const requestTypeHeader = "X-Request-Type"
+
+// isGraphQL reports whether a request is a GraphQL request, declared either with the
+// 'GRAPHQL' method as in JetBrains IDEs or with an 'X-Request-Type: GraphQL' header as
+// in the VSCode REST Client.
+func isGraphQL(in syntax.Request) bool {
+	if in.Method == "GRAPHQL" {
+		return true
+	}
+
+	for _, header := range in.Headers {
+		if strings.EqualFold(header.Name, requestTypeHeader) && strings.EqualFold(header.Value, "GraphQL") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveGraphQL turns a resolved GraphQL request into the standard JSON POST, with the
+// query and any variables in the body.
+func resolveGraphQL(in syntax.Request, resolved Request) (Request, error) {
+	if resolved.BodyFile != "" {
+		return Request{}, fmt.Errorf("GraphQL request %s must have an inline body or a '<@' body file", in.Name)
+	}
+
+	body, err := graphql.Body(string(resolved.Body))
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid GraphQL request %s: %w", in.Name, err)
+	}
+
+	resolved.Method = http.MethodPost
+	resolved.Body = body
+	resolved.GraphQL = true
+
+	// The header is only there to mark the request as GraphQL, the server doesn't need it
+	resolved.Headers = slices.DeleteFunc(resolved.Headers, func(header Header) bool {
+		return strings.EqualFold(header.Name, requestTypeHeader)
+	})
+
+	if resolved.Header("Content-Type") == "" {
+		resolved.Headers = append(resolved.Headers, Header{Name: "Content-Type", Value: "application/json"})
+	}
+
+	return resolved, nil
+}
+
+// CheckGraphQL checks the syntax of the query and variables of every GraphQL request in a
+// file with an inline body, without sending anything. It knows nothing of the schema, so
+// a query may still be rejected by the server.
+//
+// Any errors are reported to handler, the returned error will simply signify whether there
+// were any.
+func CheckGraphQL(in syntax.File, handler syntax.ErrorHandler) error {
+	hadErrors := false
+	report := func(pos syntax.Position, msg string) {
+		hadErrors = true
+
+		if handler != nil {
+			handler(pos, msg)
+		}
+	}
+
+	for _, request := range in.Requests {
+		if !isGraphQL(request) || request.Body == nil {
+			continue
+		}
+
+		body := string(request.Body)
+		query, variables := graphql.Split(body)
+
+		// Variables may be interpolated anywhere, so tags are replaced by something that's
+		// valid as a name or value in the query, and as a value in the variables
+		var syntaxErr *graphql.SyntaxError
+		if err := graphql.Check(maskTags(query, '_')); errors.As(err, &syntaxErr) {
+			report(offsetPosition(request.Positions.Body, body, syntaxErr.Offset), "bad GraphQL query: "+syntaxErr.Msg)
+		}
+
+		if variables != "" {
+			var vars map[string]any
+			if err := json.Unmarshal([]byte(maskTags(variables, '0')), &vars); err != nil {
+				offset := strings.LastIndex(body, variables)
+				report(offsetPosition(request.Positions.Body, body, offset), "bad GraphQL variables: "+err.Error())
+			}
+		}
+	}
+
+	if hadErrors {
+		return ErrGraphQL
+	}
+
+	return nil
+}
+
+// maskTags replaces every '{{...}}' tag in src with mask padded with spaces to the same
+// length, so offsets into it are unchanged.
+func maskTags(src string, mask byte) string {
+	builder := &strings.Builder{}
+	builder.Grow(len(src))
+
+	rest := src
+
+	for {
+		start := strings.Index(rest, "{{")
+		if start == -1 {
+			break
+		}
+
+		end := strings.Index(rest[start:], "}}")
+		if end == -1 {
+			break
+		}
+
+		end += start + len("}}")
+
+		builder.WriteString(rest[:start])
+		builder.WriteByte(mask)
+		builder.WriteString(strings.Repeat(" ", end-start-1))
+
+		rest = rest[end:]
+	}
+
+	builder.WriteString(rest)
+
+	return builder.String()
+}
+
+// offsetPosition returns the position of the byte at offset in src, where start is the
+// position of the first byte of src.
+func offsetPosition(start syntax.Position, src string, offset int) syntax.Position {
+	before := src[:offset]
+	lines := strings.Count(before, "\n")
+
+	pos := start
+	pos.Offset += offset
+	pos.Line += lines
+
+	if lines == 0 {
+		pos.StartCol += offset
+	} else {
+		pos.StartCol = 1 + offset - (strings.LastIndex(before, "\n") + 1)
+	}
+
+	pos.EndCol = pos.StartCol
+
+	return pos
+}
@@ -0,0 +1,30 @@
+package spec
+
+import (
+	"fmt"
+
+	"go.followtheprocess.codes/req/internal/grpc"
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+// MethodGRPC is the method of a gRPC request, which isn't sent over HTTP/1 but calls
+// a unary gRPC method with it's JSON body, as in JetBrains IDEs.
+const MethodGRPC = "GRPC"
+
+// resolveGRPC validates a resolved gRPC request, it's URL is a gRPC target rather than
+// a URL and it's body must be JSON.
+func resolveGRPC(in syntax.Request, resolved Request) (Request, error) {
+	if _, err := grpc.ParseTarget(resolved.URL); err != nil {
+		return Request{}, fmt.Errorf("invalid gRPC request %s: %w", in.Name, err)
+	}
+
+	if resolved.BodyFile != "" {
+		return Request{}, fmt.Errorf("gRPC request %s must have an inline body or a '<@' body file", in.Name)
+	}
+
+	if len(resolved.Parts) > 0 || len(in.Form) > 0 {
+		return Request{}, fmt.Errorf("gRPC request %s must have a JSON body", in.Name)
+	}
+
+	return resolved, nil
+}
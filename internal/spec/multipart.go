@@ -0,0 +1,150 @@
+package spec
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+)
+
+// Boundary returns the boundary declared in a multipart Content-Type header e.g.
+// 'multipart/form-data; boundary=WebAppBoundary', or false if it isn't multipart.
+func Boundary(contentType string) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "", false
+	}
+
+	boundary, ok := params["boundary"]
+
+	return boundary, ok
+}
+
+// resolveParts splits the resolved body of a multipart request into it's parts, in the
+// JetBrains syntax:
+//
+//	--WebAppBoundary
+//	Content-Disposition: form-data; name="field"
+//
+//	value
+//	--WebAppBoundary
+//	Content-Disposition: form-data; name="photo"; filename="photo.png"
+//	Content-Type: image/png
+//
+//	< ./photo.png
+//	--WebAppBoundary--
+//
+// A part whose content is a '< ./file' is streamed from that file when the request is
+// sent, one whose content is a '<@ ./file' has it's contents read and interpolated
+// now. Anything before the first boundary or after the last is ignored.
+func resolveParts(in syntax.Request, body, boundary string, interpolator *interp.Interpolator, cfg config) ([]Part, error) {
+	delimiter := "--" + boundary
+	closing := delimiter + "--"
+
+	var (
+		parts   []Part
+		current []string // Lines of the part being collected
+		inPart  bool     // Whether we're past the first boundary
+		closed  bool     // Whether we've seen the closing boundary
+	)
+
+	for line := range strings.Lines(strings.ReplaceAll(body, "\r\n", "\n")) {
+		line = strings.TrimSuffix(line, "\n")
+
+		switch strings.TrimRight(line, " \t") {
+		case delimiter, closing:
+			if inPart {
+				part, err := resolvePart(in, current, interpolator, cfg)
+				if err != nil {
+					return nil, err
+				}
+
+				parts = append(parts, part)
+			}
+
+			inPart, current = true, nil
+			closed = strings.TrimRight(line, " \t") == closing
+		default:
+			if inPart {
+				current = append(current, line)
+			}
+		}
+
+		if closed {
+			break
+		}
+	}
+
+	if !closed {
+		return nil, fmt.Errorf("multipart body for request %s must end with the closing boundary %q", in.Name, closing)
+	}
+
+	return parts, nil
+}
+
+// resolvePart parses the lines of a single part of a multipart body, a set of headers
+// then a blank line then it's content.
+func resolvePart(in syntax.Request, lines []string, interpolator *interp.Interpolator, cfg config) (Part, error) {
+	var part Part
+
+	index := 0
+	for ; index < len(lines) && strings.TrimSpace(lines[index]) != ""; index++ {
+		name, value, ok := strings.Cut(lines[index], ":")
+		if !ok {
+			return Part{}, fmt.Errorf("bad header in multipart body for request %s: %q", in.Name, lines[index])
+		}
+
+		part.Headers = append(part.Headers, Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+
+	// Skip the blank line between the headers and the content
+	if index < len(lines) {
+		index++
+	}
+
+	content := strings.Join(lines[index:], "\n")
+	trimmed := strings.TrimSpace(content)
+
+	// A file is the only thing on it's line e.g. '< ./photo.png', anything else is
+	// content that just happens to start with a '<' e.g. some HTML
+	if strings.Contains(trimmed, "\n") {
+		part.Body = []byte(content)
+		return part, nil
+	}
+
+	if path, ok := strings.CutPrefix(trimmed, "<@ "); ok {
+		path = strings.TrimSpace(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.dir, path)
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return Part{}, fmt.Errorf("could not read multipart file for request %s: %w", in.Name, err)
+		}
+
+		pos := syntax.Position{Name: path, Line: 1, StartCol: 1, EndCol: 1}
+
+		resolved, err := interpolator.Interpolate(string(contents), pos)
+		if err != nil {
+			return Part{}, fmt.Errorf("could not resolve multipart file %s: %w", path, err)
+		}
+
+		part.Body = []byte(resolved)
+
+		return part, nil
+	}
+
+	if path, ok := strings.CutPrefix(trimmed, "< "); ok {
+		part.File = strings.TrimSpace(path)
+		return part, nil
+	}
+
+	part.Body = []byte(content)
+
+	return part, nil
+}
@@ -13,8 +13,9 @@ type Request struct {
 	// Request scoped variables, override globals if specified
 	Vars map[string]string `json:"vars,omitempty"`
 
-	// Request headers, may have variable interpolation in values but not keys
-	Headers map[string]string `json:"headers,omitempty"`
+	// Request headers in the order they were declared, with variable interpolation
+	// evaluated. A header may appear more than once
+	Headers []Header `json:"headers,omitempty"`
 
 	// Request scoped prompts, the user will be asked to provide values for each of these
 	// whenever this particular request is invoked.
@@ -46,9 +47,22 @@ type Request struct {
 	// which to write the response (relative to the .http file)
 	ResponseFile string `json:"responseFile,omitempty"`
 
+	// Whether the response redirect was declared with '>>', in which case if ResponseFile
+	// already exists, a numeric suffix is added to the filename rather than overwriting it
+	UniqueResponseFile bool `json:"uniqueResponseFile,omitempty"`
+
+	// If a response reference was provided e.g. '<> ./previous.json', this is the path
+	// to the local file the response is saved to and compared against on the next
+	// run (relative to the .http file)
+	ResponseReference string `json:"responseReference,omitempty"`
+
 	// Request body, if provided inline. Again, variable interpolation and special things like {{ .Global.base }} have been evaluated
 	Body []byte `json:"body,omitempty"`
 
+	// The parts of a multipart body, split on the boundary in the Content-Type header. If
+	// present, these are sent instead of Body
+	Parts []Part `json:"parts,omitempty"`
+
 	// Request scoped timeout, overrides global if set
 	Timeout time.Duration `json:"timeout,omitempty"`
 
@@ -57,6 +71,32 @@ type Request struct {
 
 	// Disable following redirects for this request, overrides global if set
 	NoRedirect bool `json:"noRedirect,omitempty"`
+
+	// The path to a .proto file declaring the service of a gRPC request, from '@proto'
+	// (relative to the .http file). If empty, the server's reflection service is used
+	Proto string `json:"proto,omitempty"`
+
+	// The scripted messages of a WebSocket request, split from the body on '===' separators
+	Messages []Message `json:"messages,omitempty"`
+
+	// Whether this is a GraphQL request, in which case Body is the JSON encoded query
+	// and variables and the response is expected to be GraphQL too
+	GraphQL bool `json:"graphql,omitempty"`
+
+	// Assertions on the response, with variable interpolation in their values evaluated
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// Header returns the value of the first header called name, or "" if there
+// isn't one. Header names are case insensitive.
+func (r Request) Header(name string) string {
+	for _, header := range r.Headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+
+	return ""
 }
 
 // String implements [fmt.Stringer] for a [Request].
@@ -95,18 +135,22 @@ func (r Request) String() string {
 		fmt.Fprintf(builder, "# @no-redirect = %v\n", r.NoRedirect)
 	}
 
+	if r.Proto != "" {
+		fmt.Fprintf(builder, "# @proto = %s\n", r.Proto)
+	}
+
 	if r.HTTPVersion != "" {
 		fmt.Fprintf(builder, "%s %s %s\n", r.Method, r.URL, r.HTTPVersion)
 	} else {
 		fmt.Fprintf(builder, "%s %s\n", r.Method, r.URL)
 	}
 
-	for _, key := range slices.Sorted(maps.Keys(r.Headers)) {
-		fmt.Fprintf(builder, "%s: %s\n", key, r.Headers[key])
+	for _, header := range r.Headers {
+		fmt.Fprintf(builder, "%s\n", header)
 	}
 
 	// Separate the body section
-	if r.Body != nil || r.BodyFile != "" || r.ResponseFile != "" {
+	if r.Body != nil || len(r.Parts) > 0 || len(r.Messages) > 0 || r.BodyFile != "" || r.ResponseFile != "" || r.ResponseReference != "" || len(r.Assertions) > 0 {
 		builder.WriteString("\n")
 	}
 
@@ -118,26 +162,81 @@ func (r Request) String() string {
 		fmt.Fprintf(builder, "%s\n", string(r.Body))
 	}
 
+	if len(r.Parts) > 0 {
+		boundary, _ := Boundary(r.Header("Content-Type"))
+
+		for _, part := range r.Parts {
+			fmt.Fprintf(builder, "--%s\n%s", boundary, part)
+		}
+
+		fmt.Fprintf(builder, "--%s--\n", boundary)
+	}
+
+	for _, message := range r.Messages {
+		builder.WriteString(message.String())
+	}
+
 	if r.ResponseFile != "" {
-		fmt.Fprintf(builder, "> %s\n", r.ResponseFile)
+		if r.UniqueResponseFile {
+			fmt.Fprintf(builder, ">> %s\n", r.ResponseFile)
+		} else {
+			fmt.Fprintf(builder, "> %s\n", r.ResponseFile)
+		}
+	}
+
+	if r.ResponseReference != "" {
+		fmt.Fprintf(builder, "<> %s\n", r.ResponseReference)
+	}
+
+	for _, assertion := range r.Assertions {
+		fmt.Fprintf(builder, "%s\n", assertion)
 	}
 
 	return builder.String()
 }
 
-// FilterValue helps implement tea.list.Item.
-//
-// See https://github.com/charmbracelet/bubbles/tree/master/list#adding-custom-items.
-func (r Request) FilterValue() string {
-	return r.Name
+// Header is a single HTTP header in a [Request].
+type Header struct {
+	// The header name e.g. "Accept"
+	Name string `json:"name"`
+
+	// The header value
+	Value string `json:"value"`
+}
+
+// String implements [fmt.Stringer] for a [Header].
+func (h Header) String() string {
+	return h.Name + ": " + h.Value
 }
 
-// Title returns the request's name.
-func (r Request) Title() string {
-	return r.Name
+// Part is a single part of a multipart request body.
+type Part struct {
+	// The part headers e.g. Content-Disposition
+	Headers []Header `json:"headers,omitempty"`
+
+	// If the part is to be read from a local file e.g. '< ./photo.png', this is the path
+	// to that file (relative to the .http file)
+	File string `json:"file,omitempty"`
+
+	// The part content, if provided inline or from a file declared with '<@'
+	Body []byte `json:"body,omitempty"`
 }
 
-// Description returns a description of the request, in this case the method and URL.
-func (r Request) Description() string {
-	return fmt.Sprintf("%s %s", r.Method, r.URL)
+// String implements [fmt.Stringer] for a [Part].
+func (p Part) String() string {
+	builder := &strings.Builder{}
+
+	for _, header := range p.Headers {
+		fmt.Fprintf(builder, "%s\n", header)
+	}
+
+	builder.WriteString("\n")
+
+	if p.File != "" {
+		fmt.Fprintf(builder, "< %s\n", p.File)
+	} else {
+		fmt.Fprintf(builder, "%s\n", string(p.Body))
+	}
+
+	return builder.String()
 }
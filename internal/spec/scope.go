@@ -1,5 +1,7 @@
 package spec
 
+import "go.followtheprocess.codes/req/internal/syntax/interp"
+
 // A Scope represents the environmental scope available from within a .http file, e.g
 // global variables set at the top of the file, builtin functions and identifiers
 // as well as local, request-scoped variables.
@@ -9,6 +11,12 @@ type Scope struct {
 
 	// Local variables, available only to a single request.
 	Local map[string]string
+
+	// Dynamic variables e.g. '$uuid', keyed by name without the leading '$'.
+	Builtins map[string]interp.Builtin
+
+	// Provides values from the responses to other requests e.g. '{{login.response.body.$.token}}'.
+	Responses interp.ResponseFunc
 }
 
 // NewScope returns a new [Scope].
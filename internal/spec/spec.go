@@ -9,13 +9,18 @@
 package spec
 
 import (
-	"bytes"
 	"fmt"
+	"maps"
+	"math/rand/v2"
 	"net/url"
-	"text/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
 )
 
 const (
@@ -41,21 +46,121 @@ func (p Prompt) String() string {
 	return fmt.Sprintf("@prompt %s\n", p.Name)
 }
 
-// ResolveFile converts a [syntax.File] to a [File], performing variable
-// resolution and other validation.
-func ResolveFile(in syntax.File) (File, error) {
-	resolved := File{
-		Name:              in.Name,
-		Timeout:           in.Timeout,
-		ConnectionTimeout: in.ConnectionTimeout,
-		NoRedirect:        in.NoRedirect,
-		Prompts:           resolvePrompts(in.Prompts),
+// A Prompter provides values for variables declared with '@prompt', typically
+// by asking the user.
+type Prompter interface {
+	// Prompt returns the value for the given prompt.
+	Prompt(prompt Prompt) (string, error)
+}
+
+// Option is a functional option for configuring how a [syntax.File] is resolved.
+type Option func(*config)
+
+// config holds the configuration for file resolution, set by applying [Option]s.
+type config struct {
+	prompter  Prompter            // Answers prompts, if nil prompts are left unanswered
+	responses ResponseSource      // Provides the responses of referenced requests, if nil references are left unresolved
+	handler   syntax.ErrorHandler // Called with the detail of any interpolation errors
+	rand      *rand.Rand          // Source of randomness for dynamic variables e.g. '$uuid'
+	now       func() time.Time    // The clock used by dynamic variables e.g. '$timestamp'
+	env       map[string]string   // Variables from the selected environment
+	overrides map[string]string   // Variables that override anything declared in the file
+	dir       string              // The directory relative to which any file paths in the .http file are resolved
+}
+
+// WithDir sets the directory against which relative file paths in the .http file
+// (e.g. '<@ ./body.json') are resolved, this should typically be the directory
+// containing the .http file.
+//
+// If not set, paths are resolved relative to the current working directory.
+func WithDir(dir string) Option {
+	return func(cfg *config) {
+		cfg.dir = dir
+	}
+}
+
+// WithEnv sets the variables from the selected environment (e.g. as loaded from
+// 'http-client.env.json'), these are stored in [Scope.Global].
+//
+// Environment variables have the lowest precedence, any variable of the same name
+// declared in the file itself (globally or in a request) takes priority.
+func WithEnv(env map[string]string) Option {
+	return func(cfg *config) {
+		cfg.env = env
+	}
+}
+
+// WithOverrides sets variables that take precedence over everything else, e.g. those passed
+// on the command line with '--var'.
+//
+// An override replaces a variable of the same name wherever it's declared, globally
+// or in a request, and a prompt for an overridden variable is never asked. Overrides
+// not declared in the file at all are stored in [Scope.Global].
+func WithOverrides(overrides map[string]string) Option {
+	return func(cfg *config) {
+		cfg.overrides = overrides
+	}
+}
+
+// WithErrorHandler sets the [syntax.ErrorHandler] called with the position and detail of
+// any errors interpolating '{{...}}' variables, e.g. a reference to an undefined variable.
+//
+// Without one, resolution still fails on such errors but the detail is lost.
+func WithErrorHandler(handler syntax.ErrorHandler) Option {
+	return func(cfg *config) {
+		cfg.handler = handler
+	}
+}
+
+// WithSeed makes dynamic variables (e.g. '$uuid', '$randomInt' and '$timestamp') deterministic,
+// so the resolved requests are reproducible.
+//
+// Random values are drawn from a source seeded with seed, and the clock is frozen at
+// seed interpreted as a Unix time in seconds.
+func WithSeed(seed uint64) Option {
+	return func(cfg *config) {
+		cfg.rand = rand.New(rand.NewPCG(seed, seed))
+		cfg.now = func() time.Time { return time.Unix(int64(seed), 0).UTC() } //nolint:gosec // Overflow is harmless here
+	}
+}
+
+// WithPrompter sets the [Prompter] used to provide values for '@prompt' variables.
+//
+// Answers to global prompts are stored in [Scope.Global] and answers to request prompts
+// in [Scope.Local]. If no prompter is set, prompts are left unanswered and any attempt
+// to use their values will result in a resolution error.
+func WithPrompter(prompter Prompter) Option {
+	return func(cfg *config) {
+		cfg.prompter = prompter
+	}
+}
+
+// Resolver resolves the requests in a [syntax.File] on demand.
+//
+// The global scope (including the answers to any global prompts) is resolved
+// once when the Resolver is created, but requests are only resolved when asked for. This
+// means a user is only prompted for the requests that are actually needed.
+type Resolver struct {
+	in    syntax.File // The raw file being resolved
+	scope Scope       // The global scope, shared by all requests
+	cfg   config      // The resolution config
+}
+
+// NewResolver returns a new [Resolver] for a [syntax.File], resolving it's global
+// scope in the process.
+func NewResolver(in syntax.File, options ...Option) (*Resolver, error) {
+	cfg := config{}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	if cfg.rand == nil {
+		cfg.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())) //nolint:gosec // Not for anything secure
 	}
 
-	// TODO(@FollowTheProcess): When the prompts get answered, we need to store the answers
-	// in the global scope here, but the local scope when processing request prompts
-	//
-	// We haven't actually integrated prompts yet
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
 
 	// Currently, this works because we don't actually allow template tags in the values of
 	// global variables at a syntax level, so we *know* that they are all fully resolved
@@ -63,14 +168,74 @@ func ResolveFile(in syntax.File) (File, error) {
 	// in order so that a variable defined on line 1 can be used in another defined on line 2
 	// but not vice versa
 	scope := NewScope()
-	scope.Global = in.Vars
-	resolved.Vars = in.Vars
+	scope.Builtins = builtins(cfg)
+	scope.Responses = responses(cfg)
+	maps.Copy(scope.Global, cfg.env)
+	maps.Copy(scope.Global, in.Vars)
+
+	answers, err := answer(in.Prompts, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(scope.Global, answers)
+	maps.Copy(scope.Global, cfg.overrides)
+
+	resolver := &Resolver{
+		in:    in,
+		scope: scope,
+		cfg:   cfg,
+	}
+
+	return resolver, nil
+}
+
+// Request resolves the request with the given name.
+func (r *Resolver) Request(name string) (Request, error) {
+	for _, request := range r.in.Requests {
+		if request.Name == name {
+			return r.resolve(request)
+		}
+	}
+
+	return Request{}, fmt.Errorf("no request named %q", name)
+}
+
+// resolve resolves a single request from the file.
+func (r *Resolver) resolve(request syntax.Request) (Request, error) {
+	resolved, err := resolveRequest(request, r.scope, r.cfg)
+	if err != nil {
+		return Request{}, fmt.Errorf("could not resolve request %s: %w", request.Name, err)
+	}
+
+	return resolved, nil
+}
+
+// ResolveFile converts a [syntax.File] to a [File], performing variable
+// resolution and other validation.
+//
+// Every request in the file is resolved, if only some are needed, prefer
+// a [Resolver].
+func ResolveFile(in syntax.File, options ...Option) (File, error) {
+	resolver, err := NewResolver(in, options...)
+	if err != nil {
+		return File{}, err
+	}
+
+	resolved := File{
+		Name:              in.Name,
+		Vars:              resolver.scope.Global,
+		Timeout:           in.Timeout,
+		ConnectionTimeout: in.ConnectionTimeout,
+		NoRedirect:        in.NoRedirect,
+		Prompts:           resolvePrompts(in.Prompts),
+	}
 
 	resolvedRequests := make([]Request, 0, len(in.Requests))
 	for _, request := range in.Requests {
-		resolved, err := resolveRequest(request, scope)
+		resolved, err := resolver.resolve(request)
 		if err != nil {
-			return File{}, fmt.Errorf("could not resolve request %s: %w", request.Name, err)
+			return File{}, err
 		}
 
 		resolvedRequests = append(resolvedRequests, resolved)
@@ -90,6 +255,33 @@ func ResolveFile(in syntax.File) (File, error) {
 	return resolved, nil
 }
 
+// answer asks the prompter for the value of each prompt, returning the answers
+// keyed by the prompt name. Overridden prompts are answered with the override
+// without asking, and if there is no prompter the rest are left unanswered.
+func answer(prompts []syntax.Prompt, cfg config) (map[string]string, error) {
+	answers := make(map[string]string, len(prompts))
+
+	for _, prompt := range prompts {
+		if value, ok := cfg.overrides[prompt.Name]; ok {
+			answers[prompt.Name] = value
+			continue
+		}
+
+		if cfg.prompter == nil {
+			continue
+		}
+
+		value, err := cfg.prompter.Prompt(Prompt{Name: prompt.Name, Description: prompt.Description})
+		if err != nil {
+			return nil, fmt.Errorf("could not get value for prompt %s: %w", prompt.Name, err)
+		}
+
+		answers[prompt.Name] = value
+	}
+
+	return answers, nil
+}
+
 // resolvePrompts converts a []syntax.Prompt to a []Prompt.
 func resolvePrompts(in []syntax.Prompt) []Prompt {
 	resolved := make([]Prompt, 0, len(in))
@@ -105,98 +297,174 @@ func resolvePrompts(in []syntax.Prompt) []Prompt {
 //
 // Note that scope is passed by value, this is because we want local variable isolation
 // in each request, and this is a nice easy way of doing that.
-func resolveRequest(in syntax.Request, scope Scope) (Request, error) {
+func resolveRequest(in syntax.Request, scope Scope, cfg config) (Request, error) {
 	// All stuff that needs no transformation
 	resolved := Request{
-		Name:              in.Name,
-		Comment:           in.Comment,
-		Prompts:           resolvePrompts(in.Prompts),
-		Method:            in.Method,
-		BodyFile:          in.BodyFile,
-		ResponseFile:      in.ResponseFile,
-		Timeout:           in.Timeout,
-		ConnectionTimeout: in.ConnectionTimeout,
-		NoRedirect:        in.NoRedirect,
+		Name:               in.Name,
+		Comment:            in.Comment,
+		Prompts:            resolvePrompts(in.Prompts),
+		Method:             in.Method,
+		BodyFile:           in.BodyFile,
+		ResponseFile:       in.ResponseFile,
+		UniqueResponseFile: in.UniqueResponseFile,
+		ResponseReference:  in.ResponseReference,
+		Timeout:            in.Timeout,
+		ConnectionTimeout:  in.ConnectionTimeout,
+		NoRedirect:         in.NoRedirect,
+		Proto:              in.Proto,
 	}
 
-	buf := &bytes.Buffer{}
+	// Answers to any request prompts are stored as local variables, available to the
+	// rest of the request (including it's other variables)
+	answers, err := answer(in.Prompts, cfg)
+	if err != nil {
+		return Request{}, err
+	}
+
+	scope.Local = answers
+
+	if len(answers) > 0 {
+		resolved.Vars = answers
+	}
 
 	// No point allocating a Vars map if it has no local variables
 	if len(in.Vars) > 0 {
-		resolvedVars := make(map[string]string, len(in.Vars))
+		resolvedVars := maps.Clone(answers)
+		interpolator := interp.New(interp.Scope(scope), cfg.handler)
 
-		for key, value := range in.Vars {
-			name := fmt.Sprintf("Request %s/Var %s", in.Name, key)
-			tmp, err := template.New(name).Option("missingkey=error").Parse(value)
-			if err != nil {
-				return Request{}, fmt.Errorf("invalid template syntax in var %s: %w", key, err)
-			}
-			if err = tmp.Execute(buf, scope); err != nil {
-				return Request{}, fmt.Errorf("failed to execute request variable templating for request %s: %w", in.Name, err)
+		// Sorted so that dynamic values and dependencies are resolved in a deterministic order
+		for _, key := range slices.Sorted(maps.Keys(in.Vars)) {
+			value := in.Vars[key]
+
+			if override, ok := cfg.overrides[key]; ok {
+				resolvedVars[key] = override
+				continue
 			}
 
-			resolvedVars[key] = buf.String()
+			resolvedVar, err := interpolator.Interpolate(value, in.Positions.Vars[key])
+			if err != nil {
+				return Request{}, fmt.Errorf("could not resolve request variable %s: %w", key, err)
+			}
 
-			// Clear the buffer for the next iteration
-			buf.Reset()
+			resolvedVars[key] = resolvedVar
 		}
 
 		// Note: Affecting the copy of scope in this function only
 		scope.Local = resolvedVars
 		resolved.Vars = resolvedVars
-
-		// Might as well reuse the same buffer later
-		buf.Reset()
 	}
 
-	resolvedHeaders := make(map[string]string, len(in.Headers))
+	interpolator := interp.New(interp.Scope(scope), cfg.handler)
 
-	for key, value := range in.Headers {
-		name := fmt.Sprintf("Request %s/Header %s", in.Name, key)
-		tmp, err := template.New(name).Option("missingkey=error").Parse(value)
+	// Headers keep their order and any duplicates. When sent, only the order of the values
+	// of each name survives, net/http decides the order of different names
+	for _, header := range in.Headers {
+		value, err := interpolator.Interpolate(header.Value, header.Pos)
 		if err != nil {
-			return Request{}, fmt.Errorf("invalid template syntax in header %s: %w", key, err)
-		}
-		if err = tmp.Execute(buf, scope); err != nil {
-			return Request{}, fmt.Errorf("failed to execute request header templating for request %s: %w", in.Name, err)
+			return Request{}, fmt.Errorf("could not resolve header %s: %w", header.Name, err)
 		}
 
-		resolvedHeaders[key] = buf.String()
-		buf.Reset()
+		resolved.Headers = append(resolved.Headers, Header{Name: header.Name, Value: value})
 	}
 
-	resolved.Headers = resolvedHeaders
-
 	// Now for the URL
-	buf.Reset()
-	tmp, err := template.New(fmt.Sprintf("Request %s/URL", in.Name)).Option("missingkey=error").Parse(in.URL)
+	resolvedURL, err := interpolator.Interpolate(in.URL, in.Positions.URL)
 	if err != nil {
-		return Request{}, fmt.Errorf("invalid template syntax in URL %s: %w", in.URL, err)
-	}
-	if err = tmp.Execute(buf, scope); err != nil {
-		return Request{}, fmt.Errorf("failed to execute URL templating for request %s: %w", in.Name, err)
+		return Request{}, fmt.Errorf("could not resolve URL: %w", err)
 	}
 
-	// Now URL templates have been resolved, it must be a valid URL
-	resolvedURL := buf.String()
-	_, err = url.ParseRequestURI(resolvedURL)
-	if err != nil {
-		return Request{}, fmt.Errorf("invalid URL for request %s: %w", in.Name, err)
+	// Now URL templates have been resolved, it must be a valid URL. Except for gRPC,
+	// whose target is checked along with the rest of the request
+	if in.Method != MethodGRPC {
+		_, err = url.ParseRequestURI(resolvedURL)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid URL for request %s: %w", in.Name, err)
+		}
 	}
 
 	resolved.URL = resolvedURL
 
 	// Lastly, the body
-	buf.Reset()
-	tmp, err = template.New(fmt.Sprintf("Request %s/Body", in.Name)).Option("missingkey=error").Parse(string(in.Body))
-	if err != nil {
-		return Request{}, fmt.Errorf("invalid template syntax in request %s body: %w", in.Name, err)
+	if in.Body != nil {
+		body, err := interpolator.Interpolate(string(in.Body), in.Positions.Body)
+		if err != nil {
+			return Request{}, fmt.Errorf("could not resolve body: %w", err)
+		}
+
+		resolved.Body = []byte(body)
+
+		// A multipart body is split into it's parts, so any files in it can be streamed
+		// when the request is sent
+		if boundary, ok := Boundary(resolved.Header("Content-Type")); ok {
+			resolved.Parts, err = resolveParts(in, body, boundary, interpolator, cfg)
+			if err != nil {
+				return Request{}, err
+			}
+
+			resolved.Body = nil
+		}
 	}
-	if err = tmp.Execute(buf, scope); err != nil {
-		return Request{}, fmt.Errorf("failed to execute templating for request %s body: %w", in.Name, err)
+
+	// A form's fields are interpolated one at a time so each value can be encoded on
+	// it's own, whatever characters it ends up with
+	if len(in.Form) > 0 {
+		body, err := resolveForm(in.Form, interpolator)
+		if err != nil {
+			return Request{}, err
+		}
+
+		resolved.Body = body
 	}
 
-	resolved.Body = buf.Bytes()
+	// If the body file was declared with '<@', it's contents must be interpolated
+	// and so it becomes the body. Otherwise it's left to be streamed from disk
+	// when the request is sent.
+	if in.InterpolateBodyFile {
+		body, err := resolveBodyFile(in, interpolator, cfg)
+		if err != nil {
+			return Request{}, err
+		}
+
+		resolved.Body = body
+		resolved.BodyFile = ""
+	}
+
+	// A GraphQL request is sent as a JSON POST, whichever way it was declared
+	if isGraphQL(in) {
+		resolved, err = resolveGraphQL(in, resolved)
+		if err != nil {
+			return Request{}, err
+		}
+	}
+
+	if in.Method == MethodWebSocket {
+		resolved, err = resolveWebSocket(in, resolved)
+		if err != nil {
+			return Request{}, err
+		}
+	}
+
+	if in.Method == MethodGRPC {
+		resolved, err = resolveGRPC(in, resolved)
+		if err != nil {
+			return Request{}, err
+		}
+	}
+
+	for _, assertion := range in.Assertions {
+		value, err := interpolator.Interpolate(assertion.Value, assertion.ValuePos)
+		if err != nil {
+			return Request{}, fmt.Errorf("could not resolve assertion %q: %w", assertion, err)
+		}
+
+		resolved.Assertions = append(resolved.Assertions, Assertion{
+			Subject:  assertion.Subject,
+			Selector: assertion.Selector,
+			Operator: assertion.Operator,
+			Value:    value,
+			Pos:      assertion.Pos,
+		})
+	}
 
 	// Ensure we have sensible default timeouts if none were set
 	if resolved.Timeout == 0 {
@@ -209,3 +477,47 @@ func resolveRequest(in syntax.Request, scope Scope) (Request, error) {
 
 	return resolved, nil
 }
+
+// resolveBodyFile reads the body file for a request and performs variable interpolation
+// on it's contents, returning the resolved body.
+func resolveBodyFile(in syntax.Request, interpolator *interp.Interpolator, cfg config) ([]byte, error) {
+	path := in.BodyFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.dir, path)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read body file for request %s: %w", in.Name, err)
+	}
+
+	// The body file is it's own source, so errors point into it rather than the .http file
+	pos := syntax.Position{Name: path, Line: 1, StartCol: 1, EndCol: 1}
+
+	body, err := interpolator.Interpolate(string(contents), pos)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve body file %s: %w", in.BodyFile, err)
+	}
+
+	return []byte(body), nil
+}
+
+// resolveForm performs variable interpolation on the value of each field of a form
+// urlencoded body, returning the body e.g. 'name=foo&password=s%26cret'.
+//
+// The text of the form is already urlencoded so is kept as written, only the values of
+// variables are percent-encoded.
+func resolveForm(form []syntax.Field, interpolator *interp.Interpolator) ([]byte, error) {
+	fields := make([]string, 0, len(form))
+
+	for _, field := range form {
+		value, err := interpolator.InterpolateEscaped(field.Value, field.Pos, url.QueryEscape)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve form field %s: %w", field.Name, err)
+		}
+
+		fields = append(fields, field.Name+"="+value)
+	}
+
+	return []byte(strings.Join(fields, "&")), nil
+}
@@ -3,13 +3,20 @@ package spec_test
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"go.followtheprocess.codes/req/internal/spec"
 	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/parser"
 	"go.followtheprocess.codes/snapshot"
 	"go.followtheprocess.codes/test"
 	"go.followtheprocess.codes/txtar"
@@ -68,6 +75,567 @@ func TestResolve(t *testing.T) {
 	}
 }
 
+func TestResolvePrompts(t *testing.T) {
+	in := syntax.File{
+		Name:    "prompts.http",
+		Vars:    map[string]string{"base": "https://api.com/v1"},
+		Prompts: []syntax.Prompt{{Name: "token", Description: "API token"}},
+		Requests: []syntax.Request{
+			{
+				Name:    "GetUser",
+				Prompts: []syntax.Prompt{{Name: "id"}},
+				Vars:    map[string]string{"path": "users/{{.Local.id}}"},
+				Method:  http.MethodGet,
+				URL:     "{{.Global.base}}/{{.Local.path}}",
+				Headers: []syntax.Header{{Name: "Authorization", Value: "Bearer {{.Global.token}}"}},
+			},
+			{
+				Name:    "GetItem",
+				Prompts: []syntax.Prompt{{Name: "item"}},
+				Method:  http.MethodGet,
+				URL:     "{{.Global.base}}/items/{{.Local.item}}",
+			},
+		},
+	}
+
+	t.Run("resolver", func(t *testing.T) {
+		prompter := &fakePrompter{answers: map[string]string{"token": "secret", "id": "123"}}
+
+		resolver, err := spec.NewResolver(in, spec.WithPrompter(prompter))
+		test.Ok(t, err)
+
+		// Only the global prompts should have been asked so far
+		test.EqualFunc(t, prompter.asked, []string{"token"}, slices.Equal)
+
+		request, err := resolver.Request("GetUser")
+		test.Ok(t, err)
+
+		// Only this request's prompts should have been asked, not GetItem's
+		test.EqualFunc(t, prompter.asked, []string{"token", "id"}, slices.Equal)
+
+		test.Equal(t, request.URL, "https://api.com/v1/users/123")
+		test.Equal(t, request.Header("Authorization"), "Bearer secret")
+		test.Equal(t, request.Vars["id"], "123")
+		test.Equal(t, request.Vars["path"], "users/123")
+
+		_, err = resolver.Request("Missing")
+		test.Err(t, err)
+	})
+
+	t.Run("resolve file", func(t *testing.T) {
+		prompter := &fakePrompter{answers: map[string]string{"token": "secret", "id": "123", "item": "abc"}}
+
+		resolved, err := spec.ResolveFile(in, spec.WithPrompter(prompter))
+		test.Ok(t, err)
+
+		test.EqualFunc(t, prompter.asked, []string{"token", "id", "item"}, slices.Equal)
+		test.Equal(t, resolved.Vars["token"], "secret")
+		test.Equal(t, resolved.Requests[1].URL, "https://api.com/v1/items/abc")
+	})
+
+	t.Run("prompter error", func(t *testing.T) {
+		prompter := &fakePrompter{answers: map[string]string{"token": "secret"}}
+
+		resolver, err := spec.NewResolver(in, spec.WithPrompter(prompter))
+		test.Ok(t, err)
+
+		_, err = resolver.Request("GetUser")
+		test.Err(t, err)
+	})
+
+	t.Run("no prompter", func(t *testing.T) {
+		// Prompts are left unanswered so using their value is an error
+		_, err := spec.ResolveFile(in)
+		test.Err(t, err)
+	})
+}
+
+func TestResolveEnv(t *testing.T) {
+	in := syntax.File{
+		Name: "env.http",
+		Vars: map[string]string{"base": "https://api.com"},
+		Requests: []syntax.Request{
+			{
+				Name:   "GetUser",
+				Vars:   map[string]string{"token": "local"},
+				Method: http.MethodGet,
+				URL:    "{{.Global.base}}/{{.Global.version}}/users",
+				Headers: []syntax.Header{
+					{Name: "Authorization", Value: "Bearer {{.Local.token}}"},
+					{Name: "X-Env", Value: "{{.Global.token}}"},
+				},
+			},
+		},
+	}
+
+	env := map[string]string{
+		"base":    "http://localhost:8080",
+		"version": "v1",
+		"token":   "from-env",
+	}
+
+	resolved, err := spec.ResolveFile(in, spec.WithEnv(env))
+	test.Ok(t, err)
+
+	// Variables declared in the file take precedence over the environment
+	test.Equal(t, resolved.Vars["base"], "https://api.com")
+	test.Equal(t, resolved.Vars["version"], "v1")
+
+	request := resolved.Requests[0]
+	test.Equal(t, request.URL, "https://api.com/v1/users")
+	test.Equal(t, request.Header("Authorization"), "Bearer local")
+	test.Equal(t, request.Header("X-Env"), "from-env")
+}
+
+func TestResolveOverrides(t *testing.T) {
+	in := syntax.File{
+		Name:    "overrides.http",
+		Vars:    map[string]string{"base": "https://api.com"},
+		Prompts: []syntax.Prompt{{Name: "token"}},
+		Requests: []syntax.Request{
+			{
+				Name:    "GetUser",
+				Prompts: []syntax.Prompt{{Name: "id"}},
+				Vars:    map[string]string{"version": "v1", "path": "users/{{.Local.id}}"},
+				Method:  http.MethodGet,
+				URL:     "{{.Global.base}}/{{.Local.version}}/{{.Local.path}}",
+				Headers: []syntax.Header{
+					{Name: "Authorization", Value: "Bearer {{.Global.token}}"},
+					{Name: "X-Extra", Value: "{{.Global.extra}}"},
+				},
+			},
+		},
+	}
+
+	env := map[string]string{"base": "https://env.com"}
+
+	overrides := map[string]string{
+		"base":    "http://localhost:8080",
+		"version": "v2",
+		"token":   "overridden",
+		"id":      "123",
+		"extra":   "not in file",
+	}
+
+	prompter := &fakePrompter{answers: map[string]string{"token": "secret", "id": "456"}}
+
+	resolved, err := spec.ResolveFile(in, spec.WithEnv(env), spec.WithOverrides(overrides), spec.WithPrompter(prompter))
+	test.Ok(t, err)
+
+	// Overridden prompts should never be asked
+	test.Equal(t, len(prompter.asked), 0)
+
+	test.Equal(t, resolved.Vars["base"], "http://localhost:8080")
+	test.Equal(t, resolved.Vars["extra"], "not in file")
+
+	request := resolved.Requests[0]
+	test.Equal(t, request.URL, "http://localhost:8080/v2/users/123")
+	test.Equal(t, request.Vars["version"], "v2")
+	test.Equal(t, request.Header("Authorization"), "Bearer overridden")
+	test.Equal(t, request.Header("X-Extra"), "not in file")
+}
+
+func TestResolveInterpolation(t *testing.T) {
+	src := `@id = global
+@base = https://api.com
+
+### Get a user
+# @name GetUser
+# @id = 123
+# @path = users/{{token}}
+GET {{base}}/users/{{id}}
+Authorization: Bearer {{ token }}
+
+{"id": "{{id}}", "base": "{{.Global.base}}"}
+
+### Broken
+# @name Broken
+GET {{base}}/items/{{item}}
+`
+
+	p, err := parser.New("interp.http", strings.NewReader(src), nil)
+	test.Ok(t, err)
+
+	in, err := p.Parse()
+	test.Ok(t, err)
+
+	var errs []string
+
+	handler := func(pos syntax.Position, msg string) {
+		errs = append(errs, fmt.Sprintf("%s: %s", pos, msg))
+	}
+
+	resolver, err := spec.NewResolver(
+		in,
+		spec.WithOverrides(map[string]string{"token": "secret"}),
+		spec.WithErrorHandler(handler),
+	)
+	test.Ok(t, err)
+
+	request, err := resolver.Request("GetUser")
+	test.Ok(t, err)
+
+	// Locals win over globals of the same name
+	test.Equal(t, request.Vars["path"], "users/secret")
+	test.Equal(t, request.URL, "https://api.com/users/123")
+	test.Equal(t, request.Header("Authorization"), "Bearer secret")
+	test.Equal(t, string(request.Body), `{"id": "123", "base": "https://api.com"}`)
+	test.Equal(t, len(errs), 0)
+
+	_, err = resolver.Request("Broken")
+	test.Err(t, err)
+
+	// The error should point precisely at the offending tag
+	want := `interp.http:15:20-28: undefined variable "item"`
+	test.Diff(t, strings.Join(errs, "\n"), want)
+}
+
+func TestResolveBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	test.Ok(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=from-dotenv\n"), 0o644))
+
+	t.Setenv("REQ_TEST_TOKEN", "from-env")
+
+	in := syntax.File{
+		Name: "builtins.http",
+		Vars: map[string]string{"tokenVar": "REQ_TEST_TOKEN"},
+		Requests: []syntax.Request{
+			{
+				Name:   "Builtins",
+				Method: http.MethodPost,
+				URL:    "https://api.com/items/{{$randomInt}}",
+				Headers: []syntax.Header{
+					{Name: "Idempotency-Key", Value: "{{$uuid}}"},
+					{Name: "X-Request-Id", Value: "{{$uuid}}"},
+					{Name: "X-Timestamp", Value: "{{$timestamp}}"},
+					{Name: "X-Iso", Value: "{{$isoTimestamp}}"},
+					{Name: "X-Small", Value: "{{$randomInt 5 6}}"},
+					{Name: "Authorization", Value: "Bearer {{$processEnv %tokenVar}}"},
+					{Name: "X-Secret", Value: "{{$dotenv SECRET}}"},
+				},
+				Body: []byte(`{"a": "{{$guid}}", "b": "{{$random.uuid}}"}`),
+			},
+		},
+	}
+
+	resolve := func(t *testing.T, options ...spec.Option) spec.Request {
+		t.Helper()
+
+		options = append(options, spec.WithDir(dir))
+		resolved, err := spec.ResolveFile(in, options...)
+		test.Ok(t, err)
+
+		return resolved.Requests[0]
+	}
+
+	t.Run("values", func(t *testing.T) {
+		request := resolve(t)
+
+		uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+		test.True(t, uuid.MatchString(request.Header("Idempotency-Key")), test.Context("bad uuid %q", request.Header("Idempotency-Key")))
+
+		timestamp, err := strconv.ParseInt(request.Header("X-Timestamp"), 10, 64)
+		test.Ok(t, err)
+		test.True(t, time.Since(time.Unix(timestamp, 0)) < time.Minute, test.Context("timestamp %d is not now", timestamp))
+
+		_, err = time.Parse(time.RFC3339, request.Header("X-Iso"))
+		test.Ok(t, err)
+
+		test.Equal(t, request.Header("X-Small"), "5")
+		test.Equal(t, request.Header("Authorization"), "Bearer from-env")
+		test.Equal(t, request.Header("X-Secret"), "from-dotenv")
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		first := resolve(t, spec.WithSeed(42))
+		second := resolve(t, spec.WithSeed(42))
+		other := resolve(t, spec.WithSeed(43))
+
+		test.Equal(t, first.URL, second.URL)
+		test.Equal(t, first.Header("Idempotency-Key"), second.Header("Idempotency-Key"))
+		test.Equal(t, first.Header("X-Request-Id"), second.Header("X-Request-Id"))
+		test.Equal(t, string(first.Body), string(second.Body))
+		test.True(t, first.Header("Idempotency-Key") != other.Header("Idempotency-Key"), test.Context("different seeds, same uuid"))
+
+		// Each reference gets a new value
+		var body map[string]string
+		test.Ok(t, json.Unmarshal(first.Body, &body))
+		test.True(t, body["a"] != body["b"], test.Context("uuids were equal: %s", body["a"]))
+
+		// The clock is frozen at the seed
+		test.Equal(t, first.Header("X-Timestamp"), "42")
+		test.Equal(t, first.Header("X-Iso"), "1970-01-01T00:00:42Z")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name string // Name of the test case
+			body string // Request body using the builtin
+		}{
+			{name: "unset env", body: "{{$processEnv REQ_TEST_DEFINITELY_NOT_SET}}"},
+			{name: "unset dotenv", body: "{{$dotenv MISSING}}"},
+			{name: "bad range", body: "{{$randomInt 10 1}}"},
+			{name: "bad int", body: "{{$randomInt one 10}}"},
+			{name: "unexpected args", body: "{{$uuid 4}}"},
+			{name: "unknown", body: "{{$nope}}"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				broken := syntax.File{
+					Name: "broken.http",
+					Requests: []syntax.Request{
+						{Name: "Broken", Method: http.MethodPost, URL: "https://api.com", Body: []byte(tt.body)},
+					},
+				}
+
+				_, err := spec.ResolveFile(broken, spec.WithDir(dir))
+				test.Err(t, err)
+			})
+		}
+	})
+}
+
+// responseMap is a [spec.ResponseSource] of canned responses.
+type responseMap map[string]spec.Response
+
+func (r responseMap) Response(name string) (spec.Response, error) {
+	response, ok := r[name]
+	if !ok {
+		return spec.Response{}, fmt.Errorf("no response to %s", name)
+	}
+
+	return response, nil
+}
+
+func TestResolveResponses(t *testing.T) {
+	src := `### Log in
+# @name Login
+POST https://api.com/login
+
+### Get a user
+# @name GetUser
+# @token = {{Login.response.body.$.token}}
+GET https://api.com/users/{{Login.response.body.$.users[-1].id}}
+Authorization: Bearer {{token}}
+X-Session: {{Login.response.headers.X-Session}}
+
+{"login": {{Login.response.body}}}
+`
+
+	p, err := parser.New("chain.http", strings.NewReader(src), nil)
+	test.Ok(t, err)
+
+	in, err := p.Parse()
+	test.Ok(t, err)
+
+	t.Run("sent", func(t *testing.T) {
+		source := responseMap{
+			"Login": {
+				Header:     http.Header{"X-Session": []string{"abc"}},
+				Body:       []byte(`{"token":"s3cr3t","users":[{"id":1},{"id":2}]}`),
+				StatusCode: http.StatusOK,
+			},
+		}
+
+		resolver, err := spec.NewResolver(in, spec.WithResponses(source))
+		test.Ok(t, err)
+
+		request, err := resolver.Request("GetUser")
+		test.Ok(t, err)
+
+		test.Equal(t, request.URL, "https://api.com/users/2")
+		test.Equal(t, request.Header("Authorization"), "Bearer s3cr3t")
+		test.Equal(t, request.Header("X-Session"), "abc")
+		test.Equal(t, string(request.Body), `{"login": {"token":"s3cr3t","users":[{"id":1},{"id":2}]}}`)
+	})
+
+	t.Run("unsent", func(t *testing.T) {
+		// Without a source, references are left as they are
+		resolver, err := spec.NewResolver(in)
+		test.Ok(t, err)
+
+		request, err := resolver.Request("GetUser")
+		test.Ok(t, err)
+
+		test.Equal(t, request.URL, "https://api.com/users/{{Login.response.body.$.users[-1].id}}")
+		test.Equal(t, request.Header("X-Session"), "{{Login.response.headers.X-Session}}")
+	})
+}
+
+func TestCheckReferences(t *testing.T) {
+	tests := []struct {
+		name string // Name of the test case
+		src  string // The .http source
+		want string // The expected errors, one per line
+	}{
+		{
+			name: "valid",
+			src: `### A
+# @name A
+GET https://api.com/a
+
+### B
+# @name B
+GET https://api.com/b/{{A.response.body.$.id}}
+`,
+			want: "",
+		},
+		{
+			name: "unknown request",
+			src: `### A
+# @name A
+GET https://api.com/a/{{Missing.response.body.$.id}}
+`,
+			want: `refs.http:3:23-53: reference to unknown request "Missing"`,
+		},
+		{
+			name: "unknown request in form",
+			src: `### A
+# @name A
+POST https://api.com/a
+Content-Type: application/x-www-form-urlencoded
+
+token={{Missing.response.body.$.token}}
+`,
+			want: `refs.http:6:7-40: reference to unknown request "Missing"`,
+		},
+		{
+			name: "bad part",
+			src: `### A
+# @name A
+GET https://api.com/a
+
+### B
+# @name B
+GET https://api.com/b/{{A.response.status}}
+`,
+			want: `refs.http:7:23-44: bad reference to the response of A, expected 'body' or 'headers', got "status"`,
+		},
+		{
+			name: "self",
+			src: `### A
+# @name A
+GET https://api.com/a/{{A.response.body.$.id}}
+`,
+			want: `refs.http:3:23-47: dependency cycle: A -> A`,
+		},
+		{
+			name: "cycle",
+			src: `### A
+# @name A
+GET https://api.com/a/{{C.response.body.$.id}}
+
+### B
+# @name B
+GET https://api.com/b/{{A.response.body.$.id}}
+
+### C
+# @name C
+GET https://api.com/c
+X-B: {{B.response.headers.X-Id}}
+`,
+			want: `refs.http:7:23-47: dependency cycle: A -> C -> B -> A`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parser.New("refs.http", strings.NewReader(tt.src), nil)
+			test.Ok(t, err)
+
+			in, err := p.Parse()
+			test.Ok(t, err)
+
+			var errs []string
+
+			handler := func(pos syntax.Position, msg string) {
+				errs = append(errs, fmt.Sprintf("%s: %s", pos, msg))
+			}
+
+			err = spec.CheckReferences(in, handler)
+			test.Equal(t, err != nil, tt.want != "", test.Context("CheckReferences returned %v", err))
+			test.Diff(t, strings.Join(errs, "\n"), tt.want)
+		})
+	}
+}
+
+func TestResolveWebSocket(t *testing.T) {
+	tests := []struct {
+		name    string // Name of the test case
+		src     string // The .http source
+		wantErr string // The expected error
+	}{
+		{
+			name:    "http url",
+			src:     "### Chat\nWEBSOCKET https://chat.com/ws\n\n===\nhello\n",
+			wantErr: `could not resolve request #1: WebSocket request #1 must have a ws:// or wss:// URL, got "https://chat.com/ws"`,
+		},
+		{
+			name:    "unknown separator",
+			src:     "### Chat\nWEBSOCKET ws://chat.com/ws\n\n=== wait-for-client\nhello\n",
+			wantErr: `could not resolve request #1: invalid WebSocket request #1: unknown message separator "=== wait-for-client", expected "===" or "=== wait-for-server"`,
+		},
+		{
+			name:    "body file",
+			src:     "### Chat\nWEBSOCKET ws://chat.com/ws\n\n< ./messages.txt\n",
+			wantErr: `could not resolve request #1: WebSocket request #1 must have an inline body or a '<@' body file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parser.New("websocket.http", strings.NewReader(tt.src), nil)
+			test.Ok(t, err)
+
+			in, err := p.Parse()
+			test.Ok(t, err)
+
+			_, err = spec.ResolveFile(in)
+			test.Err(t, err)
+			test.Equal(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestResolveGRPC(t *testing.T) {
+	tests := []struct {
+		name    string // Name of the test case
+		src     string // The .http source
+		wantErr string // The expected error
+	}{
+		{
+			name:    "no method",
+			src:     "### Health\nGRPC localhost:50051/grpc.health.v1.Health\n",
+			wantErr: `could not resolve request #1: invalid gRPC request #1: gRPC URL must be host:port/package.Service/Method, got "localhost:50051/grpc.health.v1.Health"`,
+		},
+		{
+			name:    "bad scheme",
+			src:     "### Health\nGRPC ws://localhost:50051/grpc.health.v1.Health/Check\n",
+			wantErr: `could not resolve request #1: invalid gRPC request #1: gRPC URL scheme must be grpc, grpcs, http or https, got "ws"`,
+		},
+		{
+			name:    "body file",
+			src:     "### Health\nGRPC localhost:50051/grpc.health.v1.Health/Check\n\n< ./check.json\n",
+			wantErr: `could not resolve request #1: gRPC request #1 must have an inline body or a '<@' body file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parser.New("grpc.http", strings.NewReader(tt.src), nil)
+			test.Ok(t, err)
+
+			in, err := p.Parse()
+			test.Ok(t, err)
+
+			_, err = spec.ResolveFile(in)
+			test.Err(t, err)
+			test.Equal(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
 func TestFormat(t *testing.T) {
 	tests := []struct {
 		name string    // Name of the test case
@@ -184,10 +752,10 @@ func TestFormat(t *testing.T) {
 						Name:   "Another Request",
 						Method: http.MethodPost,
 						URL:    "https://api.com/v1/items/123",
-						Headers: map[string]string{
-							"Accept":        "application/json",
-							"Content-Type":  "application/json",
-							"Authorization": "Bearer xxxxx",
+						Headers: []spec.Header{
+							{Name: "Accept", Value: "application/json"},
+							{Name: "Content-Type", Value: "application/json"},
+							{Name: "Authorization", Value: "Bearer xxxxx"},
 						},
 					},
 				},
@@ -263,6 +831,23 @@ func TestFormat(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "request with response reference",
+			file: spec.File{
+				Name: "Requests",
+				Vars: map[string]string{
+					"base": "https://api.com/v1",
+				},
+				Requests: []spec.Request{
+					{
+						Name:              "Another Request",
+						Method:            http.MethodGet,
+						URL:               "https://api.com/v1/items/123",
+						ResponseReference: "./previous.200.json",
+					},
+				},
+			},
+		},
 		{
 			name: "request with prompt",
 			file: spec.File{
@@ -283,6 +868,39 @@ func TestFormat(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "websocket request",
+			file: spec.File{
+				Name: "Requests",
+				Requests: []spec.Request{
+					{
+						Name:   "Chat",
+						Method: spec.MethodWebSocket,
+						URL:    "wss://chat.com/ws",
+						Messages: []spec.Message{
+							{Body: `{"message": "hello"}`},
+							{Body: `{"message": "bye"}`, Wait: 2},
+							{Wait: 1},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "grpc request",
+			file: spec.File{
+				Name: "Requests",
+				Requests: []spec.Request{
+					{
+						Name:   "Health",
+						Method: spec.MethodGRPC,
+						URL:    "localhost:50051/grpc.health.v1.Health/Check",
+						Proto:  "protos/health.proto",
+						Body:   []byte(`{"service": "api"}`),
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -292,3 +910,163 @@ func TestFormat(t *testing.T) {
 		})
 	}
 }
+
+// fakePrompter is a [spec.Prompter] that answers from a map and records
+// which prompts it was asked.
+type fakePrompter struct {
+	answers map[string]string
+	asked   []string
+}
+
+func (f *fakePrompter) Prompt(prompt spec.Prompt) (string, error) {
+	f.asked = append(f.asked, prompt.Name)
+
+	answer, ok := f.answers[prompt.Name]
+	if !ok {
+		return "", fmt.Errorf("no answer for %s", prompt.Name)
+	}
+
+	return answer, nil
+}
+
+func TestAssertionCheck(t *testing.T) {
+	response := spec.Response{
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+			"Vary":         []string{"Accept", "Origin"},
+		},
+		Body:       []byte(`{"id": 9007199254740993, "items": [1, 2, 3], "name": "req", "price": 9.5}`),
+		StatusCode: http.StatusOK,
+	}
+
+	tests := []struct {
+		name      string         // Name of the test case
+		errMsg    string         // If we wanted an error, what should it say
+		assertion spec.Assertion // The assertion to check
+		wantErr   bool           // Whether we want an error
+	}{
+		{
+			name:      "status equal",
+			assertion: spec.Assertion{Subject: "status", Operator: "==", Value: "200"},
+			wantErr:   false,
+		},
+		{
+			name:      "status not equal",
+			assertion: spec.Assertion{Subject: "status", Operator: "==", Value: "201"},
+			wantErr:   true,
+			errMsg:    `expected status == 201, got "200"`,
+		},
+		{
+			name:      "status less than",
+			assertion: spec.Assertion{Subject: "status", Operator: "<", Value: "400"},
+			wantErr:   false,
+		},
+		{
+			name:      "header contains",
+			assertion: spec.Assertion{Subject: "header", Selector: "Content-Type", Operator: "contains", Value: "json"},
+			wantErr:   false,
+		},
+		{
+			name:      "header multiple values",
+			assertion: spec.Assertion{Subject: "header", Selector: "vary", Operator: "==", Value: `"Accept, Origin"`},
+			wantErr:   false,
+		},
+		{
+			name:      "header exists",
+			assertion: spec.Assertion{Subject: "header", Selector: "Content-Type", Operator: "exists"},
+			wantErr:   false,
+		},
+		{
+			name:      "header not exists",
+			assertion: spec.Assertion{Subject: "header", Selector: "X-Missing", Operator: "!exists"},
+			wantErr:   false,
+		},
+		{
+			name:      "header missing",
+			assertion: spec.Assertion{Subject: "header", Selector: "X-Missing", Operator: "==", Value: "yes"},
+			wantErr:   true,
+			errMsg:    "expected header X-Missing == yes, but header X-Missing is missing",
+		},
+		{
+			name:      "body contains",
+			assertion: spec.Assertion{Subject: "body", Operator: "contains", Value: `"name"`},
+			wantErr:   false,
+		},
+		{
+			name:      "body does not contain",
+			assertion: spec.Assertion{Subject: "body", Operator: "contains", Value: "error"},
+			wantErr:   true,
+			errMsg:    `expected body contains error, got "{\"id\": 9007199254740993, \"items\": [1, 2, 3], \"name\": \"req\", \"price\": 9.5}"`,
+		},
+		{
+			name:      "body not contains",
+			assertion: spec.Assertion{Subject: "body", Operator: "!contains", Value: "error"},
+			wantErr:   false,
+		},
+		{
+			name:      "body length",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.items.length", Operator: ">", Value: "0"},
+			wantErr:   false,
+		},
+		{
+			name:      "body numeric equality",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.price", Operator: "==", Value: "9.50"},
+			wantErr:   false,
+		},
+		{
+			name:      "body large integer equal",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.id", Operator: "==", Value: "9007199254740993"},
+			wantErr:   false,
+		},
+		{
+			name:      "body large integer not equal",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.id", Operator: "==", Value: "9007199254740992"},
+			wantErr:   true,
+			errMsg:    `expected body $.id == 9007199254740992, got "9007199254740993"`,
+		},
+		{
+			name:      "body large integer greater",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.id", Operator: ">", Value: "9007199254740992"},
+			wantErr:   false,
+		},
+		{
+			name:      "body matches",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.name", Operator: "matches", Value: "^r.q$"},
+			wantErr:   false,
+		},
+		{
+			name:      "body exists",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.name", Operator: "exists"},
+			wantErr:   false,
+		},
+		{
+			name:      "body missing",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.missing", Operator: "exists"},
+			wantErr:   true,
+			errMsg:    "expected body $.missing to exist",
+		},
+		{
+			name:      "not numbers",
+			assertion: spec.Assertion{Subject: "body", Selector: "$.name", Operator: ">=", Value: "1"},
+			wantErr:   true,
+			errMsg:    `cannot use >= on "req" and 1, both must be numbers`,
+		},
+		{
+			name:      "bad regex",
+			assertion: spec.Assertion{Subject: "body", Operator: "matches", Value: "("},
+			wantErr:   true,
+			errMsg:    "bad regular expression \"(\": error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.assertion.Check(response)
+			test.WantErr(t, err, tt.wantErr)
+
+			if err != nil {
+				test.Equal(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package spec
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+// MethodWebSocket is the method of a WebSocket request, which isn't sent over HTTP
+// but opens a WebSocket connection and sends it's [Message] script.
+const MethodWebSocket = "WEBSOCKET"
+
+// Message separators in the body of a WebSocket request, as in JetBrains IDEs.
+const (
+	messageSeparator = "==="             // Starts the next message
+	waitForServer    = "wait-for-server" // After a separator, waits for a message from the server first
+)
+
+// Message is a single message in the script of a WebSocket request.
+type Message struct {
+	// The text message to send, "" if this only waits for the server
+	Body string `json:"body,omitempty"`
+
+	// How many messages to wait for from the server before sending Body, one for
+	// each '=== wait-for-server' separator before it
+	Wait int `json:"wait,omitempty"`
+}
+
+// String implements [fmt.Stringer] for a [Message].
+func (m Message) String() string {
+	builder := &strings.Builder{}
+
+	for range m.Wait {
+		fmt.Fprintf(builder, "%s %s\n", messageSeparator, waitForServer)
+	}
+
+	if m.Body != "" {
+		if m.Wait == 0 {
+			builder.WriteString(messageSeparator + "\n")
+		}
+
+		builder.WriteString(m.Body + "\n")
+	}
+
+	return builder.String()
+}
+
+// resolveWebSocket splits the body of a resolved WebSocket request into it's messages.
+func resolveWebSocket(in syntax.Request, resolved Request) (Request, error) {
+	if resolved.BodyFile != "" {
+		return Request{}, fmt.Errorf("WebSocket request %s must have an inline body or a '<@' body file", in.Name)
+	}
+
+	u, err := url.Parse(resolved.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") {
+		return Request{}, fmt.Errorf("WebSocket request %s must have a ws:// or wss:// URL, got %q", in.Name, resolved.URL)
+	}
+
+	messages, err := splitMessages(string(resolved.Body))
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid WebSocket request %s: %w", in.Name, err)
+	}
+
+	resolved.Messages = messages
+	resolved.Body = nil
+
+	return resolved, nil
+}
+
+// splitMessages splits the body of a WebSocket request into it's messages, each one
+// starts after a line of '===', or '=== wait-for-server' to wait for a message from
+// the server before sending it. Any text before the first separator is the first message.
+//
+// Trailing '=== wait-for-server' lines become a final message with no body, so the
+// responses to the last message are waited for.
+func splitMessages(body string) ([]Message, error) {
+	var (
+		messages []Message
+		current  strings.Builder
+		wait     int
+	)
+
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			messages = append(messages, Message{Body: text, Wait: wait})
+			wait = 0
+		}
+
+		current.Reset()
+	}
+
+	for line := range strings.Lines(body) {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), messageSeparator)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			current.WriteString(line)
+			continue
+		}
+
+		flush()
+
+		switch rest = strings.TrimSpace(rest); rest {
+		case "":
+		case waitForServer:
+			wait++
+		default:
+			return nil, fmt.Errorf("unknown message separator %q, expected %q or %q",
+				strings.TrimSpace(line), messageSeparator, messageSeparator+" "+waitForServer)
+		}
+	}
+
+	flush()
+
+	if wait > 0 {
+		messages = append(messages, Message{Wait: wait})
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,174 @@
+// Package sse implements a reader for server-sent events, the text/event-stream format
+// used to stream a response as a series of events e.g. by LLM APIs.
+//
+// A stream is a series of events separated by blank lines, each made of 'field: value'
+// lines, see https://html.spec.whatwg.org/multipage/server-sent-events.html:
+//
+//	event: message
+//	id: 1
+//	data: {"text": "Hello"}
+//
+//	: a comment, ignored
+//	data: {"text": " world"}
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of a stream of server-sent events.
+const ContentType = "text/event-stream"
+
+const (
+	defaultType = "message" // The type of an event that doesn't declare one
+	maxLineSize = 1 << 20   // The longest line allowed in a stream, 1MB
+)
+
+// Event is a single server-sent event.
+type Event struct {
+	// The event type, from 'event', "message" if it had none
+	Type string
+
+	// The event data, from every 'data' line joined with newlines
+	Data string
+
+	// The last event ID, from 'id', this carries over to later events that don't set one
+	ID string
+
+	// How long the client should wait before reconnecting, from 'retry', 0 if not set
+	Retry time.Duration
+}
+
+// Reader reads server-sent events from a stream.
+type Reader struct {
+	scanner *bufio.Scanner
+	lastID  string // The last event ID, which carries over between events
+	started bool   // Whether the first line has been read, which may start with a byte order mark
+}
+
+// NewReader returns a [Reader] reading events from r.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	scanner.Split(scanLines)
+
+	return &Reader{scanner: scanner}
+}
+
+// Next reads the next event from the stream, blocking until it's complete.
+//
+// At the end of the stream it returns [io.EOF], any incomplete event at the end is
+// discarded as it would be by a browser.
+func (r *Reader) Next() (Event, error) {
+	var (
+		event   Event
+		data    strings.Builder
+		hasData bool
+	)
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		if !r.started {
+			r.started = true
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		// A blank line dispatches the event, unless there was no data in which case
+		// there is nothing to dispatch and it starts again
+		if line == "" {
+			if !hasData {
+				event = Event{}
+				continue
+			}
+
+			event.Data = data.String()
+			event.ID = r.lastID
+
+			if event.Type == "" {
+				event.Type = defaultType
+			}
+
+			return event, nil
+		}
+
+		// A line starting with ':' is a comment
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+
+			data.WriteString(value)
+
+			hasData = true
+		case "id":
+			// An ID containing NULL is ignored
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		case "retry":
+			// Only digits are allowed, anything else is ignored
+			if millis, err := strconv.ParseUint(value, 10, 64); err == nil {
+				event.Retry = time.Duration(millis) * time.Millisecond //nolint:gosec // A retry that overflows is absurd anyway
+			}
+		default:
+			// Unknown fields are ignored
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+
+	return Event{}, io.EOF
+}
+
+// scanLines is a [bufio.SplitFunc] splitting on any of the line endings allowed in an
+// event stream: "\r\n", "\n" or a lone "\r".
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// A '\r' may be followed by a '\n', which we can't know until there's more data
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+
+			return i + 1, data[:i], nil
+		}
+
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+
+		return 0, nil, nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
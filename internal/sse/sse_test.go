@@ -0,0 +1,128 @@
+package sse_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/sse"
+	"go.followtheprocess.codes/test"
+)
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string      // Name of the test case
+		stream string      // The raw event stream
+		want   []sse.Event // The events we expect to read
+	}{
+		{
+			name:   "empty",
+			stream: "",
+			want:   nil,
+		},
+		{
+			name:   "data only",
+			stream: "data: hello\n\n",
+			want:   []sse.Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "all fields",
+			stream: "event: delta\nid: 1\nretry: 500\ndata: {\"text\": \"hi\"}\n\n",
+			want:   []sse.Event{{Type: "delta", ID: "1", Retry: 500 * time.Millisecond, Data: `{"text": "hi"}`}},
+		},
+		{
+			name:   "multi line data",
+			stream: "data: one\ndata: two\ndata:three\n\n",
+			want:   []sse.Event{{Type: "message", Data: "one\ntwo\nthree"}},
+		},
+		{
+			name:   "id carries over",
+			stream: "id: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\n",
+			want: []sse.Event{
+				{Type: "message", ID: "1", Data: "a"},
+				{Type: "message", ID: "1", Data: "b"},
+				{Type: "message", Data: "c"},
+			},
+		},
+		{
+			name:   "comments and unknown fields",
+			stream: ": keep alive\nfoo: bar\ndata: hello\n\n",
+			want:   []sse.Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "no data",
+			stream: "event: ping\n\ndata: hello\n\n",
+			want:   []sse.Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "empty data",
+			stream: "data\n\n",
+			want:   []sse.Event{{Type: "message", Data: ""}},
+		},
+		{
+			name:   "bad retry",
+			stream: "retry: soon\ndata: hello\n\n",
+			want:   []sse.Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "crlf",
+			stream: "event: a\r\ndata: one\r\n\r\ndata: two\r\n\r\n",
+			want:   []sse.Event{{Type: "a", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "cr",
+			stream: "data: one\r\rdata: two\r\r",
+			want:   []sse.Event{{Type: "message", Data: "one"}, {Type: "message", Data: "two"}},
+		},
+		{
+			name:   "byte order mark",
+			stream: "\ufeffdata: hello\n\n",
+			want:   []sse.Event{{Type: "message", Data: "hello"}},
+		},
+		{
+			name:   "incomplete last event",
+			stream: "data: one\n\ndata: two\n",
+			want:   []sse.Event{{Type: "message", Data: "one"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A byte at a time, so events are split across reads as they would be over the network
+			reader := sse.NewReader(iotest.OneByteReader(strings.NewReader(tt.stream)))
+
+			var got []sse.Event
+
+			for {
+				event, err := reader.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				test.Ok(t, err)
+
+				got = append(got, event)
+			}
+
+			test.Equal(t, len(got), len(tt.want))
+
+			for i := range got {
+				test.Equal(t, got[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	reader := sse.NewReader(io.MultiReader(strings.NewReader("data: one\n\ndata: t"), iotest.ErrReader(io.ErrUnexpectedEOF)))
+
+	event, err := reader.Next()
+	test.Ok(t, err)
+	test.Equal(t, event.Data, "one")
+
+	_, err = reader.Next()
+	test.True(t, errors.Is(err, io.ErrUnexpectedEOF), test.Context("wrong error: %v", err))
+}
@@ -0,0 +1,401 @@
+// Package ast declares the types used to represent the syntax tree of a .http file.
+//
+// Unlike [syntax.File], which holds what a file means, the tree is lossless: every node
+// carries the [Span] of source text it was parsed from, comments are kept along with the
+// '#' or '//' marker they were written with, and variables and headers are kept in the
+// order they were declared. It's intended for tooling that needs to point at or rewrite
+// specific parts of a file, like the formatter.
+//
+// [File.Lower] converts the tree to a [syntax.File], ready to be resolved and sent.
+package ast
+
+import (
+	"fmt"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/token"
+)
+
+// Node is any node in the syntax tree.
+type Node interface {
+	// Range returns the span of source text the node was parsed from.
+	Range() Span
+}
+
+// Statement is a [Comment], [Var] or [Prompt], each of which takes up a single line
+// at the top of a file or before a request line.
+type Statement interface {
+	Node
+	statement()
+}
+
+// Span is the range of source text a node was parsed from.
+//
+// Start and End are both single points (StartCol == EndCol), Start is the first character of
+// the node and End is just past it's last character.
+type Span struct {
+	Start syntax.Position // The first character
+	End   syntax.Position // Just past the last character
+}
+
+// Range implements [Node] for any node embedding a [Span].
+func (s Span) Range() Span {
+	return s
+}
+
+// Position returns the span as a single [syntax.Position], as used for error reporting.
+//
+// A span across multiple lines can't be represented this way, so it points to the
+// first character.
+func (s Span) Position() syntax.Position {
+	pos := s.Start
+	if s.End.Line == s.Start.Line {
+		pos.EndCol = s.End.StartCol
+	}
+
+	return pos
+}
+
+// File is the syntax tree of an entire .http file.
+type File struct {
+	// Name of the file
+	Name string
+
+	// Global variables, prompts and comments before the first request, in order
+	Statements []Statement
+
+	// The requests in the file
+	Requests []*Request
+
+	// Comments after the last request
+	Comments []*Comment
+
+	Span
+}
+
+// Request is a single request.
+type Request struct {
+	// The optional request comment after the '###', either on the same line or the next one
+	Comment *Comment
+
+	// The optional version of the HTTP protocol e.g. 'HTTP/2'
+	Version *Text
+
+	// The optional inline body, trimmed of leading and trailing whitespace
+	Body *Text
+
+	// The optional file the body is read from e.g. '< ./body.json' or '<@ ./body.json'
+	BodyFile *Redirect
+
+	// The optional response redirect e.g. '> ./response.json', '>> ...' or '>>! ...'
+	Response *Redirect
+
+	// The optional response reference e.g. '<> ./previous.json'
+	Reference *Redirect
+
+	// Comments between the previous request (or the globals) and the '###'
+	Comments []*Comment
+
+	// Request variables, prompts and comments between the '###' and the request line, in order
+	Statements []Statement
+
+	// The headers, in the order they were declared
+	Headers []*Header
+
+	// The fields of a form urlencoded body e.g. 'name=foo', in the order they were declared
+	Form []*Field
+
+	// Assertions on the response e.g. '?? status == 200', in the order they were declared
+	Assertions []*Assertion
+
+	// The HTTP method
+	Method Text
+
+	// The URL, possibly with variable interpolation
+	URL Text
+
+	// The '###'
+	Separator Span
+
+	// From the '###' to the end of the request's last token, not including Comments
+	Span
+}
+
+// Comment is a line comment.
+type Comment struct {
+	// The comment marker, '#' or '//'. Empty for a request comment on the same line as
+	// the '###'
+	Marker string
+
+	// The comment text with surrounding whitespace removed
+	Text string
+
+	Span
+}
+
+// Var is a variable declaration e.g. '@base = https://api.com', or in a request
+// '# @name = Login'.
+type Var struct {
+	// The value, nil for '@no-redirect'
+	Value *Text
+
+	// The comment marker, '#' or '//'. Empty for global variables or a request variable
+	// declared without one
+	Marker string
+
+	// The name of the variable, after the '@'
+	Name Text
+
+	// [token.Ident] for a generic variable, otherwise the keyword e.g. [token.Name] or [token.Timeout]
+	Keyword token.Kind
+
+	// Whether the variable was declared with an '='
+	Eq bool
+
+	Span
+}
+
+// Prompt is a prompt declaration e.g. '@prompt token Your API token'.
+type Prompt struct {
+	// The optional description
+	Description *Text
+
+	// The comment marker, as for [Var]
+	Marker string
+
+	// The name of the variable being prompted for
+	Name Text
+
+	Span
+}
+
+// Header is a single request header e.g. 'Content-Type: application/json'.
+type Header struct {
+	Name  Text // The header name
+	Value Text // The header value, possibly with variable interpolation
+	Span
+}
+
+// Field is a single field of a form urlencoded body e.g. 'name=foo' or '&name=foo'.
+type Field struct {
+	Name      Text // The field name
+	Value     Text // The field value, possibly with variable interpolation
+	Ampersand bool // Whether the field was declared with a leading '&'
+	Span
+}
+
+// Redirect is a body file, response redirect or response reference and the file path
+// that follows it.
+type Redirect struct {
+	// The file path
+	Path Text
+
+	// One of [token.LeftAngle], [token.LeftAngleAt], [token.RightAngle],
+	// [token.DoubleRightAngle], [token.DoubleRightAngleBang] or [token.LeftRightAngle]
+	Op token.Kind
+
+	Span
+}
+
+// Assertion is a response assertion e.g. '?? header Content-Type contains json'.
+type Assertion struct {
+	Selector *Text // The optional selector, a header name or body JSONPath/XPath
+	Value    *Text // The optional expected value
+	Subject  Text  // What's being checked e.g. 'status'
+	Operator Text  // The comparison e.g. '=='
+	Span
+}
+
+// Text is a run of source text e.g. a URL or header value, with surrounding
+// whitespace removed.
+type Text struct {
+	Value string
+	Span
+}
+
+func (*Comment) statement() {}
+func (*Var) statement()     {}
+func (*Prompt) statement()  {}
+
+// Lower converts the syntax tree to a [syntax.File].
+//
+// It's only meaningful for a tree that parsed without errors.
+func (f *File) Lower() syntax.File {
+	file := syntax.File{Name: f.Name}
+
+	for _, statement := range f.Statements {
+		switch statement := statement.(type) {
+		case *Prompt:
+			file.Prompts = append(file.Prompts, statement.lower())
+		case *Var:
+			switch statement.Keyword {
+			case token.Name:
+				file.Name = statement.value()
+			case token.Timeout:
+				file.Timeout = statement.duration()
+			case token.ConnectionTimeout:
+				file.ConnectionTimeout = statement.duration()
+			case token.NoRedirect:
+				file.NoRedirect = true
+			default:
+				if file.Vars == nil {
+					file.Vars = make(map[string]string)
+				}
+
+				file.Vars[statement.Name.Value] = statement.value()
+			}
+		}
+	}
+
+	for index, request := range f.Requests {
+		file.Requests = append(file.Requests, request.lower(index))
+	}
+
+	return file
+}
+
+// lower converts the request to a [syntax.Request], index is it's position in the file.
+func (r *Request) lower(index int) syntax.Request {
+	request := syntax.Request{
+		Method: r.Method.Value,
+		URL:    r.URL.Value,
+	}
+
+	if r.Comment != nil {
+		request.Comment = r.Comment.Text
+	}
+
+	for _, statement := range r.Statements {
+		switch statement := statement.(type) {
+		case *Prompt:
+			request.Prompts = append(request.Prompts, statement.lower())
+		case *Var:
+			switch statement.Keyword {
+			case token.Name:
+				request.Name = statement.value()
+			case token.Timeout:
+				request.Timeout = statement.duration()
+			case token.ConnectionTimeout:
+				request.ConnectionTimeout = statement.duration()
+			case token.NoRedirect:
+				request.NoRedirect = true
+			case token.Proto:
+				request.Proto = statement.value()
+			default:
+				if request.Vars == nil {
+					request.Vars = make(map[string]string)
+					request.Positions.Vars = make(map[string]syntax.Position)
+				}
+
+				request.Vars[statement.Name.Value] = statement.value()
+
+				if statement.Value != nil {
+					request.Positions.Vars[statement.Name.Value] = statement.Value.Start
+				}
+			}
+		}
+	}
+
+	// If it's name is missing, name it after it's position in the file (1 indexed)
+	if request.Name == "" {
+		request.Name = fmt.Sprintf("#%d", 1+index)
+	}
+
+	request.Positions.URL = r.URL.Start
+
+	if r.Version != nil {
+		request.HTTPVersion = r.Version.Value
+	}
+
+	for _, header := range r.Headers {
+		request.Headers = append(request.Headers, syntax.Header{
+			Name:  header.Name.Value,
+			Value: header.Value.Value,
+			Pos:   header.Value.Start,
+		})
+	}
+
+	for _, field := range r.Form {
+		request.Form = append(request.Form, syntax.Field{
+			Name:  field.Name.Value,
+			Value: field.Value.Value,
+			Pos:   field.Value.Start,
+		})
+	}
+
+	if r.Body != nil {
+		if r.Body.Value != "" {
+			request.Body = []byte(r.Body.Value)
+		}
+
+		request.Positions.Body = r.Body.Start
+	}
+
+	if r.BodyFile != nil {
+		request.BodyFile = r.BodyFile.Path.Value
+		request.InterpolateBodyFile = r.BodyFile.Op == token.LeftAngleAt
+	}
+
+	if r.Response != nil {
+		request.ResponseFile = r.Response.Path.Value
+		request.UniqueResponseFile = r.Response.Op == token.DoubleRightAngle
+	}
+
+	if r.Reference != nil {
+		request.ResponseReference = r.Reference.Path.Value
+	}
+
+	for _, assertion := range r.Assertions {
+		request.Assertions = append(request.Assertions, assertion.lower())
+	}
+
+	return request
+}
+
+// lower converts the prompt to a [syntax.Prompt].
+func (p *Prompt) lower() syntax.Prompt {
+	prompt := syntax.Prompt{Name: p.Name.Value}
+	if p.Description != nil {
+		prompt.Description = p.Description.Value
+	}
+
+	return prompt
+}
+
+// lower converts the assertion to a [syntax.Assertion].
+func (a *Assertion) lower() syntax.Assertion {
+	assertion := syntax.Assertion{
+		Subject:  a.Subject.Value,
+		Operator: a.Operator.Value,
+		Pos:      a.Position(),
+	}
+
+	if a.Selector != nil {
+		assertion.Selector = a.Selector.Value
+	}
+
+	if a.Value != nil {
+		assertion.Value = a.Value.Value
+		assertion.ValuePos = a.Value.Start
+	}
+
+	return assertion
+}
+
+// value returns the variable's value, or "" if it has none.
+func (v *Var) value() string {
+	if v.Value == nil {
+		return ""
+	}
+
+	return v.Value.Value
+}
+
+// duration returns the variable's value as a duration, the parser has already
+// reported any that are invalid.
+func (v *Var) duration() time.Duration {
+	duration, _ := time.ParseDuration(v.value())
+
+	return duration
+}
@@ -0,0 +1,305 @@
+// Package format implements canonical formatting of .http files, as used by `req fmt`.
+//
+// Unlike [syntax.File.String], which prints the parsed meaning of a file, the formatter
+// prints the lossless [ast.File] produced by the parser so everything the author wrote
+// is kept: comments, the order of variables and headers, '#' vs '//' comment markers
+// and whether variables were declared with an '='. Only the layout changes:
+//
+//   - Whitespace within a line is normalised e.g. '@base=https://x' -> '@base = https://x'
+//   - Requests are separated by a single blank line
+//   - The request line, variables and headers are kept together with no blank lines
+//   - The body, body file, response redirect and assertions are each preceded by a single blank line
+//   - Comments after a request with no body follow straight after it, a blank line would start a body
+//   - Blank lines between top level comments and global variables are kept, but runs of them are collapsed
+//   - Inline bodies are kept exactly as written other than leading and trailing whitespace
+//
+// Formatting is idempotent, formatting already formatted source returns it unchanged.
+package format
+
+import (
+	"bytes"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/ast"
+	"go.followtheprocess.codes/req/internal/syntax/parser"
+	"go.followtheprocess.codes/req/internal/syntax/token"
+)
+
+// Source formats the .http file src, returning the canonically formatted source.
+//
+// The source must be valid, syntax errors are reported to handler and an error returned.
+func Source(name string, src []byte, handler syntax.ErrorHandler) ([]byte, error) {
+	p, err := parser.New(name, bytes.NewReader(src), handler)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := p.ParseFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return File(file), nil
+}
+
+// File prints the syntax tree of a valid .http file in canonical form.
+func File(file *ast.File) []byte {
+	buf := &bytes.Buffer{}
+
+	statements := file.Statements
+
+	for i, request := range file.Requests {
+		// Comments directly above the '###' belong to the request, for the first
+		// request they were parsed along with the globals
+		var leading []*ast.Comment
+
+		if i == 0 {
+			statements, leading = attach(statements, request.Separator.Start.Line)
+			writeStatements(buf, statements)
+
+			if len(statements) > 0 {
+				buf.WriteByte('\n')
+			}
+		} else {
+			var detached []*ast.Comment
+
+			detached, leading = attach(request.Comments, request.Separator.Start.Line)
+
+			// A blank line after the head of a request starts it's body, so any comments
+			// must follow straight after it
+			if len(request.Comments) == 0 || !headOnly(file.Requests[i-1]) {
+				buf.WriteByte('\n')
+			}
+
+			if len(detached) > 0 {
+				writeStatements(buf, detached)
+				buf.WriteByte('\n')
+			}
+		}
+
+		for _, comment := range leading {
+			writeStatement(buf, comment)
+		}
+
+		writeRequest(buf, request)
+	}
+
+	if len(file.Requests) == 0 {
+		writeStatements(buf, statements)
+	}
+
+	if len(file.Comments) > 0 {
+		last := len(file.Requests) - 1
+		if buf.Len() > 0 && (last < 0 || !headOnly(file.Requests[last])) {
+			buf.WriteByte('\n')
+		}
+
+		writeStatements(buf, file.Comments)
+	}
+
+	return buf.Bytes()
+}
+
+// attach splits the run of comments at the end of statements that sit directly above
+// the '###' on line, with no blank lines between them, from the rest.
+func attach[S ast.Statement](statements []S, line int) (rest []S, attached []*ast.Comment) {
+	index := len(statements)
+
+	for index > 0 {
+		comment, ok := ast.Statement(statements[index-1]).(*ast.Comment)
+		if !ok || comment.End.Line != line-1 {
+			break
+		}
+
+		attached = append([]*ast.Comment{comment}, attached...)
+		line = comment.Start.Line
+		index--
+	}
+
+	return statements[:index], attached
+}
+
+// headOnly reports whether the request ends with it's head, the request line and
+// headers, with nothing after it.
+func headOnly(request *ast.Request) bool {
+	return len(request.Form) == 0 &&
+		(request.Body == nil || request.Body.Value == "") &&
+		request.BodyFile == nil &&
+		request.Response == nil &&
+		request.Reference == nil &&
+		len(request.Assertions) == 0
+}
+
+// writeRequest prints a single request, from the '###' onwards.
+func writeRequest(buf *bytes.Buffer, request *ast.Request) {
+	buf.WriteString("###")
+
+	// The request comment is usually on the same line as the separator, but may be
+	// on the line after with it's own marker
+	comment := request.Comment
+	if comment != nil && comment.Marker == "" {
+		if comment.Text != "" {
+			buf.WriteString(" " + comment.Text)
+		}
+
+		comment = nil
+	}
+
+	buf.WriteByte('\n')
+
+	if comment != nil {
+		writeStatement(buf, comment)
+	}
+
+	for _, statement := range request.Statements {
+		writeStatement(buf, statement)
+	}
+
+	buf.WriteString(request.Method.Value + " " + request.URL.Value)
+
+	if request.Version != nil {
+		buf.WriteString(" " + request.Version.Value)
+	}
+
+	buf.WriteByte('\n')
+
+	for _, header := range request.Headers {
+		buf.WriteString(header.Name.Value + ":")
+
+		if header.Value.Value != "" {
+			buf.WriteString(" " + header.Value.Value)
+		}
+
+		buf.WriteByte('\n')
+	}
+
+	if len(request.Form) > 0 {
+		buf.WriteByte('\n')
+	}
+
+	for _, field := range request.Form {
+		if field.Ampersand {
+			buf.WriteByte('&')
+		}
+
+		buf.WriteString(field.Name.Value + "=" + field.Value.Value + "\n")
+	}
+
+	if request.Body != nil && request.Body.Value != "" {
+		buf.WriteString("\n" + request.Body.Value + "\n")
+	}
+
+	if request.BodyFile != nil {
+		buf.WriteString("\n" + redirect(request.BodyFile) + "\n")
+	}
+
+	if request.Response != nil {
+		buf.WriteString("\n" + redirect(request.Response) + "\n")
+	}
+
+	if request.Reference != nil {
+		buf.WriteString("\n" + redirect(request.Reference) + "\n")
+	}
+
+	if len(request.Assertions) > 0 {
+		buf.WriteByte('\n')
+	}
+
+	for _, assertion := range request.Assertions {
+		parts := []string{"??", assertion.Subject.Value}
+
+		if assertion.Selector != nil {
+			parts = append(parts, assertion.Selector.Value)
+		}
+
+		parts = append(parts, assertion.Operator.Value)
+
+		if assertion.Value != nil {
+			parts = append(parts, assertion.Value.Value)
+		}
+
+		buf.WriteString(strings.Join(parts, " ") + "\n")
+	}
+}
+
+// redirect formats a body file or response redirect e.g. '< ./body.json'.
+func redirect(r *ast.Redirect) string {
+	var op string
+
+	switch r.Op {
+	case token.LeftAngle:
+		op = "<"
+	case token.LeftAngleAt:
+		op = "<@"
+	case token.RightAngle:
+		op = ">"
+	case token.DoubleRightAngle:
+		op = ">>"
+	case token.DoubleRightAngleBang:
+		op = ">>!"
+	case token.LeftRightAngle:
+		op = "<>"
+	}
+
+	if r.Path.Value == "" {
+		return op
+	}
+
+	return op + " " + r.Path.Value
+}
+
+// writeStatements prints a run of statements one per line, keeping a single blank line
+// wherever there was at least one in the source.
+func writeStatements[S ast.Statement](buf *bytes.Buffer, statements []S) {
+	for i, statement := range statements {
+		if i > 0 && statement.Range().Start.Line-statements[i-1].Range().End.Line > 1 {
+			buf.WriteByte('\n')
+		}
+
+		writeStatement(buf, statement)
+	}
+}
+
+// writeStatement prints a single comment, variable or prompt on it's own line.
+func writeStatement(buf *bytes.Buffer, statement ast.Statement) {
+	switch statement := statement.(type) {
+	case *ast.Comment:
+		buf.WriteString(statement.Marker)
+
+		if statement.Text != "" {
+			buf.WriteString(" " + statement.Text)
+		}
+	case *ast.Var:
+		writeMarker(buf, statement.Marker)
+		buf.WriteString("@" + statement.Name.Value)
+
+		var value string
+		if statement.Value != nil {
+			value = statement.Value.Value
+		}
+
+		switch {
+		case statement.Eq:
+			buf.WriteString(" = " + value)
+		case value != "":
+			buf.WriteString(" " + value)
+		}
+	case *ast.Prompt:
+		writeMarker(buf, statement.Marker)
+		buf.WriteString("@prompt " + statement.Name.Value)
+
+		if statement.Description != nil {
+			buf.WriteString(" " + statement.Description.Value)
+		}
+	}
+
+	buf.WriteByte('\n')
+}
+
+// writeMarker prints the comment marker before a request variable, if it has one.
+func writeMarker(buf *bytes.Buffer, marker string) {
+	if marker != "" {
+		buf.WriteString(marker + " ")
+	}
+}
@@ -0,0 +1,139 @@
+package format_test
+
+import (
+	"encoding/json"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/format"
+	"go.followtheprocess.codes/req/internal/syntax/parser"
+	"go.followtheprocess.codes/test"
+	"go.followtheprocess.codes/txtar"
+)
+
+var update = flag.Bool("update", false, "Update testdata")
+
+// TestSource formats the src.http from each txtar archive in testdata and compares it against
+// want.http.
+func TestSource(t *testing.T) {
+	test.ColorEnabled(true) // Force colour in the diffs
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.txtar"))
+	test.Ok(t, err)
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			archive, err := txtar.ParseFile(file)
+			test.Ok(t, err)
+
+			src, ok := archive.Read("src.http")
+			test.True(t, ok, test.Context("archive %s missing src.http", name))
+
+			want, ok := archive.Read("want.http")
+			test.True(t, ok, test.Context("archive %s missing want.http", name))
+
+			got, err := format.Source(name, []byte(src), testFailHandler(t))
+			test.Ok(t, err)
+
+			if *update {
+				err := archive.Write("want.http", string(got))
+				test.Ok(t, err)
+
+				err = txtar.DumpFile(file, archive)
+				test.Ok(t, err)
+
+				return
+			}
+
+			test.Diff(t, string(got), want)
+		})
+	}
+}
+
+// TestIdempotent checks that formatting every valid file in the parser testdata (and our
+// own) doesn't change it's meaning, and that formatting it again changes nothing.
+func TestIdempotent(t *testing.T) {
+	var files []string
+
+	for _, pattern := range []string{
+		filepath.Join("testdata", "*.txtar"),
+		filepath.Join("..", "parser", "testdata", "valid", "*.txtar"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		test.Ok(t, err)
+
+		files = append(files, matches...)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			archive, err := txtar.ParseFile(file)
+			test.Ok(t, err)
+
+			src, ok := archive.Read("src.http")
+			test.True(t, ok, test.Context("archive %s missing src.http", name))
+
+			once, err := format.Source(name, []byte(src), testFailHandler(t))
+			test.Ok(t, err)
+
+			twice, err := format.Source(name, once, testFailHandler(t))
+			test.Ok(t, err)
+
+			test.Diff(t, string(twice), string(once))
+
+			// Formatting must not change what the file means
+			test.Diff(t, parse(t, name, once), parse(t, name, []byte(src)))
+		})
+	}
+}
+
+// TestInvalid checks that invalid files are never formatted.
+func TestInvalid(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "parser", "testdata", "invalid", "*.txtar"))
+	test.Ok(t, err)
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			archive, err := txtar.ParseFile(file)
+			test.Ok(t, err)
+
+			src, ok := archive.Read("src.http")
+			test.True(t, ok, test.Context("archive %s missing src.http", name))
+
+			got, err := format.Source(name, []byte(src), nil)
+			test.Err(t, err)
+			test.Equal(t, len(got), 0)
+		})
+	}
+}
+
+// parse parses src and returns the result as JSON.
+func parse(t *testing.T, name string, src []byte) string {
+	t.Helper()
+
+	p, err := parser.New(name, strings.NewReader(string(src)), testFailHandler(t))
+	test.Ok(t, err)
+
+	file, err := p.Parse()
+	test.Ok(t, err)
+
+	got, err := json.MarshalIndent(file, "", "  ")
+	test.Ok(t, err)
+
+	return string(got)
+}
+
+// testFailHandler returns a [syntax.ErrorHandler] that fails the test if called.
+func testFailHandler(tb testing.TB) syntax.ErrorHandler {
+	tb.Helper()
+
+	return func(pos syntax.Position, msg string) {
+		tb.Fatalf("%s: %s", pos, msg)
+	}
+}
@@ -0,0 +1,333 @@
+// Package interp implements the '{{...}}' variable interpolation used in .http files.
+//
+// The syntax is compatible with JetBrains IDEs and the VSCode REST Client, a variable
+// is referenced by name e.g. '{{base}}' and is looked up first in the request's local
+// variables, then in the global variables.
+//
+// Dynamic variables are prefixed with a '$' and may take space separated arguments
+// e.g. '{{$randomInt 1 10}}'. An argument of the form '%name' is replaced with the
+// value of the variable name before being passed on.
+//
+// The response to another request in the same file may be referenced by that request's
+// name e.g. '{{login.response.body.$.token}}' or '{{login.response.headers.Location}}',
+// see [Reference].
+//
+// For compatibility with earlier versions of req, a variable may also be referenced
+// explicitly by scope with '{{.Global.base}}' or '{{.Local.id}}'.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+const (
+	openTag  = "{{" // Opening delimiter of an interpolation tag
+	closeTag = "}}" // Closing delimiter of an interpolation tag
+)
+
+// ErrInterp is a generic interpolation error, details on the error are passed
+// to the installed [syntax.ErrorHandler] at the moment it occurs.
+var ErrInterp = errors.New("interpolation error")
+
+// A Builtin computes the value of a dynamic variable e.g. '{{$uuid}}', it is called
+// each time the variable is referenced with the arguments that followed it's name.
+type Builtin func(args []string) (string, error)
+
+// A ResponseFunc returns the value selected by ref from the response to another request.
+type ResponseFunc func(ref Reference) (string, error)
+
+// Scope is the set of variables available to interpolation.
+type Scope struct {
+	// Global variables available to the entire file.
+	Global map[string]string
+
+	// Local variables, available only to a single request.
+	Local map[string]string
+
+	// Dynamic variables, keyed by name without the leading '$'.
+	Builtins map[string]Builtin
+
+	// Provides values from the responses to other requests, if nil any
+	// reference to a response is an error.
+	Responses ResponseFunc
+}
+
+// Interpolator performs variable interpolation against a [Scope].
+type Interpolator struct {
+	handler syntax.ErrorHandler // The installed error handler, called in response to interpolation errors
+	scope   Scope               // The variables available to interpolation
+}
+
+// New returns a new [Interpolator] that looks up variables in scope.
+//
+// If handler is non-nil, it is called with the position and detail of
+// any interpolation errors.
+func New(scope Scope, handler syntax.ErrorHandler) *Interpolator {
+	return &Interpolator{
+		handler: handler,
+		scope:   scope,
+	}
+}
+
+// Interpolate replaces every '{{...}}' tag in src with the value of the variable
+// it references.
+//
+// The pos is the source position of the first character of src, and is used
+// to report the precise position of any errors. All errors in src are reported
+// to the handler before returning, the returned error will simply signify whether or
+// not there were any.
+func (i *Interpolator) Interpolate(src string, pos syntax.Position) (string, error) {
+	return i.interpolate(src, pos, nil)
+}
+
+// InterpolateEscaped is like [Interpolator.Interpolate] but passes the value of every
+// tag through escape, the text around the tags is kept exactly as written.
+//
+// This is for text that must already be encoded e.g. a form field, where only the
+// values of variables need percent-encoding.
+func (i *Interpolator) InterpolateEscaped(src string, pos syntax.Position, escape func(string) string) (string, error) {
+	return i.interpolate(src, pos, escape)
+}
+
+// interpolate implements [Interpolator.Interpolate] and [Interpolator.InterpolateEscaped],
+// escape may be nil in which case values are used as they are.
+func (i *Interpolator) interpolate(src string, pos syntax.Position, escape func(string) string) (string, error) {
+	builder := &strings.Builder{}
+	builder.Grow(len(src))
+
+	hadErrors := false
+	offset := 0 // Byte offset into src of the text not yet consumed
+
+	for {
+		start, end := findTag(src, offset)
+		if start == -1 {
+			builder.WriteString(src[offset:])
+			break
+		}
+
+		builder.WriteString(src[offset:start])
+
+		if end == -1 {
+			i.error(src, pos, start, start+len(openTag), "unterminated '{{', expected a closing '}}'")
+			hadErrors = true
+
+			break
+		}
+
+		value, err := i.eval(src[start+len(openTag) : end-len(closeTag)])
+		if err != nil {
+			i.error(src, pos, start, end, err.Error())
+			hadErrors = true
+		}
+
+		if escape != nil {
+			value = escape(value)
+		}
+
+		builder.WriteString(value)
+
+		offset = end
+	}
+
+	if hadErrors {
+		return "", ErrInterp
+	}
+
+	return builder.String(), nil
+}
+
+// eval evaluates the expression inside a single '{{...}}' tag, returning it's value.
+func (i *Interpolator) eval(expr string) (string, error) {
+	expr = strings.TrimSpace(expr)
+
+	if expr == "" {
+		return "", errors.New("empty variable reference '{{}}'")
+	}
+
+	// The scoped form e.g. '{{.Global.base}}'
+	if rest, ok := strings.CutPrefix(expr, "."); ok {
+		scope, name, _ := strings.Cut(rest, ".")
+
+		var vars map[string]string
+
+		switch scope {
+		case "Global":
+			vars = i.scope.Global
+		case "Local":
+			vars = i.scope.Local
+		default:
+			return "", fmt.Errorf("unknown scope %q, expected Global or Local", scope)
+		}
+
+		if !isIdent(name) {
+			return "", fmt.Errorf("invalid variable name %q", name)
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("undefined %s variable %q", strings.ToLower(scope), name)
+		}
+
+		return value, nil
+	}
+
+	// A dynamic variable e.g. '{{$randomInt 1 10}}'
+	if strings.HasPrefix(expr, "$") {
+		return i.builtin(expr)
+	}
+
+	// A reference to another request's response e.g. '{{login.response.body.$.token}}'
+	if ref, ok, err := ParseReference(expr); ok {
+		if err != nil {
+			return "", err
+		}
+
+		if i.scope.Responses == nil {
+			return "", fmt.Errorf("cannot reference the response to %s here", ref.Request)
+		}
+
+		return i.scope.Responses(ref)
+	}
+
+	if !isIdent(expr) {
+		return "", fmt.Errorf("invalid variable reference %q", expr)
+	}
+
+	value, ok := i.lookup(expr)
+	if !ok {
+		return "", fmt.Errorf("undefined variable %q", expr)
+	}
+
+	return value, nil
+}
+
+// builtin evaluates a dynamic variable expression, including it's leading '$'.
+func (i *Interpolator) builtin(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	name := strings.TrimPrefix(fields[0], "$")
+
+	builtin, ok := i.scope.Builtins[name]
+	if !ok {
+		return "", fmt.Errorf("unknown dynamic variable %q", "$"+name)
+	}
+
+	args := fields[1:]
+	for index, arg := range args {
+		ref, ok := strings.CutPrefix(arg, "%")
+		if !ok {
+			continue
+		}
+
+		value, ok := i.lookup(ref)
+		if !ok {
+			return "", fmt.Errorf("undefined variable %q in argument to $%s", ref, name)
+		}
+
+		args[index] = value
+	}
+
+	value, err := builtin(args)
+	if err != nil {
+		return "", fmt.Errorf("$%s: %w", name, err)
+	}
+
+	return value, nil
+}
+
+// lookup returns the value of the named variable, local variables take
+// precedence over global ones.
+func (i *Interpolator) lookup(name string) (string, bool) {
+	if value, ok := i.scope.Local[name]; ok {
+		return value, true
+	}
+
+	value, ok := i.scope.Global[name]
+
+	return value, ok
+}
+
+// findTag returns the byte offsets of the start of the next '{{' in src at or after offset,
+// and of the end of it's matching '}}'.
+//
+// If there are no more tags, start is -1. If the tag is unterminated, end is -1.
+func findTag(src string, offset int) (start, end int) {
+	start = strings.Index(src[offset:], openTag)
+	if start == -1 {
+		return -1, -1
+	}
+
+	start += offset
+
+	end = strings.Index(src[start+len(openTag):], closeTag)
+	if end == -1 {
+		return start, -1
+	}
+
+	return start, end + start + len(openTag) + len(closeTag)
+}
+
+// error calculates the source position of src[start:end] and calls the installed
+// error handler with it and msg.
+func (i *Interpolator) error(src string, pos syntax.Position, start, end int, msg string) {
+	if i.handler == nil {
+		return
+	}
+
+	i.handler(position(src, pos, start, end), msg)
+}
+
+// position returns the source position of src[start:end], given that src starts
+// at pos.
+//
+// If the text spans multiple lines, the position is clamped to the end of the
+// first line.
+func position(src string, pos syntax.Position, start, end int) syntax.Position {
+	line := pos.Line
+	col := pos.StartCol
+
+	for _, char := range []byte(src[:start]) {
+		if char == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+
+	if newline := strings.IndexByte(src[start:end], '\n'); newline != -1 {
+		end = start + newline
+	}
+
+	return syntax.Position{
+		Name:     pos.Name,
+		Offset:   pos.Offset + start,
+		Line:     line,
+		StartCol: col,
+		EndCol:   col + end - start,
+	}
+}
+
+// isIdent reports whether s is a valid variable name, the same rules as
+// idents in the .http syntax.
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, char := range s {
+		if !isIdentChar(char) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isIdentChar reports whether r is a valid identifier character.
+func isIdentChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-'
+}
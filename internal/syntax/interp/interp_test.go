@@ -0,0 +1,224 @@
+package interp_test
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+	"go.followtheprocess.codes/test"
+)
+
+func TestInterpolate(t *testing.T) {
+	scope := interp.Scope{
+		Global: map[string]string{"base": "https://api.com", "id": "global", "user-id": "42"},
+		Local:  map[string]string{"id": "local", "token": "secret"},
+	}
+
+	tests := []struct {
+		name    string // Name of the test case
+		src     string // Source text to interpolate
+		want    string // Expected interpolated text
+		errs    string // Expected errors passed to the handler, one per line
+		wantErr bool   // Whether we want an error
+	}{
+		{
+			name: "empty",
+			src:  "",
+			want: "",
+		},
+		{
+			name: "no tags",
+			src:  "https://api.com/users",
+			want: "https://api.com/users",
+		},
+		{
+			name: "global",
+			src:  "{{base}}/users",
+			want: "https://api.com/users",
+		},
+		{
+			name: "local shadows global",
+			src:  "{{base}}/users/{{id}}",
+			want: "https://api.com/users/local",
+		},
+		{
+			name: "whitespace",
+			src:  "{{ base }}/users/{{  id\t}}",
+			want: "https://api.com/users/local",
+		},
+		{
+			name: "hyphenated",
+			src:  "/users/{{user-id}}",
+			want: "/users/42",
+		},
+		{
+			name: "scoped",
+			src:  "{{.Global.base}}/users/{{ .Global.id }}/{{.Local.id}}",
+			want: "https://api.com/users/global/local",
+		},
+		{
+			name: "multiline",
+			src:  "{\n  \"token\": \"{{token}}\"\n}",
+			want: "{\n  \"token\": \"secret\"\n}",
+		},
+		{
+			name: "single braces",
+			src:  `{"id": {"nested": true}}`,
+			want: `{"id": {"nested": true}}`,
+		},
+		{
+			name:    "undefined",
+			src:     "{{base}}/{{missing}}",
+			errs:    `test.http:3:19-30: undefined variable "missing"`,
+			wantErr: true,
+		},
+		{
+			name:    "undefined local",
+			src:     "{{.Local.base}}",
+			errs:    `test.http:3:10-25: undefined local variable "base"`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown scope",
+			src:     "{{.Env.base}}",
+			errs:    `test.http:3:10-23: unknown scope "Env", expected Global or Local`,
+			wantErr: true,
+		},
+		{
+			name:    "empty tag",
+			src:     "{{ }}",
+			errs:    "test.http:3:10-15: empty variable reference '{{}}'",
+			wantErr: true,
+		},
+		{
+			name:    "invalid reference",
+			src:     "{{ base + id }}",
+			errs:    `test.http:3:10-25: invalid variable reference "base + id"`,
+			wantErr: true,
+		},
+		{
+			name:    "unterminated",
+			src:     "{{base}}/{{id",
+			errs:    "test.http:3:19-21: unterminated '{{', expected a closing '}}'",
+			wantErr: true,
+		},
+		{
+			name:    "multiple errors",
+			src:     "{{one}}\n  {{two}}",
+			errs:    "test.http:3:10-17: undefined variable \"one\"\ntest.http:4:3-10: undefined variable \"two\"",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []string
+
+			handler := func(pos syntax.Position, msg string) {
+				errs = append(errs, fmt.Sprintf("%s: %s", pos, msg))
+			}
+
+			// Pretend the src starts part way along line 3
+			pos := syntax.Position{Name: "test.http", Offset: 40, Line: 3, StartCol: 10, EndCol: 10}
+
+			got, err := interp.New(scope, handler).Interpolate(tt.src, pos)
+			test.WantErr(t, err, tt.wantErr)
+
+			test.Equal(t, got, tt.want)
+			test.Diff(t, strings.Join(errs, "\n"), tt.errs)
+		})
+	}
+}
+
+func TestInterpolateNilHandler(t *testing.T) {
+	_, err := interp.New(interp.Scope{}, nil).Interpolate("{{missing}}", syntax.Position{})
+	test.Err(t, err)
+	test.True(t, errors.Is(err, interp.ErrInterp))
+}
+
+func TestInterpolateEscaped(t *testing.T) {
+	scope := interp.Scope{Global: map[string]string{"password": "s&cret=100%"}}
+
+	got, err := interp.New(scope, nil).InterpolateEscaped("100%25&{{password}}", syntax.Position{}, url.QueryEscape)
+	test.Ok(t, err)
+	test.Equal(t, got, "100%25&s%26cret%3D100%25")
+}
+
+func TestInterpolateBuiltins(t *testing.T) {
+	scope := interp.Scope{
+		Global: map[string]string{"name": "global"},
+		Local:  map[string]string{"name": "local"},
+		Builtins: map[string]interp.Builtin{
+			"echo": func(args []string) (string, error) {
+				return strings.Join(args, ","), nil
+			},
+			"fail": func(args []string) (string, error) {
+				return "", errors.New("boom")
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string // Name of the test case
+		src     string // Source text to interpolate
+		want    string // Expected interpolated text
+		errs    string // Expected errors passed to the handler, one per line
+		wantErr bool   // Whether we want an error
+	}{
+		{
+			name: "no args",
+			src:  "id: {{$echo}}",
+			want: "id: ",
+		},
+		{
+			name: "args",
+			src:  "{{ $echo 1  2 }}",
+			want: "1,2",
+		},
+		{
+			name: "variable args",
+			src:  "{{$echo %name plain}}",
+			want: "local,plain",
+		},
+		{
+			name:    "undefined variable arg",
+			src:     "{{$echo %missing}}",
+			errs:    `test.http:1:1-19: undefined variable "missing" in argument to $echo`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			src:     "{{$nope}}",
+			errs:    `test.http:1:1-10: unknown dynamic variable "$nope"`,
+			wantErr: true,
+		},
+		{
+			name:    "error",
+			src:     "{{$fail}}",
+			errs:    "test.http:1:1-10: $fail: boom",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []string
+
+			handler := func(pos syntax.Position, msg string) {
+				errs = append(errs, fmt.Sprintf("%s: %s", pos, msg))
+			}
+
+			pos := syntax.Position{Name: "test.http", Line: 1, StartCol: 1, EndCol: 1}
+
+			got, err := interp.New(scope, handler).Interpolate(tt.src, pos)
+			test.WantErr(t, err, tt.wantErr)
+
+			test.Equal(t, got, tt.want)
+			test.Diff(t, strings.Join(errs, "\n"), tt.errs)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+)
+
+// Part is the part of a response selected by a [Reference].
+type Part int
+
+const (
+	Body    Part = iota // The response body
+	Headers             // The response headers
+)
+
+// String implements [fmt.Stringer] for a [Part].
+func (p Part) String() string {
+	switch p {
+	case Body:
+		return "body"
+	case Headers:
+		return "headers"
+	default:
+		return fmt.Sprintf("Part(%d)", int(p))
+	}
+}
+
+// A Reference is a reference to part of the response to another request, in the same form
+// as JetBrains and the VSCode REST Client:
+//
+//	{{login.response.body.$.token}}         // JSONPath into a JSON body
+//	{{login.response.body.//token}}         // XPath into an XML body
+//	{{login.response.body.*}}               // The entire body, as is '{{login.response.body}}'
+//	{{login.response.headers.Location}}     // A response header
+type Reference struct {
+	Request string // Name of the referenced request
+	Path    string // The selector for the body, or the header name. Empty for the entire body
+	Part    Part   // The part of the response being referenced
+}
+
+// String implements [fmt.Stringer] for a [Reference], returning it in the form it
+// would be written inside a '{{...}}' tag.
+func (r Reference) String() string {
+	if r.Path == "" {
+		return fmt.Sprintf("%s.response.%s", r.Request, r.Part)
+	}
+
+	return fmt.Sprintf("%s.response.%s.%s", r.Request, r.Part, r.Path)
+}
+
+// ParseReference parses expr, the contents of a '{{...}}' tag, as a [Reference].
+//
+// If expr does not look like a reference, ok is false. If it does but is malformed,
+// ok is true and err describes what's wrong.
+func ParseReference(expr string) (ref Reference, ok bool, err error) {
+	expr = strings.TrimSpace(expr)
+
+	name, rest, found := strings.Cut(expr, ".response")
+	if !found || !isIdent(name) {
+		return Reference{}, false, nil
+	}
+
+	// Guard against e.g. 'thing.responses'
+	if rest != "" && rest[0] != '.' {
+		return Reference{}, false, nil
+	}
+
+	ref.Request = name
+	rest = strings.TrimPrefix(rest, ".")
+
+	part, path, _ := strings.Cut(rest, ".")
+
+	switch part {
+	case "body":
+		ref.Part = Body
+		if path != "*" {
+			ref.Path = path
+		}
+	case "headers":
+		if path == "" {
+			return ref, true, fmt.Errorf("missing header name in reference to %s.response.headers", name)
+		}
+
+		ref.Part = Headers
+		ref.Path = path
+	default:
+		return ref, true, fmt.Errorf("bad reference to the response of %s, expected 'body' or 'headers', got %q", name, part)
+	}
+
+	return ref, true, nil
+}
+
+// A Tag is a complete '{{...}}' tag in some source text.
+type Tag struct {
+	Expr string          // The expression inside the tag, with surrounding whitespace trimmed
+	Pos  syntax.Position // The source position of the entire tag
+}
+
+// Tags returns every complete '{{...}}' tag in src, in order. The pos is the
+// source position of the first character of src.
+//
+// Unterminated tags are ignored, they're reported when src is interpolated.
+func Tags(src string, pos syntax.Position) []Tag {
+	var tags []Tag
+
+	offset := 0
+
+	for {
+		start, end := findTag(src, offset)
+		if start == -1 || end == -1 {
+			return tags
+		}
+
+		tags = append(tags, Tag{
+			Expr: strings.TrimSpace(src[start+len(openTag) : end-len(closeTag)]),
+			Pos:  position(src, pos, start, end),
+		})
+
+		offset = end
+	}
+}
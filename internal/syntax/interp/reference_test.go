@@ -0,0 +1,108 @@
+package interp_test
+
+import (
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/interp"
+	"go.followtheprocess.codes/test"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string           // Name of the test case
+		expr    string           // The expression to parse
+		want    interp.Reference // Expected reference
+		ok      bool             // Whether expr should look like a reference
+		wantErr bool             // Whether we want an error
+	}{
+		{
+			name: "variable",
+			expr: "token",
+			ok:   false,
+		},
+		{
+			name: "builtin",
+			expr: "$uuid",
+			ok:   false,
+		},
+		{
+			name: "not response",
+			expr: "login.responses.body",
+			ok:   false,
+		},
+		{
+			name: "jsonpath",
+			expr: "login.response.body.$.token",
+			want: interp.Reference{Request: "login", Part: interp.Body, Path: "$.token"},
+			ok:   true,
+		},
+		{
+			name: "jsonpath with dots",
+			expr: " login.response.body.$.user.items[0].id ",
+			want: interp.Reference{Request: "login", Part: interp.Body, Path: "$.user.items[0].id"},
+			ok:   true,
+		},
+		{
+			name: "xpath",
+			expr: "login.response.body.//token",
+			want: interp.Reference{Request: "login", Part: interp.Body, Path: "//token"},
+			ok:   true,
+		},
+		{
+			name: "whole body",
+			expr: "login.response.body",
+			want: interp.Reference{Request: "login", Part: interp.Body},
+			ok:   true,
+		},
+		{
+			name: "whole body star",
+			expr: "login.response.body.*",
+			want: interp.Reference{Request: "login", Part: interp.Body},
+			ok:   true,
+		},
+		{
+			name: "header",
+			expr: "login.response.headers.X-Session",
+			want: interp.Reference{Request: "login", Part: interp.Headers, Path: "X-Session"},
+			ok:   true,
+		},
+		{
+			name:    "header missing name",
+			expr:    "login.response.headers",
+			ok:      true,
+			wantErr: true,
+		},
+		{
+			name:    "bad part",
+			expr:    "login.response.status",
+			ok:      true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := interp.ParseReference(tt.expr)
+			test.Equal(t, ok, tt.ok)
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.Equal(t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	pos := syntax.Position{Name: "tags.http", Line: 3, StartCol: 5}
+
+	tags := interp.Tags("GET {{ base }}/users/{{login.response.body.$.id}} {{unterminated", pos)
+	test.Equal(t, len(tags), 2)
+
+	test.Equal(t, tags[0].Expr, "base")
+	test.Equal(t, tags[0].Pos.String(), "tags.http:3:9-19")
+
+	test.Equal(t, tags[1].Expr, "login.response.body.$.id")
+	test.Equal(t, tags[1].Pos.String(), "tags.http:3:26-54")
+}
@@ -2,15 +2,18 @@
 package parser
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/ast"
 	"go.followtheprocess.codes/req/internal/syntax/scanner"
 	"go.followtheprocess.codes/req/internal/syntax/token"
 )
@@ -19,15 +22,36 @@ import (
 // to the parser's [syntax.ErrorHandler] at the moment it occurs.
 var ErrParse = errors.New("parse error")
 
+// parseError is the error returned when there are syntax errors. It matches [ErrParse]
+// with [errors.Is] and the full [syntax.ErrorList] can be retrieved with [errors.As].
+type parseError struct {
+	errors syntax.ErrorList
+}
+
+// Error implements the error interface for a [parseError].
+func (e parseError) Error() string {
+	return ErrParse.Error()
+}
+
+// Unwrap returns both [ErrParse] and the list of errors.
+func (e parseError) Unwrap() []error {
+	return []error{ErrParse, e.errors}
+}
+
 // Parser is the http file parser.
 type Parser struct {
-	handler   syntax.ErrorHandler // The installed error handler, to be called in response to parse errors
-	scanner   *scanner.Scanner    // Scanner to produce tokens
-	name      string              // Name of the file being parsed
-	src       []byte              // Raw source text
-	current   token.Token         // Current token under inspection
-	next      token.Token         // Next token in the stream
-	hadErrors bool                // Whether we encountered parse errors
+	handler    syntax.ErrorHandler // The installed error handler, to be called in response to parse errors
+	scanner    *scanner.Scanner    // Scanner to produce tokens
+	name       string              // Name of the file being parsed
+	src        []byte              // Raw source text
+	lines      []int               // Byte offset of the start of each line in src
+	errors     syntax.ErrorList    // Every error from the scanner and parser, guarded by mu
+	scanned    syntax.ErrorList    // Errors from the scanner not yet reached by the parser, guarded by mu
+	pending    *syntax.Error       // The scanner error for p.next, until it's reported or skipped
+	current    token.Token         // Current token under inspection
+	next       token.Token         // Next token in the stream
+	mu         sync.Mutex          // Protects errors, scanned and the handler, the scanner reports from it's own goroutine
+	recovering bool                // Whether the current request had an error and needs skipping
 }
 
 // New initialises and returns a new [Parser] that reads from r.
@@ -38,13 +62,23 @@ func New(name string, r io.Reader, handler syntax.ErrorHandler) (*Parser, error)
 		return nil, fmt.Errorf("failed to read from input: %w", err)
 	}
 
+	lines := []int{0}
+
+	for index, byt := range src {
+		if byt == '\n' {
+			lines = append(lines, index+1)
+		}
+	}
+
 	p := &Parser{
 		handler: handler,
-		scanner: scanner.New(name, src, handler),
 		name:    name,
 		src:     src,
+		lines:   lines,
 	}
 
+	p.scanner = scanner.New(name, src, p.scanError)
+
 	// Read 2 tokens so current and next are set
 	p.advance()
 	p.advance()
@@ -54,46 +88,143 @@ func New(name string, r io.Reader, handler syntax.ErrorHandler) (*Parser, error)
 
 // Parse parses the file to completion returning a [syntax.File] and any parsing errors.
 //
-// The returned error will simply signify whether or not there were parse errors,
-// the installed error handler passed to [New] will have the full detail and should
-// be preferred.
+// After a syntax error, the rest of the offending request is skipped and parsing carries
+// on from the next '###' so that every error in the file is reported, not just the first.
+// Each one is passed to the installed error handler as it's found and if there were any,
+// the returned error matches [ErrParse] and contains a [syntax.ErrorList] of them all,
+// sorted by position.
 func (p *Parser) Parse() (syntax.File, error) {
-	file := syntax.File{
+	file, err := p.ParseFile()
+	if err != nil {
+		return syntax.File{}, err
+	}
+
+	return file.Lower(), nil
+}
+
+// ParseFile parses the file to completion returning it's syntax tree and any parsing errors.
+//
+// Errors are reported as for [Parser.Parse]. The tree is returned regardless, missing
+// any requests with errors that couldn't be parsed.
+func (p *Parser) ParseFile() (*ast.File, error) {
+	file := &ast.File{
 		Name: p.name,
+		Span: ast.Span{Start: p.point(0), End: p.point(len(p.src))},
+	}
+
+	// Parse any globals (and comments) at the top of the file
+	file.Statements = p.parseStatements()
+
+	// A .proto file only makes sense for a single gRPC request
+	for _, statement := range file.Statements {
+		if v, ok := statement.(*ast.Var); ok && v.Keyword == token.Proto {
+			p.report(v.Position(), "@proto is only allowed in a request")
+		}
 	}
 
-	// Parse any global at the top of the file
-	file = p.parseGlobals(file)
+	var comments []*ast.Comment
 
 	for !p.current.Is(token.EOF) {
 		if p.current.Is(token.Error) {
-			// An error from the scanner
-			return syntax.File{}, ErrParse
+			// The scanner has skipped to the next '###', and the error was reported
+			// as we reached it
+			p.synchronise()
+			continue
 		}
 
-		request := p.parseRequest()
+		if !p.current.Is(token.Separator) {
+			p.errorf("expected %s, got %s", token.Separator, p.current.Kind)
+			p.synchronise()
 
-		// If it's name is missing, name it after it's position in the file (1 indexed)
-		if request.Name == "" {
-			request.Name = fmt.Sprintf("#%d", 1+len(file.Requests))
+			continue
 		}
 
+		request := p.parseRequest()
+		request.Comments = comments
+		comments = nil
+
 		file.Requests = append(file.Requests, request)
 
 		p.advance()
+
+		if p.recovering {
+			p.synchronise()
+		}
+
+		// Comments between requests belong to the next one
+		for p.current.Is(token.Comment) {
+			comments = append(comments, p.parseComment())
+			p.advance()
+		}
 	}
 
-	if p.hadErrors {
-		return syntax.File{}, ErrParse
+	file.Comments = comments
+
+	p.checkNames(file)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.errors) > 0 {
+		p.errors.Sort()
+		return file, parseError{errors: p.errors}
 	}
 
 	return file, nil
 }
 
+// checkNames reports any request whose '@name' is already used by an earlier request,
+// requests are looked up by name so each must be unique.
+func (p *Parser) checkNames(file *ast.File) {
+	lines := make(map[string]int, len(file.Requests))
+
+	for _, request := range file.Requests {
+		for _, statement := range request.Statements {
+			v, ok := statement.(*ast.Var)
+			if !ok || v.Keyword != token.Name || v.Value == nil {
+				continue
+			}
+
+			name := v.Value.Value
+
+			if line, exists := lines[name]; exists {
+				p.report(v.Value.Position(), fmt.Sprintf("duplicate request name %q, already used on line %d", name, line))
+				continue
+			}
+
+			lines[name] = v.Value.Start.Line
+		}
+	}
+}
+
+// synchronise skips tokens until the next request separator or eof so that parsing
+// can carry on after a syntax error.
+func (p *Parser) synchronise() {
+	for !p.current.Is(token.Separator, token.EOF) {
+		p.advance()
+	}
+
+	p.recovering = false
+}
+
 // advance advances the parser by a single token.
+//
+// An error from the scanner is reported once the parser reaches it's [token.Error],
+// unless the parser is skipping the rest of a request after an earlier error in which
+// case it's only a knock-on effect of that one and is dropped.
 func (p *Parser) advance() {
+	if p.pending != nil && !p.recovering {
+		p.report(p.pending.Pos, p.pending.Msg)
+	}
+
+	p.pending = nil
+
 	p.current = p.next
 	p.next = p.scanner.Scan()
+
+	if p.next.Is(token.Error) {
+		p.pending = p.popScanned()
+	}
 }
 
 // expect asserts that the next token is one of the given kinds, emitting a syntax error if not.
@@ -103,8 +234,13 @@ func (p *Parser) advance() {
 func (p *Parser) expect(kinds ...token.Kind) {
 	if p.next.Is(token.Error) {
 		// Nobody expects an error!
-		// But seriously, this means the scanner has emitted an error and has already
-		// passed it to the error handler
+		// But seriously, this means the scanner has found an error, it's the reason
+		// for whatever we were expecting not being there
+		if p.pending != nil {
+			p.report(p.pending.Pos, p.pending.Msg)
+			p.pending = nil
+		}
+
 		return
 	}
 
@@ -170,17 +306,93 @@ func (p *Parser) position() syntax.Position {
 	}
 }
 
-// error calculates the current position and calls the installed error handler
-// with the correct information.
+// point returns the position of the single character at offset.
+func (p *Parser) point(offset int) syntax.Position {
+	// The index of the last line starting at or before offset
+	index, found := slices.BinarySearch(p.lines, offset)
+	if !found {
+		index--
+	}
+
+	col := 1 + offset - p.lines[index]
+
+	return syntax.Position{
+		Name:     p.name,
+		Offset:   offset,
+		Line:     1 + index,
+		StartCol: col,
+		EndCol:   col,
+	}
+}
+
+// span returns the [ast.Span] between two offsets.
+func (p *Parser) span(start, end int) ast.Span {
+	return ast.Span{Start: p.point(start), End: p.point(end)}
+}
+
+// textNode returns the text described by p.current as an [ast.Text], with the same
+// value as [Parser.text] and a span covering just that text.
+func (p *Parser) textNode() ast.Text {
+	raw := string(p.src[p.current.Start:p.current.End])
+	start := p.current.Start + len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+	value := strings.TrimSpace(raw)
+
+	return ast.Text{Value: value, Span: p.span(start, start+len(value))}
+}
+
+// end returns the offset just past the last non whitespace character of p.current.
+func (p *Parser) end() int {
+	return p.current.Start + len(strings.TrimRightFunc(string(p.src[p.current.Start:p.current.End]), unicode.IsSpace))
+}
+
+// error calculates the current position and reports the error.
 func (p *Parser) error(msg string) {
-	p.hadErrors = true
+	p.recovering = true
+	p.report(p.position(), msg)
+}
+
+// report records an error from either the scanner or the parser and calls the
+// installed error handler with it.
+//
+// The handler is called with the lock held, so errors from the scanner and the
+// parser are never written out at the same time.
+func (p *Parser) report(pos syntax.Position, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.errors.Add(pos, msg)
 
 	if p.handler == nil {
 		// I guess ignore?
 		return
 	}
 
-	p.handler(p.position(), msg)
+	p.handler(pos, msg)
+}
+
+// scanError is the scanner's error handler, it holds on to the error until the parser
+// reaches it's [token.Error].
+func (p *Parser) scanError(pos syntax.Position, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.scanned.Add(pos, msg)
+}
+
+// popScanned removes and returns the oldest error from the scanner, the one for the
+// [token.Error] just received as the scanner reports each before emitting the token.
+func (p *Parser) popScanned() *syntax.Error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.scanned) == 0 {
+		return nil
+	}
+
+	err := p.scanned[0]
+	p.scanned = p.scanned[1:]
+
+	return &err
 }
 
 // errorf calls error with a formatted message.
@@ -193,45 +405,48 @@ func (p *Parser) text() string {
 	return strings.TrimSpace(string(p.src[p.current.Start:p.current.End]))
 }
 
-// parseGlobals parses a run of variable declarations at the top of the file, returning
-// the modified [syntax.File].
+// parseStatements parses a run of variable declarations, prompts and comments, either
+// at the top of the file or at the start of a request.
 //
-// If p.current is anything other than '@', the input file is returned as is.
-func (p *Parser) parseGlobals(file syntax.File) syntax.File {
-	if !p.current.Is(token.At) {
-		return file
-	}
+// If p.current is anything other than '@' or a comment, nil is returned.
+func (p *Parser) parseStatements() []ast.Statement {
+	var statements []ast.Statement
+
+	for p.current.Is(token.At, token.Comment) {
+		if p.current.Is(token.Comment) {
+			statements = append(statements, p.parseComment())
+			p.advance()
+
+			continue
+		}
+
+		marker, start := p.marker(p.current.Start)
 
-	for p.current.Is(token.At) {
 		switch p.next.Kind {
-		case token.Timeout:
-			file.Timeout = p.parseDuration()
-		case token.ConnectionTimeout:
-			file.ConnectionTimeout = p.parseDuration()
+		case token.Timeout, token.ConnectionTimeout:
+			statements = append(statements, p.parseDuration(marker, start))
 		case token.NoRedirect:
 			p.advance()
 
-			file.NoRedirect = true
-		case token.Name:
-			file.Name = p.parseName()
+			statements = append(statements, &ast.Var{
+				Marker:  marker,
+				Name:    p.textNode(),
+				Keyword: token.NoRedirect,
+				Span:    p.span(start, p.end()),
+			})
+		case token.Name, token.Proto:
+			statements = append(statements, p.parseName(marker, start))
 		case token.Prompt:
-			file.Prompts = append(file.Prompts, p.parsePrompt())
+			statements = append(statements, p.parsePrompt(marker, start))
 		case token.Ident:
-			// Generic variable, shove it in the map which we now lazily initialise
-			// because not every request will have vars
-			key, value := p.parseVar()
-
-			if file.Vars == nil {
-				file.Vars = make(map[string]string)
-			}
-
-			file.Vars[key] = value
+			statements = append(statements, p.parseVar(marker, start))
 		default:
 			p.expect(
 				token.Timeout,
 				token.ConnectionTimeout,
 				token.NoRedirect,
 				token.Name,
+				token.Proto,
 				token.Prompt,
 				token.Ident,
 			)
@@ -240,191 +455,317 @@ func (p *Parser) parseGlobals(file syntax.File) syntax.File {
 		p.advance()
 	}
 
-	return file
+	return statements
+}
+
+// parseComment parses a comment, p.current is the comment.
+func (p *Parser) parseComment() *ast.Comment {
+	marker, start := p.marker(p.current.Start)
+
+	return &ast.Comment{
+		Marker: marker,
+		Text:   p.text(),
+		Span:   p.span(start, p.end()),
+	}
 }
 
-// parseRequest parses a single request in a http file.
-func (p *Parser) parseRequest() syntax.Request {
-	if !p.current.Is(token.Separator) {
-		p.errorf("expected %s, got %s", token.Separator, p.current.Kind)
-		return syntax.Request{}
+// marker returns the comment marker ('#' or '//') before offset on the same line and
+// the offset at which it starts. If there isn't one, it returns "" and offset.
+func (p *Parser) marker(offset int) (marker string, start int) {
+	index := offset
+	for index > 0 && (p.src[index-1] == ' ' || p.src[index-1] == '\t') {
+		index--
 	}
 
-	request := syntax.Request{}
+	switch {
+	case index >= 2 && p.src[index-1] == '/' && p.src[index-2] == '/':
+		return "//", index - 2
+	case index >= 1 && p.src[index-1] == '#':
+		return "#", index - 1
+	default:
+		return "", offset
+	}
+}
+
+// parseRequest parses a single request in a http file, p.current is the separator.
+func (p *Parser) parseRequest() *ast.Request {
+	request := &ast.Request{}
+
+	start := p.current.Start
+	request.Separator = p.span(p.current.Start, p.current.End)
 
 	// Does it have a comment as in "### [comment]"
 	if p.next.Is(token.Comment) {
+		separatorLine := request.Separator.Start.Line
+
 		p.advance()
-		request.Comment = p.text()
+		request.Comment = p.parseComment()
+
+		// The marker found is the last '#' of the separator itself
+		if request.Comment.Start.Line == separatorLine {
+			request.Comment.Marker = ""
+			request.Comment.Span = p.span(p.current.Start, p.end())
+		}
 	}
 
 	p.advance()
-	request = p.parseRequestVars(request)
+	request.Statements = p.parseStatements()
+
+	// Whatever happens, the request spans up to the last token parsed
+	defer func() { request.Span = p.span(start, p.end()) }()
 
 	if !token.IsMethod(p.current.Kind) {
 		p.errorf("request separators must be followed by either a comment or a HTTP method, got %s: %q", p.current.Kind, p.text())
-		return syntax.Request{}
+		return request
 	}
 
-	request.Method = p.text()
+	request.Method = p.textNode()
+	grpc := p.current.Is(token.MethodGRPC)
 
 	p.expect(token.URL)
-	p.validateURL(p.text())
 
-	request.URL = p.text()
+	// A gRPC target isn't a URL, it's checked when the request is resolved
+	if !grpc {
+		p.validateURL(p.text())
+	}
+
+	request.URL = p.textNode()
 
 	if p.next.Is(token.HTTPVersion) {
 		p.advance()
-		request.HTTPVersion = p.text()
-	}
 
-	// Now any headers, initialising the map lazily although in fairness
-	// its likely that most requests will have headers
-	if p.next.Is(token.Header) {
-		if request.Headers == nil {
-			request.Headers = make(map[string]string)
-		}
+		version := p.textNode()
+		request.Version = &version
 	}
 
 	for p.next.Is(token.Header) {
 		p.advance()
-		key := p.text()
+		name := p.textNode()
 		p.expect(token.Colon)
 		p.expect(token.Text)
-		value := p.text()
-		request.Headers[key] = value
+		value := p.textNode()
+
+		request.Headers = append(request.Headers, &ast.Header{
+			Name:  name,
+			Value: value,
+			Span:  ast.Span{Start: name.Start, End: value.End},
+		})
+	}
+
+	// Or a form, with each field on it's own line e.g. 'name=foo'
+	for p.next.Is(token.Ampersand, token.Field) {
+		p.advance()
+		request.Form = append(request.Form, p.parseField())
 	}
 
 	// Do we have a request body inline?
 	if p.next.Is(token.Body) {
 		p.advance()
-		request.Body = bytes.TrimSpace(p.src[p.current.Start:p.current.End])
+
+		body := p.textNode()
+		request.Body = &body
 	}
 
-	// Might be a '< ./body.json'
-	if p.next.Is(token.LeftAngle) {
+	// Might be a '< ./body.json' or a '<@ ./body.json'
+	if p.next.Is(token.LeftAngle, token.LeftAngleAt) {
 		p.advance()
-		p.expect(token.Text)
-		request.BodyFile = p.text()
+		request.BodyFile = p.parseRedirect()
 	}
 
 	// We could now also have a response redirect
-	// e.g '> ./response.json'
-	if p.next.Is(token.RightAngle) {
+	// e.g '> ./response.json', '>> ./response.json' or '>>! ./response.json'
+	if p.next.Is(token.RightAngle, token.DoubleRightAngle, token.DoubleRightAngleBang) {
 		p.advance()
-		p.expect(token.Text)
-		request.ResponseFile = p.text()
+		request.Response = p.parseRedirect()
+	}
+
+	// And a response reference e.g. '<> ./previous.json'
+	if p.next.Is(token.LeftRightAngle) {
+		p.advance()
+		request.Reference = p.parseRedirect()
+	}
+
+	// Finally any assertions on the response e.g. '?? status == 200'
+	for p.next.Is(token.DoubleQuestion) {
+		p.advance()
+		request.Assertions = append(request.Assertions, p.parseAssertion())
 	}
 
 	return request
 }
 
-// parseRequestVars parses a run of variable declarations in a request. Returning
-// the modified [syntax.Request].
-//
-// If p.current is anything other than '@', the request is returned as is.
-func (p *Parser) parseRequestVars(request syntax.Request) syntax.Request {
-	if !p.current.Is(token.At) {
-		return request
+// parseField parses a single field of a form urlencoded body e.g. '&name=foo', p.current
+// is either the '&' or the field name.
+func (p *Parser) parseField() *ast.Field {
+	start := p.current.Start
+	field := &ast.Field{}
+
+	if p.current.Is(token.Ampersand) {
+		field.Ampersand = true
+
+		p.expect(token.Field)
 	}
 
-	for p.current.Is(token.At) {
-		switch p.next.Kind {
-		case token.Timeout:
-			request.Timeout = p.parseDuration()
-		case token.ConnectionTimeout:
-			request.ConnectionTimeout = p.parseDuration()
-		case token.NoRedirect:
-			p.advance()
+	field.Name = p.textNode()
 
-			request.NoRedirect = true
-		case token.Name:
-			request.Name = p.parseName()
-		case token.Prompt:
-			request.Prompts = append(request.Prompts, p.parsePrompt())
-		case token.Ident:
-			// Generic variable, shove it in the map which we now lazily initialise
-			// because not every request will have vars
-			key, value := p.parseVar()
+	p.expect(token.Eq)
+	p.expect(token.Text)
 
-			if request.Vars == nil {
-				request.Vars = make(map[string]string)
-			}
+	field.Value = p.textNode()
+	field.Span = p.span(start, p.end())
 
-			request.Vars[key] = value
-		default:
-			p.expect(
-				token.Timeout,
-				token.ConnectionTimeout,
-				token.NoRedirect,
-				token.Name,
-				token.Prompt,
-				token.Ident,
-			)
-		}
+	return field
+}
+
+// parseRedirect parses a body file or response redirect, p.current is the operator.
+func (p *Parser) parseRedirect() *ast.Redirect {
+	start := p.current.Start
+	redirect := &ast.Redirect{Op: p.current.Kind}
+
+	p.expect(token.Text)
+
+	redirect.Path = p.textNode()
+	redirect.Span = p.span(start, p.end())
+
+	return redirect
+}
+
+// parseAssertion parses a single response assertion e.g. '?? status == 200', p.current
+// is the '??'.
+func (p *Parser) parseAssertion() *ast.Assertion {
+	start := p.current.Start
+
+	assertion := &ast.Assertion{}
+
+	p.expect(token.Ident)
+	assertion.Subject = p.textNode()
+
+	switch assertion.Subject.Value {
+	case "status", "header", "body":
+	default:
+		p.errorf("unknown assertion subject %q, expected status, header or body", assertion.Subject.Value)
+	}
 
+	if p.next.Is(token.Text) {
 		p.advance()
+
+		selector := p.textNode()
+		assertion.Selector = &selector
 	}
 
-	return request
+	p.expect(token.Operator)
+	assertion.Operator = p.textNode()
+
+	needsValue, ok := syntax.LookupOperator(assertion.Operator.Value)
+	if !ok {
+		p.errorf("unknown assertion operator %q, expected one of %s", assertion.Operator.Value, strings.Join(syntax.Operators(), ", "))
+	}
+
+	if p.next.Is(token.Text) {
+		p.advance()
+
+		value := p.textNode()
+		assertion.Value = &value
+	}
+
+	if ok && needsValue != (assertion.Value != nil && assertion.Value.Value != "") {
+		if needsValue {
+			p.errorf("assertion operator %q needs a value", assertion.Operator.Value)
+		} else {
+			p.errorf("assertion operator %q does not take a value", assertion.Operator.Value)
+		}
+	}
+
+	// The assertion spans from the '??' to the end of it's last token
+	assertion.Span = p.span(start, p.end())
+
+	return assertion
 }
 
-// parseDuration parses a duration declaration e.g. in a global or request variable.
-func (p *Parser) parseDuration() time.Duration {
+// parseDuration parses a duration declaration e.g. in a global or request variable,
+// p.current is the '@'.
+func (p *Parser) parseDuration(marker string, start int) *ast.Var {
 	p.advance()
+
+	v := &ast.Var{Marker: marker, Name: p.textNode(), Keyword: p.current.Kind}
+
 	// Can either be @timeout = 20s or @timeout 20s
 	if p.next.Is(token.Eq) {
 		p.advance()
+
+		v.Eq = true
 	}
 
 	p.expect(token.Text)
 
-	duration, err := time.ParseDuration(p.text())
-	if err != nil {
+	if _, err := time.ParseDuration(p.text()); err != nil {
 		p.errorf("bad timeout value: %v", err)
 	}
 
-	return duration
+	value := p.textNode()
+	v.Value = &value
+	v.Span = p.span(start, p.end())
+
+	return v
 }
 
-// parseName parses a name declaration e.g. in a global or request variable.
-func (p *Parser) parseName() string {
+// parseName parses a name declaration e.g. in a global or request variable, or a
+// request's '@proto' file which takes the same form, p.current is the '@'.
+func (p *Parser) parseName(marker string, start int) *ast.Var {
 	p.advance()
+
+	v := &ast.Var{Marker: marker, Name: p.textNode(), Keyword: p.current.Kind}
+
 	// Can either be @name = MyName or @name MyName
 	if p.next.Is(token.Eq) {
 		p.advance()
+
+		v.Eq = true
 	}
 
 	p.expect(token.Text)
 
-	return p.text()
+	value := p.textNode()
+	v.Value = &value
+	v.Span = p.span(start, p.end())
+
+	return v
 }
 
-// parsePrompt parses a prompt declaration e.g. in a global or request variable.
-func (p *Parser) parsePrompt() syntax.Prompt {
+// parsePrompt parses a prompt declaration e.g. in a global or request variable, p.current
+// is the '@'.
+func (p *Parser) parsePrompt(marker string, start int) *ast.Prompt {
 	p.advance()
 
 	p.expect(token.Ident)
-	name := p.text()
 
-	p.expect(token.Text)
-	description := p.text()
+	prompt := &ast.Prompt{Marker: marker, Name: p.textNode()}
 
-	prompt := syntax.Prompt{
-		Name:        name,
-		Description: description,
+	// The description is optional
+	if p.next.Is(token.Text) {
+		p.advance()
+
+		description := p.textNode()
+		prompt.Description = &description
 	}
 
+	prompt.Span = p.span(start, p.end())
+
 	return prompt
 }
 
-// parseVar parses a generic '@ident = <value>' in either global or request scope.
-func (p *Parser) parseVar() (key, value string) {
+// parseVar parses a generic '@ident = <value>' in either global or request scope, p.current
+// is the '@'.
+func (p *Parser) parseVar(marker string, start int) *ast.Var {
 	p.advance()
-	key = p.text()
+
+	v := &ast.Var{Marker: marker, Name: p.textNode(), Keyword: token.Ident}
+
 	// Can either be @ident = value or @ident value
 	if p.next.Is(token.Eq) {
 		p.advance()
+
+		v.Eq = true
 	}
 
 	p.expect(token.URL, token.Text)
@@ -433,9 +774,11 @@ func (p *Parser) parseVar() (key, value string) {
 		p.validateURL(p.text())
 	}
 
-	value = p.text()
+	value := p.textNode()
+	v.Value = &value
+	v.Span = p.span(start, p.end())
 
-	return key, value
+	return v
 }
 
 // validateURL validates a (possibly templated) URL. The validation is on
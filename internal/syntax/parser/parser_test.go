@@ -2,6 +2,7 @@ package parser_test
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"go.followtheprocess.codes/req/internal/syntax"
+	"go.followtheprocess.codes/req/internal/syntax/ast"
 	"go.followtheprocess.codes/req/internal/syntax/parser"
 	"go.followtheprocess.codes/test"
 	"go.followtheprocess.codes/txtar"
@@ -97,13 +99,27 @@ func TestInvalid(t *testing.T) {
 
 			collector := &errorCollector{}
 
-			parser, err := parser.New(name, strings.NewReader(src), collector.handler())
+			p, err := parser.New(name, strings.NewReader(src), collector.handler())
 			test.Ok(t, err)
 
-			_, err = parser.Parse()
+			_, err = p.Parse()
 			test.Err(t, err, test.Context("Parse() failed to return an error given invalid syntax"))
+			test.True(t, errors.Is(err, parser.ErrParse), test.Context("Parse() error was not ErrParse"))
 
-			got := collector.String()
+			var errs syntax.ErrorList
+
+			test.True(t, errors.As(err, &errs), test.Context("Parse() error did not contain a syntax.ErrorList"))
+
+			// The list is sorted by position, the collector sorts lexically
+			lines := make([]string, 0, len(errs))
+			for _, err := range errs {
+				lines = append(lines, err.Error()+"\n")
+			}
+
+			got := strings.Join(lines, "")
+
+			slices.Sort(lines)
+			test.Equal(t, collector.String(), strings.Join(lines, ""), test.Context("handler was not called with every error"))
 
 			if *update {
 				err := archive.Write("want.txt", got)
@@ -200,3 +216,78 @@ func (e *errorCollector) handler() syntax.ErrorHandler {
 		e.errs = append(e.errs, fmt.Sprintf("%s: %s\n", pos, msg))
 	}
 }
+
+// TestParseFile checks the lossless syntax tree returned by ParseFile keeps comments and
+// gives every node a span covering exactly the source it was parsed from.
+func TestParseFile(t *testing.T) {
+	src := `# The base URL
+@base = https://api.com
+
+### Get an item
+// @name GetItem
+# @prompt id The item ID
+GET {{base}}/items/{{id}}
+Accept: application/json
+
+?? status == 200
+
+// Trailing comment
+`
+
+	p, err := parser.New("items.http", strings.NewReader(src), testFailHandler(t))
+	test.Ok(t, err)
+
+	file, err := p.ParseFile()
+	test.Ok(t, err)
+
+	test.Equal(t, len(file.Statements), 2)
+	test.Equal(t, len(file.Requests), 1)
+	test.Equal(t, len(file.Comments), 1)
+
+	request := file.Requests[0]
+
+	test.Equal(t, len(request.Statements), 2)
+	test.Equal(t, len(request.Headers), 1)
+	test.Equal(t, len(request.Assertions), 1)
+
+	comment, ok := file.Statements[0].(*ast.Comment)
+	test.True(t, ok, test.Context("first statement was %T, expected *ast.Comment", file.Statements[0]))
+	test.Equal(t, comment.Marker, "#")
+	test.Equal(t, comment.Text, "The base URL")
+
+	name, ok := request.Statements[0].(*ast.Var)
+	test.True(t, ok, test.Context("first request statement was %T, expected *ast.Var", request.Statements[0]))
+	test.Equal(t, name.Marker, "//")
+
+	test.Equal(t, request.Comment.Marker, "")
+	test.Equal(t, request.Comment.Text, "Get an item")
+	test.Equal(t, file.Comments[0].Text, "Trailing comment")
+
+	// Every span must cover exactly the source text the node was parsed from
+	tests := []struct {
+		node ast.Node // The node under test
+		want string   // The source text it's span should cover
+	}{
+		{node: file.Statements[0], want: "# The base URL"},
+		{node: file.Statements[1], want: "@base = https://api.com"},
+		{node: request.Separator, want: "###"},
+		{node: request.Comment, want: "Get an item"},
+		{node: request.Statements[0], want: "// @name GetItem"},
+		{node: request.Statements[1], want: "# @prompt id The item ID"},
+		{node: request.Method, want: "GET"},
+		{node: request.URL, want: "{{base}}/items/{{id}}"},
+		{node: request.Headers[0], want: "Accept: application/json"},
+		{node: request.Headers[0].Value, want: "application/json"},
+		{node: request.Assertions[0], want: "?? status == 200"},
+		{node: request.Assertions[0].Operator, want: "=="},
+		{node: file.Comments[0], want: "// Trailing comment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			span := tt.node.Range()
+			test.Equal(t, src[span.Start.Offset:span.End.Offset], tt.want)
+			test.Equal(t, span.Start.Name, "items.http")
+		})
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"bytes"
 	"fmt"
 	"iter"
+	"mime"
 	"slices"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -51,6 +53,9 @@ type Scanner struct {
 	pos               int                 // Current scanner position in src (bytes, 0 indexed)
 	line              int                 // Current line number, 1 indexed
 	currentLineOffset int                 // Offset at which the current line started
+	form              bool                // Whether the current request has a form urlencoded Content-Type
+	graphql           bool                // Whether the current request is a GraphQL request
+	grpc              bool                // Whether the current request is a gRPC request
 }
 
 // New returns a new [Scanner] and kicks off the state machine in a goroutine.
@@ -75,7 +80,7 @@ func (s *Scanner) Scan() token.Token {
 	return <-s.tokens
 }
 
-// All returns an iterator over the tokens in the file, stopping at EOF or Error.
+// All returns an iterator over the tokens in the file, stopping at EOF.
 //
 // The final token will still be yielded.
 func (s *Scanner) All() iter.Seq[token.Token] {
@@ -174,8 +179,8 @@ func (s *Scanner) emit(kind token.Kind) {
 }
 
 // run starts the state machine for the scanner, it runs with each [scanFn] returning the next
-// state until one returns nil (typically in response to an error or eof), at which point the tokens channel
-// is closed as a signal to the receiver that no more tokens will be sent.
+// state until one returns nil at eof, at which point the tokens channel is closed as a signal to
+// the receiver that no more tokens will be sent.
 func (s *Scanner) run() {
 	for state := scanStart; state != nil; {
 		state = state(s)
@@ -187,29 +192,27 @@ func (s *Scanner) run() {
 // error calculates the position information and calls the installed error handler
 // with the information, emitting an error token in the process.
 func (s *Scanner) error(msg string) {
-	// So that even if there is no handler installed, we still know something
-	// went wrong
-	s.emit(token.Error)
-
-	if s.handler == nil {
-		// Nothing more to do
-		return
-	}
-
-	// Column is the number of bytes between the last newline and the current position
-	// +1 because columns are 1 indexed
-	startCol := 1 + s.start - s.currentLineOffset
-	endCol := 1 + s.pos - s.currentLineOffset
+	// The handler is called before the token is emitted, so whoever receives the
+	// token knows the error has already been reported
+	if s.handler != nil {
+		// Column is the number of bytes between the last newline and the current position
+		// +1 because columns are 1 indexed
+		col := 1 + s.pos - s.currentLineOffset
+
+		position := syntax.Position{
+			Name:     s.name,
+			Offset:   s.pos,
+			Line:     s.line,
+			StartCol: col,
+			EndCol:   col,
+		}
 
-	position := syntax.Position{
-		Name:     s.name,
-		Offset:   s.pos,
-		Line:     s.line,
-		StartCol: startCol,
-		EndCol:   endCol,
+		s.handler(position, msg)
 	}
 
-	s.handler(position, msg)
+	// So that even if there is no handler installed, we still know something
+	// went wrong
+	s.emit(token.Error)
 }
 
 // errorf calls error with a formatted message.
@@ -225,6 +228,7 @@ func (s *Scanner) errorf(format string, a ...any) {
 //   - '#' for comments and request separators
 //   - '/' for comments
 //   - '@' for global variables
+//   - '??' for response assertions, at the end of a request
 //
 // Everything else must only appear in certain contexts e.g. HTTP methods may *only* appear
 // immediately after a separator. HTTP versions may *only* appear after a URL etc.
@@ -239,19 +243,46 @@ func scanStart(s *Scanner) scanFn {
 		return nil
 	case utf8.RuneError:
 		s.errorf("invalid utf8 character: %U", char)
-		return nil
+		return scanRecover
 	case '#':
 		return scanHash
 	case '/':
 		return scanSlash
 	case '@':
 		return scanAt
+	case '?':
+		return scanQuestion
 	default:
 		switch {
 		case isIdent(char):
 			return scanText
 		default:
 			s.errorf("unrecognised character: %q", char)
+			return scanRecover
+		}
+	}
+}
+
+// scanRecover is the state after a syntax error.
+//
+// It discards everything up to the next '###' at the start of a line so scanning can
+// carry on from the next request, meaning one mistake doesn't hide all the errors
+// after it.
+func scanRecover(s *Scanner) scanFn {
+	for {
+		s.skip(isLineSpace)
+
+		lineStart := len(bytes.TrimSpace(s.src[s.currentLineOffset:s.pos])) == 0
+		if lineStart && bytes.HasPrefix(s.src[s.pos:], []byte("###")) {
+			return scanStart
+		}
+
+		s.takeUntil('\n', eof)
+
+		if s.next() == eof {
+			s.start = s.pos
+			s.emit(token.EOF)
+
 			return nil
 		}
 	}
@@ -324,6 +355,11 @@ func scanSeparator(s *Scanner) scanFn {
 
 	s.emit(token.Separator)
 
+	// A new request, which may not be a form, GraphQL or gRPC
+	s.form = false
+	s.graphql = false
+	s.grpc = false
+
 	// If there is text on the same line as the separator it is a request comment
 	s.skip(isLineSpace)
 
@@ -340,7 +376,7 @@ func scanAt(s *Scanner) scanFn {
 	s.emit(token.At)
 
 	if bytes.HasPrefix(s.src[s.pos:], []byte("http")) {
-		return scanURL
+		return scanVarURL
 	}
 
 	if isAlpha(s.peek()) {
@@ -369,6 +405,9 @@ func scanIdent(s *Scanner) scanFn {
 		// Prompts are handled in a special way as you may have e.g.
 		// @prompt username <Arbitrary description on a single line>
 		return scanPrompt
+	case kind == token.Proto:
+		// @proto [=] ./path/to/service.proto
+		return scanProto
 	case s.peek() == '=':
 		// @var = value
 		return scanEq
@@ -402,6 +441,22 @@ func scanPrompt(s *Scanner) scanFn {
 	return scanStart
 }
 
+// scanProto scans the path to a .proto file after '@proto', with or without an '='.
+func scanProto(s *Scanner) scanFn {
+	if s.peek() == '=' {
+		s.next()
+		s.emit(token.Eq)
+		s.skip(isLineSpace)
+	}
+
+	if isFilePath(s.peek()) {
+		s.takeWhile(isText)
+		s.emit(token.Text)
+	}
+
+	return scanStart
+}
+
 // scanEq scans a '=' character, as used in a variable declaration.
 func scanEq(s *Scanner) scanFn {
 	s.next()
@@ -410,16 +465,26 @@ func scanEq(s *Scanner) scanFn {
 	s.skip(isLineSpace)
 
 	if bytes.HasPrefix(s.src[s.pos:], []byte("http")) {
-		return scanURL
+		return scanVarURL
 	}
 
-	if isAlphaNumeric(s.peek()) {
+	// A value may also start with an interpolation e.g. '@token = {{login.response.body.$.token}}'
+	if isAlphaNumeric(s.peek()) || bytes.HasPrefix(s.src[s.pos:], []byte("{{")) {
 		return scanText
 	}
 
 	return scanStart
 }
 
+// scanVarURL scans a URL as the value of a variable e.g. '@base = https://api.com', unlike
+// the URL of a request it's the end of the statement.
+func scanVarURL(s *Scanner) scanFn {
+	s.takeWhile(isText)
+	s.emit(token.URL)
+
+	return scanStart
+}
+
 // scanText scans a series of continuous text characters (no whitespace).
 func scanText(s *Scanner) scanFn {
 	s.takeWhile(isText)
@@ -433,6 +498,9 @@ func scanText(s *Scanner) scanFn {
 
 	// If it was a HTTP method, we should now have a url following it
 	if wasMethod {
+		s.graphql = kind == token.MethodGraphQL
+		s.grpc = kind == token.MethodGRPC
+
 		return scanURL
 	}
 
@@ -441,10 +509,18 @@ func scanText(s *Scanner) scanFn {
 
 // scanURL scans a series continuous characters (no whitespace) and emits a URL token.
 func scanURL(s *Scanner) scanFn {
-	// It might also be an interpolation
-	if !bytes.HasPrefix(s.src[s.pos:], []byte("http")) && !bytes.HasPrefix(s.src[s.pos:], []byte("{{")) {
+	// It might also be an interpolation or a WebSocket URL, and a gRPC target
+	// needn't have a scheme at all e.g. 'localhost:50051/package.Service/Method'
+	rest := s.src[s.pos:]
+	valid := bytes.HasPrefix(rest, []byte("http")) || bytes.HasPrefix(rest, []byte("ws")) || bytes.HasPrefix(rest, []byte("{{"))
+
+	if s.grpc {
+		valid = isText(s.peek())
+	}
+
+	if !valid {
 		s.errorf("HTTP methods must be followed by a valid URL")
-		return nil
+		return scanRecover
 	}
 
 	s.takeWhile(isText)
@@ -457,19 +533,7 @@ func scanURL(s *Scanner) scanFn {
 		return scanHTTPVersion
 	}
 
-	// Is the next thing headers?
-	s.skip(unicode.IsSpace)
-
-	if isAlpha(s.peek()) {
-		return scanHeaders
-	}
-
-	// Either another request or the end
-	if s.peek() == '#' || s.peek() == eof {
-		return scanStart
-	}
-
-	return scanBody
+	return scanHeadEnd
 }
 
 // scanHTTPVersion scans a HTTP/<version> literal.
@@ -490,7 +554,7 @@ func scanHTTPVersion(s *Scanner) scanFn {
 			// Now what follows *must* be a digit or it's malformed
 			if !isDigit(s.peek()) {
 				s.errorf("bad number literal in HTTP version, illegal char %q", s.peek())
-				return nil
+				return scanRecover
 			}
 			// Consume any remaining digits
 			s.takeWhile(isDigit)
@@ -501,21 +565,7 @@ func scanHTTPVersion(s *Scanner) scanFn {
 
 	// The only thing allowed to follow a HTTP Version is a list of headers
 	// or a request body
-	s.skip(unicode.IsSpace)
-
-	if isAlpha(s.peek()) {
-		// Headers
-		return scanHeaders
-	}
-
-	// Either another request or the end
-	if s.peek() == '#' || s.peek() == eof {
-		return scanStart
-	}
-
-	// Scan the body as just raw text, we then use the 'Content-Type' header to
-	// figure out what it should actually be later on during parsing/eval.
-	return scanBody
+	return scanHeadEnd
 }
 
 // scanHeaders scans a series of HTTP headers, one per line, emitting
@@ -526,18 +576,20 @@ func scanHTTPVersion(s *Scanner) scanFn {
 func scanHeaders(s *Scanner) scanFn {
 	s.takeWhile(isIdent)
 
+	name := string(s.src[s.start:s.pos])
+
 	// Header without a colon or value e.g. 'Content-Type'
 	// this is unfinished so is an error, like an unterminated string literal almost.
 	if s.peek() == eof {
 		s.error("unexpected eof")
-		return nil
+		return scanRecover
 	}
 
 	s.emit(token.Header)
 
 	if s.peek() != ':' {
 		s.errorf("expected ':' got %q", s.peek())
-		return nil
+		return scanRecover
 	}
 
 	s.next() // Consume the ':' we now know exists
@@ -546,33 +598,70 @@ func scanHeaders(s *Scanner) scanFn {
 
 	// The value is just arbitrary text until the end of the line
 	s.takeUntil('\n', eof)
+
+	switch {
+	case strings.EqualFold(name, "Content-Type"):
+		mediaType, _, err := mime.ParseMediaType(string(s.src[s.start:s.pos]))
+		s.form = err == nil && mediaType == "application/x-www-form-urlencoded"
+	case strings.EqualFold(name, "X-Request-Type"):
+		s.graphql = s.graphql || strings.EqualFold(string(bytes.TrimSpace(s.src[s.start:s.pos])), "GraphQL")
+	}
+
 	s.emit(token.Text)
 
-	// Now for the fun bit, call itself if there are more headers
+	// Now for the fun bit, there may be more headers
+	return scanHeadEnd
+}
+
+// scanHeadEnd scans what follows the request line or a header, the end of the line
+// they are on.
+//
+// The head of a request ends at the first blank line, everything after it up to the
+// next '###' is the body however it starts e.g. a markdown '# Title' or CSS 'body {'.
+// Without a blank line the next line may be another header, or a comment or body
+// that can't be mistaken for one.
+func scanHeadEnd(s *Scanner) scanFn {
+	blank := s.atBlankLine()
+
 	s.skip(unicode.IsSpace)
 
-	if isAlpha(s.peek()) {
+	switch {
+	case s.peek() == eof:
+		return scanStart
+	case s.form && s.atField():
+		// A field of a form looks a lot like a header
+		return scanForm
+	case blank:
+		// Scan the body as just raw text, we then use the 'Content-Type' header to
+		// figure out what it should actually be later on during parsing/eval.
+		return scanBody
+	case s.atHeader():
 		return scanHeaders
-	}
-
-	// After headers is either a body, another request separator, or eof
-	if s.peek() == '#' || s.peek() == eof {
+	case s.peek() == '#':
+		// Another request or a comment
 		return scanStart
+	default:
+		return scanBody
 	}
-
-	// Must be a body
-	return scanBody
 }
 
 // scanBody scans a HTTP request body, in a variety of forms:
 //
 //   - '< {filepath}' (Reading the request body from the file)
+//   - '<@ {filepath}' (Reading the request body from the file, interpolating variables)
 //   - raw text body
+//
+// It may also be a '<> {filepath}' response reference for a request with no body.
 func scanBody(s *Scanner) scanFn {
 	if s.peek() == '<' {
 		return scanLeftAngle
 	}
 
+	// No body, straight into assertions
+	if bytes.HasPrefix(s.src[s.pos:], []byte("??")) {
+		return scanStart
+	}
+
 	// Are we redirecting the response, without specifying a body
 	// e.g. in a GET request, there is no body but we still might redirect
 	// the response
@@ -580,8 +669,17 @@ func scanBody(s *Scanner) scanFn {
 		return scanRightAngle
 	}
 
-	// Scan raw body as a single token
-	s.takeUntil('#', eof, '>')
+	// Scan raw body as a single token, it may contain anything (including '#' and '>')
+	// so only ends at something that can't be part of it at the start of a line
+	for !s.atBodyEnd() {
+		s.next()
+	}
+
+	// A blank line straight before the next request, there's no body
+	if s.pos == s.start {
+		return scanStart
+	}
+
 	s.emit(token.Body)
 
 	s.skip(unicode.IsSpace)
@@ -592,14 +690,236 @@ func scanBody(s *Scanner) scanFn {
 		return scanRightAngle
 	}
 
+	// Or referencing a previous response
+	if bytes.HasPrefix(s.src[s.pos:], []byte("<>")) {
+		return scanLeftAngle
+	}
+
 	return scanStart
 }
 
+// scanForm scans a single field of a form urlencoded body, either all on one line or in
+// the multi-line syntax of the VSCode REST Client:
+//
+//	name=foo&password=bar
+//
+//	name=foo
+//	&password=bar
+//
+// The leading '&' of a line is optional. The name is emitted as a [token.Field] and the
+// value, up to the next '&' or the end of the line, as [token.Text].
+func scanForm(s *Scanner) scanFn {
+	if s.peek() == '&' {
+		s.next()
+		s.emit(token.Ampersand)
+		s.skip(isLineSpace)
+	}
+
+	s.takeUntil('=', '&', '\n', eof)
+
+	if s.pos == s.start {
+		s.error("expected a form field name")
+		return scanRecover
+	}
+
+	if s.peek() != '=' {
+		s.errorf("expected '=' after form field name, got %q", s.peek())
+		return scanRecover
+	}
+
+	s.emit(token.Field)
+
+	s.next() // Consume the '='
+	s.emit(token.Eq)
+	s.skip(isLineSpace)
+
+	s.takeUntil('&', '\n', eof)
+	s.emit(token.Text)
+
+	// More fields on the same line
+	if s.peek() == '&' {
+		return scanForm
+	}
+
+	s.skip(unicode.IsSpace)
+
+	// Every line up until the end of the body is another field, a comment ends it
+	// like anything else would
+	if !s.atBodyEnd() && s.peek() != '#' && s.peek() != '<' {
+		return scanForm
+	}
+
+	if s.peek() == '>' {
+		return scanRightAngle
+	}
+
+	if bytes.HasPrefix(s.src[s.pos:], []byte("<>")) {
+		return scanLeftAngle
+	}
+
+	return scanStart
+}
+
+// scanQuestion scans a '??' literal, the start of a response assertion e.g.
+// '?? status == 200'.
+//
+// The first '?' has already been consumed.
+func scanQuestion(s *Scanner) scanFn {
+	if s.peek() != '?' {
+		s.error("unrecognised character: '?'")
+		return scanRecover
+	}
+
+	s.next() // Consume the second '?'
+	s.emit(token.DoubleQuestion)
+	s.skip(isLineSpace)
+
+	return scanAssertion
+}
+
+// scanAssertion scans the rest of an assertion after the '??', in the form
+// '<subject> [selector] <operator> [value]' e.g:
+//
+//	?? status == 200
+//	?? header Content-Type contains json
+//	?? body $.items.length > 0
+//	?? body contains "id"
+//
+// The subject is emitted as an [token.Ident], the selector (a header name or a
+// JSONPath/XPath into the body) and the value as [token.Text] and the operator as
+// [token.Operator]. Whether they're valid is up to the parser.
+func scanAssertion(s *Scanner) scanFn {
+	s.takeWhile(isIdent)
+
+	subject := string(s.src[s.start:s.pos])
+	if subject == "" {
+		s.errorf("expected an assertion subject e.g. status, header or body, got %q", s.peek())
+		return scanRecover
+	}
+
+	s.emit(token.Ident)
+	s.skip(isLineSpace)
+
+	// Headers always need a name but a body selector is optional, and always starts
+	// with '$' (JSONPath) or '/' (XPath) so it can't be confused with an operator
+	if subject == "header" || (subject == "body" && (s.peek() == '$' || s.peek() == '/')) {
+		s.takeWhile(isText)
+		s.emit(token.Text)
+		s.skip(isLineSpace)
+	}
+
+	s.takeWhile(isText)
+
+	if s.pos == s.start {
+		s.error("expected an assertion operator e.g. '==' or 'contains'")
+		return scanRecover
+	}
+
+	s.emit(token.Operator)
+	s.skip(isLineSpace)
+
+	// The value is optional (e.g. for 'exists') and is the rest of the line
+	if s.peek() != '\n' && s.peek() != '\r' && s.peek() != eof {
+		s.takeUntil('\n', eof)
+		s.emit(token.Text)
+	}
+
+	return scanStart
+}
+
+// atBodyEnd reports whether the scanner is at the end of an inline request body. That's
+// either eof or one of the following at the start of a line, ignoring any leading whitespace:
+//
+//   - '###' the next request separator
+//   - '>' or '>>' a response redirect
+//   - '<>' a response reference
+//   - '??' the first response assertion
+func (s *Scanner) atBodyEnd() bool {
+	if s.peek() == eof {
+		return true
+	}
+
+	if len(bytes.TrimLeft(s.src[s.currentLineOffset:s.pos], " \t")) != 0 {
+		return false
+	}
+
+	rest := s.src[s.pos:]
+
+	return bytes.HasPrefix(rest, []byte("###")) ||
+		bytes.HasPrefix(rest, []byte(">")) ||
+		bytes.HasPrefix(rest, []byte("<>")) ||
+		bytes.HasPrefix(rest, []byte("??"))
+}
+
+// atBlankLine reports whether the line after the one the scanner is on is blank, or
+// only whitespace.
+func (s *Scanner) atBlankLine() bool {
+	_, next, found := bytes.Cut(s.src[s.pos:], []byte("\n"))
+	if !found {
+		return false
+	}
+
+	line, _, _ := bytes.Cut(next, []byte("\n"))
+
+	return len(bytes.TrimSpace(line)) == 0
+}
+
+// atHeader reports whether the line the scanner is at is a header, which starts with
+// a letter. So does a GraphQL query e.g. 'query {', so in a GraphQL request the name
+// must also be followed by a ':'.
+func (s *Scanner) atHeader() bool {
+	if !isAlpha(s.peek()) {
+		return false
+	}
+
+	if !s.graphql {
+		return true
+	}
+
+	rest := s.src[s.pos:]
+	end := bytes.IndexFunc(rest, func(r rune) bool { return !isIdent(r) })
+
+	return end != -1 && rest[end] == ':'
+}
+
+// atField reports whether the line the scanner is at is a form field e.g. 'name=value'
+// or '&name=value' rather than a header, which has it's ':' before any '='.
+func (s *Scanner) atField() bool {
+	line, _, _ := bytes.Cut(s.src[s.pos:], []byte("\n"))
+
+	if bytes.HasPrefix(line, []byte("&")) {
+		return true
+	}
+
+	eq := bytes.IndexByte(line, '=')
+	colon := bytes.IndexByte(line, ':')
+
+	return eq > 0 && (colon == -1 || eq < colon)
+}
+
 // scanLeftAngle scans a '<' literal in the context of a request body
-// read from file.
+// read from file, or a '<@' if the contents of that file should have
+// variable interpolation applied.
+//
+// It also handles '<>', a response reference to the file the response is
+// saved to and compared against.
 func scanLeftAngle(s *Scanner) scanFn {
 	s.next() // Consume the '<'
-	s.emit(token.LeftAngle)
+
+	reference := false
+
+	switch s.peek() {
+	case '@':
+		s.next() // Consume the '@'
+		s.emit(token.LeftAngleAt)
+	case '>':
+		s.next() // Consume the '>'
+		s.emit(token.LeftRightAngle)
+
+		reference = true
+	default:
+		s.emit(token.LeftAngle)
+	}
 
 	s.skip(isLineSpace)
 
@@ -610,19 +930,45 @@ func scanLeftAngle(s *Scanner) scanFn {
 
 	s.skip(unicode.IsSpace)
 
+	// A response reference is always last
+	if reference {
+		return scanStart
+	}
+
 	// Are we redirecting the response *after* a body has been specified by a file
 	if s.peek() == '>' {
 		return scanRightAngle
 	}
 
+	// Or referencing a previous response
+	if bytes.HasPrefix(s.src[s.pos:], []byte("<>")) {
+		return scanLeftAngle
+	}
+
 	return scanStart
 }
 
 // scanRightAngle scans a '>' literal in the context of a response redirect
 // to a local file.
+//
+// It also handles '>>' (redirect, adding a unique suffix to the filename if it
+// already exists) and '>>!' (redirect, overwriting the file if it already exists).
 func scanRightAngle(s *Scanner) scanFn {
 	s.next() // Consume the '>'
-	s.emit(token.RightAngle)
+
+	switch {
+	case s.peek() != '>':
+		s.emit(token.RightAngle)
+	default:
+		s.next() // Consume the second '>'
+
+		if s.peek() == '!' {
+			s.next() // Consume the '!'
+			s.emit(token.DoubleRightAngleBang)
+		} else {
+			s.emit(token.DoubleRightAngle)
+		}
+	}
 
 	s.skip(isLineSpace)
 
@@ -631,6 +977,13 @@ func scanRightAngle(s *Scanner) scanFn {
 		s.emit(token.Text)
 	}
 
+	s.skip(unicode.IsSpace)
+
+	// A response reference may follow the redirect
+	if bytes.HasPrefix(s.src[s.pos:], []byte("<>")) {
+		return scanLeftAngle
+	}
+
 	return scanStart
 }
 
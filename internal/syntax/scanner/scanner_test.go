@@ -347,7 +347,7 @@ func FuzzScanner(f *testing.F) {
 			// Property: The kind must be one of the known kinds
 			test.True(
 				t,
-				(tok.Kind >= token.EOF) && (tok.Kind <= token.NoRedirect),
+				(tok.Kind >= token.EOF) && (tok.Kind <= token.Proto),
 				test.Context("token %s was not one of the pre-defined kinds", tok),
 			)
 
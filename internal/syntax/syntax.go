@@ -80,6 +80,72 @@ func (p Position) String() string {
 	return fmt.Sprintf("%s:%d:%d-%d", p.Name, p.Line, p.StartCol, p.EndCol)
 }
 
+// Error is a single syntax error at a position in the source.
+type Error struct {
+	Msg string   // The error message
+	Pos Position // Where the error is
+}
+
+// Error implements the error interface for [Error].
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is a list of syntax errors, it's what the parser returns when a file has
+// one or more syntax errors.
+type ErrorList []Error
+
+// Add appends an [Error] to the list.
+func (l *ErrorList) Add(pos Position, msg string) {
+	*l = append(*l, Error{Pos: pos, Msg: msg})
+}
+
+// Sort sorts the list by position in the source.
+func (l ErrorList) Sort() {
+	slices.SortStableFunc(l, func(a, b Error) int {
+		if a.Pos.Name != b.Pos.Name {
+			return strings.Compare(a.Pos.Name, b.Pos.Name)
+		}
+
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line - b.Pos.Line
+		}
+
+		return a.Pos.StartCol - b.Pos.StartCol
+	})
+}
+
+// Error implements the error interface for an [ErrorList], reporting the first
+// error and how many more there are.
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	more := len(l) - 1
+
+	noun := "errors"
+	if more == 1 {
+		noun = "error"
+	}
+
+	return fmt.Sprintf("%s (and %d more %s)", l[0], more, noun)
+}
+
+// Report calls handler with each error in the list, in order.
+func (l ErrorList) Report(handler ErrorHandler) {
+	if handler == nil {
+		return
+	}
+
+	for _, err := range l {
+		handler(err.Pos, err.Msg)
+	}
+}
+
 // File represents a single .http file as parsed.
 //
 // It is *nearly* concrete but may have e.g. variable interpolation to perform, URLs
@@ -158,8 +224,9 @@ type Request struct {
 	// Request scoped variables
 	Vars map[string]string `json:"vars,omitempty"`
 
-	// Request headers, may have variable interpolation in the values but not the keys
-	Headers map[string]string `json:"headers,omitempty"`
+	// Request headers in the order they were declared, may have variable interpolation
+	// in the values but not the names. A header may appear more than once
+	Headers []Header `json:"headers,omitempty"`
 
 	// Request scoped prompts, the user will be asked to provide values for each of these
 	// whenever this particular request is invoked.
@@ -187,13 +254,30 @@ type Request struct {
 	// to that local file (relative to the .http file)
 	BodyFile string `json:"bodyFile,omitempty"`
 
+	// Whether variable interpolation should be performed on the contents
+	// of BodyFile, declared with '<@ ./body.json' rather than '< ./body.json'
+	InterpolateBodyFile bool `json:"interpolateBodyFile,omitempty"`
+
 	// If a response redirect was provided, this is the path to the local file into
 	// which to write the response (relative to the .http file)
 	ResponseFile string `json:"responseFile,omitempty"`
 
+	// Whether the response redirect was declared with '>>', in which case if ResponseFile
+	// already exists, a numeric suffix is added to the filename rather than overwriting it
+	UniqueResponseFile bool `json:"uniqueResponseFile,omitempty"`
+
+	// If a response reference was provided e.g. '<> ./previous.json', this is the path
+	// to the local file the response is saved to and compared against on the next
+	// run (relative to the .http file)
+	ResponseReference string `json:"responseReference,omitempty"`
+
 	// Request body, if provided inline. Again, may have variable interpolation still to perform
 	Body []byte `json:"body,omitempty"`
 
+	// The fields of a form urlencoded body, declared one per line after a form Content-Type
+	// in place of an inline body, in the order they were declared
+	Form []Field `json:"form,omitempty"`
+
 	// Request scoped timeout, overrides global if set
 	Timeout time.Duration `json:"timeout,omitempty"`
 
@@ -202,6 +286,32 @@ type Request struct {
 
 	// Disable following redirects for this request, overrides global if set
 	NoRedirect bool `json:"noRedirect,omitempty"`
+
+	// The path to a .proto file declaring the service of a gRPC request, from '@proto'
+	// (relative to the .http file). If empty, the server's reflection service is used
+	Proto string `json:"proto,omitempty"`
+
+	// Assertions on the response declared with '??', in the order they appear
+	Assertions []Assertion `json:"assertions,omitempty"`
+
+	// Source positions of the values that may contain variable interpolation, used
+	// to report errors during resolution
+	Positions Positions `json:"-"`
+}
+
+// Positions holds the source positions of the values in a [Request] that may contain
+// variable interpolation, each pointing to the first character of the value.
+//
+// Values constructed other than by parsing a file will have zero (invalid) positions.
+type Positions struct {
+	// Request variables, keyed by variable name
+	Vars map[string]Position
+
+	// The request URL
+	URL Position
+
+	// The inline request body
+	Body Position
 }
 
 // String implements [fmt.Stringer] for a [Request].
@@ -240,31 +350,55 @@ func (r Request) String() string {
 		fmt.Fprintf(builder, "# @no-redirect = %v\n", r.NoRedirect)
 	}
 
+	if r.Proto != "" {
+		fmt.Fprintf(builder, "# @proto = %s\n", r.Proto)
+	}
+
 	if r.HTTPVersion != "" {
 		fmt.Fprintf(builder, "%s %s %s\n", r.Method, r.URL, r.HTTPVersion)
 	} else {
 		fmt.Fprintf(builder, "%s %s\n", r.Method, r.URL)
 	}
 
-	for _, key := range slices.Sorted(maps.Keys(r.Headers)) {
-		fmt.Fprintf(builder, "%s: %s\n", key, r.Headers[key])
+	for _, header := range r.Headers {
+		fmt.Fprintf(builder, "%s\n", header)
 	}
 
 	// Separate the body section
-	if r.Body != nil || r.BodyFile != "" || r.ResponseFile != "" {
+	if r.Body != nil || len(r.Form) > 0 || r.BodyFile != "" || r.ResponseFile != "" || r.ResponseReference != "" || len(r.Assertions) > 0 {
 		builder.WriteString("\n")
 	}
 
 	if r.BodyFile != "" {
-		fmt.Fprintf(builder, "< %s\n", r.BodyFile)
+		if r.InterpolateBodyFile {
+			fmt.Fprintf(builder, "<@ %s\n", r.BodyFile)
+		} else {
+			fmt.Fprintf(builder, "< %s\n", r.BodyFile)
+		}
 	}
 
 	if r.Body != nil {
 		fmt.Fprintf(builder, "%s\n", string(r.Body))
 	}
 
+	for _, field := range r.Form {
+		fmt.Fprintf(builder, "%s\n", field)
+	}
+
 	if r.ResponseFile != "" {
-		fmt.Fprintf(builder, "> %s\n", r.ResponseFile)
+		if r.UniqueResponseFile {
+			fmt.Fprintf(builder, ">> %s\n", r.ResponseFile)
+		} else {
+			fmt.Fprintf(builder, "> %s\n", r.ResponseFile)
+		}
+	}
+
+	if r.ResponseReference != "" {
+		fmt.Fprintf(builder, "<> %s\n", r.ResponseReference)
+	}
+
+	for _, assertion := range r.Assertions {
+		fmt.Fprintf(builder, "%s\n", assertion)
 	}
 
 	return builder.String()
@@ -288,6 +422,104 @@ func (p Prompt) String() string {
 	return fmt.Sprintf("@prompt %s\n", p.Name)
 }
 
+// Header is a single HTTP header declared in a request.
+type Header struct {
+	// The header name e.g. "Accept"
+	Name string `json:"name"`
+
+	// The header value, may have variable interpolation still to perform
+	Value string `json:"value"`
+
+	// Source position of the value, for reporting interpolation errors
+	Pos Position `json:"-"`
+}
+
+// String implements [fmt.Stringer] for a [Header].
+func (h Header) String() string {
+	return h.Name + ": " + h.Value
+}
+
+// Field is a single field of a form urlencoded body e.g. 'name=foo'.
+type Field struct {
+	// The field name
+	Name string `json:"name"`
+
+	// The field value, may have variable interpolation still to perform
+	Value string `json:"value"`
+
+	// Source position of the value, for reporting interpolation errors
+	Pos Position `json:"-"`
+}
+
+// String implements [fmt.Stringer] for a [Field].
+func (f Field) String() string {
+	return f.Name + "=" + f.Value
+}
+
+// Assertion is a check on the response to a request, declared after the request
+// in the form '?? <subject> [selector] <operator> [value]' e.g. '?? status == 200'.
+type Assertion struct {
+	// What is being checked, one of "status", "header" or "body"
+	Subject string `json:"subject"`
+
+	// The header name for a "header" subject, or an optional JSONPath ('$...') or
+	// XPath ('/...') into the response body for a "body" subject
+	Selector string `json:"selector,omitempty"`
+
+	// The comparison e.g. "==" or "contains"
+	Operator string `json:"operator"`
+
+	// The expected value, may have variable interpolation still to perform. Empty
+	// for operators that don't need one e.g. "exists"
+	Value string `json:"value,omitempty"`
+
+	// Source position of the entire assertion, for reporting failures
+	Pos Position `json:"-"`
+
+	// Source position of the value, for reporting interpolation errors
+	ValuePos Position `json:"-"`
+}
+
+// String implements [fmt.Stringer] for an [Assertion].
+func (a Assertion) String() string {
+	parts := []string{"??", a.Subject}
+
+	if a.Selector != "" {
+		parts = append(parts, a.Selector)
+	}
+
+	parts = append(parts, a.Operator)
+
+	if a.Value != "" {
+		parts = append(parts, a.Value)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// operators are the valid assertion operators.
+var operators = []string{"==", "!=", "<", "<=", ">", ">=", "contains", "!contains", "matches", "exists", "!exists"}
+
+// LookupOperator reports whether op is a valid assertion operator and if so,
+// whether it needs a value to compare against.
+func LookupOperator(op string) (needsValue, ok bool) {
+	switch op {
+	case "exists", "!exists":
+		return false, true
+	default:
+		if slices.Contains(operators, op) {
+			return true, true
+		}
+
+		return false, false
+	}
+}
+
+// Operators returns all the valid assertion operators.
+func Operators() []string {
+	return slices.Clone(operators)
+}
+
 // PrettyConsoleHandler returns a [ErrorHandler] that formats the syntax error for
 // display on the terminal to a user.
 func PrettyConsoleHandler(w io.Writer) ErrorHandler {
@@ -160,10 +160,10 @@ func TestFileString(t *testing.T) {
 					{
 						Method: http.MethodPost,
 						URL:    "https://api.com/v1/items/123",
-						Headers: map[string]string{
-							"Accept":        "application/json",
-							"Content-Type":  "application/json",
-							"Authorization": "Bearer xxxxx",
+						Headers: []syntax.Header{
+							{Name: "Accept", Value: "application/json"},
+							{Name: "Content-Type", Value: "application/json"},
+							{Name: "Authorization", Value: "Bearer xxxxx"},
 						},
 					},
 				},
@@ -206,6 +206,24 @@ func TestFileString(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "request with interpolated body file",
+			file: syntax.File{
+				Name: "Requests",
+				Vars: map[string]string{
+					"base": "https://api.com/v1",
+				},
+				Requests: []syntax.Request{
+					{
+						Name:                "AnotherRequest",
+						Method:              http.MethodPost,
+						URL:                 "https://api.com/v1/items/123",
+						BodyFile:            "./body.json",
+						InterpolateBodyFile: true,
+					},
+				},
+			},
+		},
 		{
 			name: "request with body",
 			file: syntax.File{
@@ -240,6 +258,40 @@ func TestFileString(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "request with unique response file",
+			file: syntax.File{
+				Name: "Requests",
+				Vars: map[string]string{
+					"base": "https://api.com/v1",
+				},
+				Requests: []syntax.Request{
+					{
+						Method:             http.MethodPost,
+						URL:                "https://api.com/v1/items/123",
+						ResponseFile:       "./response.json",
+						UniqueResponseFile: true,
+					},
+				},
+			},
+		},
+		{
+			name: "request with response reference",
+			file: syntax.File{
+				Name: "Requests",
+				Vars: map[string]string{
+					"base": "https://api.com/v1",
+				},
+				Requests: []syntax.Request{
+					{
+						Method:            http.MethodGet,
+						URL:               "https://api.com/v1/items/123",
+						ResponseFile:      "./response.json",
+						ResponseReference: "./previous.200.json",
+					},
+				},
+			},
+		},
 		{
 			name: "request with prompts",
 			file: syntax.File{
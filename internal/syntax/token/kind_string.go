@@ -18,34 +18,47 @@ func _() {
 	_ = x[At-7]
 	_ = x[Eq-8]
 	_ = x[Colon-9]
-	_ = x[LeftAngle-10]
-	_ = x[RightAngle-11]
-	_ = x[HTTPVersion-12]
-	_ = x[Header-13]
-	_ = x[Body-14]
-	_ = x[MethodGet-15]
-	_ = x[MethodHead-16]
-	_ = x[MethodPost-17]
-	_ = x[MethodPut-18]
-	_ = x[MethodDelete-19]
-	_ = x[MethodConnect-20]
-	_ = x[MethodPatch-21]
-	_ = x[MethodOptions-22]
-	_ = x[MethodTrace-23]
-	_ = x[Name-24]
-	_ = x[Prompt-25]
-	_ = x[Timeout-26]
-	_ = x[ConnectionTimeout-27]
-	_ = x[NoRedirect-28]
+	_ = x[Ampersand-10]
+	_ = x[LeftAngle-11]
+	_ = x[LeftAngleAt-12]
+	_ = x[RightAngle-13]
+	_ = x[DoubleRightAngle-14]
+	_ = x[DoubleRightAngleBang-15]
+	_ = x[LeftRightAngle-16]
+	_ = x[DoubleQuestion-17]
+	_ = x[Operator-18]
+	_ = x[HTTPVersion-19]
+	_ = x[Header-20]
+	_ = x[Field-21]
+	_ = x[Body-22]
+	_ = x[MethodGet-23]
+	_ = x[MethodHead-24]
+	_ = x[MethodPost-25]
+	_ = x[MethodPut-26]
+	_ = x[MethodDelete-27]
+	_ = x[MethodConnect-28]
+	_ = x[MethodPatch-29]
+	_ = x[MethodOptions-30]
+	_ = x[MethodTrace-31]
+	_ = x[MethodGraphQL-32]
+	_ = x[MethodWebSocket-33]
+	_ = x[MethodGRPC-34]
+	_ = x[Name-35]
+	_ = x[Prompt-36]
+	_ = x[Timeout-37]
+	_ = x[ConnectionTimeout-38]
+	_ = x[NoRedirect-39]
+	_ = x[Proto-40]
 }
 
-const _Kind_name = "EOFErrorSeparatorCommentTextURLIdentAtEqColonLeftAngleRightAngleHTTPVersionHeaderBodyMethodGetMethodHeadMethodPostMethodPutMethodDeleteMethodConnectMethodPatchMethodOptionsMethodTraceNamePromptTimeoutConnectionTimeoutNoRedirect"
+const _Kind_name = "EOFErrorSeparatorCommentTextURLIdentAtEqColonAmpersandLeftAngleLeftAngleAtRightAngleDoubleRightAngleDoubleRightAngleBangLeftRightAngleDoubleQuestionOperatorHTTPVersionHeaderFieldBodyMethodGetMethodHeadMethodPostMethodPutMethodDeleteMethodConnectMethodPatchMethodOptionsMethodTraceMethodGraphQLMethodWebSocketMethodGRPCNamePromptTimeoutConnectionTimeoutNoRedirectProto"
 
-var _Kind_index = [...]uint8{0, 3, 8, 17, 24, 28, 31, 36, 38, 40, 45, 54, 64, 75, 81, 85, 94, 104, 114, 123, 135, 148, 159, 172, 183, 187, 193, 200, 217, 227}
+var _Kind_index = [...]uint16{0, 3, 8, 17, 24, 28, 31, 36, 38, 40, 45, 54, 63, 74, 84, 100, 120, 134, 148, 156, 167, 173, 178, 182, 191, 201, 211, 220, 232, 245, 256, 269, 280, 293, 308, 318, 322, 328, 335, 352, 362, 367}
 
 func (i Kind) String() string {
-	if i < 0 || i >= Kind(len(_Kind_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_Kind_index)-1 {
 		return "Kind(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _Kind_name[_Kind_index[i]:_Kind_index[i+1]]
+	return _Kind_name[_Kind_index[idx]:_Kind_index[idx+1]]
 }
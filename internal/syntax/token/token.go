@@ -11,35 +11,47 @@ type Kind int
 
 //go:generate stringer -type Kind -linecomment
 const (
-	EOF               Kind = iota // EOF
-	Error                         // Error
-	Separator                     // Separator
-	Comment                       // Comment
-	Text                          // Text
-	URL                           // URL
-	Ident                         // Ident
-	At                            // At
-	Eq                            // Eq
-	Colon                         // Colon
-	LeftAngle                     // LeftAngle
-	RightAngle                    // RightAngle
-	HTTPVersion                   // HTTPVersion
-	Header                        // Header
-	Body                          // Body
-	MethodGet                     // MethodGet
-	MethodHead                    // MethodHead
-	MethodPost                    // MethodPost
-	MethodPut                     // MethodPut
-	MethodDelete                  // MethodDelete
-	MethodConnect                 // MethodConnect
-	MethodPatch                   // MethodPatch
-	MethodOptions                 // MethodOptions
-	MethodTrace                   // MethodTrace
-	Name                          // Name
-	Prompt                        // Prompt
-	Timeout                       // Timeout
-	ConnectionTimeout             // ConnectionTimeout
-	NoRedirect                    // NoRedirect
+	EOF                  Kind = iota // EOF
+	Error                            // Error
+	Separator                        // Separator
+	Comment                          // Comment
+	Text                             // Text
+	URL                              // URL
+	Ident                            // Ident
+	At                               // At
+	Eq                               // Eq
+	Colon                            // Colon
+	Ampersand                        // Ampersand
+	LeftAngle                        // LeftAngle
+	LeftAngleAt                      // LeftAngleAt
+	RightAngle                       // RightAngle
+	DoubleRightAngle                 // DoubleRightAngle
+	DoubleRightAngleBang             // DoubleRightAngleBang
+	LeftRightAngle                   // LeftRightAngle
+	DoubleQuestion                   // DoubleQuestion
+	Operator                         // Operator
+	HTTPVersion                      // HTTPVersion
+	Header                           // Header
+	Field                            // Field
+	Body                             // Body
+	MethodGet                        // MethodGet
+	MethodHead                       // MethodHead
+	MethodPost                       // MethodPost
+	MethodPut                        // MethodPut
+	MethodDelete                     // MethodDelete
+	MethodConnect                    // MethodConnect
+	MethodPatch                      // MethodPatch
+	MethodOptions                    // MethodOptions
+	MethodTrace                      // MethodTrace
+	MethodGraphQL                    // MethodGraphQL
+	MethodWebSocket                  // MethodWebSocket
+	MethodGRPC                       // MethodGRPC
+	Name                             // Name
+	Prompt                           // Prompt
+	Timeout                          // Timeout
+	ConnectionTimeout                // ConnectionTimeout
+	NoRedirect                       // NoRedirect
+	Proto                            // Proto
 )
 
 // Token is a lexical token in a .http file.
@@ -81,6 +93,12 @@ func Method(text string) (kind Kind, ok bool) {
 		return MethodOptions, true
 	case "TRACE":
 		return MethodTrace, true
+	case "GRAPHQL":
+		return MethodGraphQL, true
+	case "WEBSOCKET":
+		return MethodWebSocket, true
+	case "GRPC":
+		return MethodGRPC, true
 	default:
 		return Text, false
 	}
@@ -88,7 +106,7 @@ func Method(text string) (kind Kind, ok bool) {
 
 // IsMethod reports whether the given kind is a HTTP Method.
 func IsMethod(kind Kind) bool {
-	return kind >= MethodGet && kind <= MethodTrace
+	return kind >= MethodGet && kind <= MethodGRPC
 }
 
 // Keyword reports whether a string refers to a keyword, returning it's [Kind]
@@ -105,6 +123,8 @@ func Keyword(text string) (kind Kind, ok bool) {
 		return ConnectionTimeout, true
 	case "no-redirect":
 		return NoRedirect, true
+	case "proto":
+		return Proto, true
 	default:
 		return Ident, false
 	}
@@ -41,6 +41,9 @@ func TestMethod(t *testing.T) {
 		{text: "PATCH", want: token.MethodPatch, ok: true},
 		{text: "OPTIONS", want: token.MethodOptions, ok: true},
 		{text: "TRACE", want: token.MethodTrace, ok: true},
+		{text: "GRAPHQL", want: token.MethodGraphQL, ok: true},
+		{text: "WEBSOCKET", want: token.MethodWebSocket, ok: true},
+		{text: "GRPC", want: token.MethodGRPC, ok: true},
 		{text: "word", want: token.Text, ok: false},
 		{text: "patch", want: token.Text, ok: false},
 		{text: "get", want: token.Text, ok: false},
@@ -66,6 +69,7 @@ func TestKeyword(t *testing.T) {
 		{text: "timeout", want: token.Timeout, ok: true},
 		{text: "connection-timeout", want: token.ConnectionTimeout, ok: true},
 		{text: "no-redirect", want: token.NoRedirect, ok: true},
+		{text: "proto", want: token.Proto, ok: true},
 		{text: "something-else", want: token.Ident, ok: false},
 		{text: "base", want: token.Ident, ok: false},
 		{text: "myVar", want: token.Ident, ok: false},
@@ -2,9 +2,11 @@
 package list
 
 import (
+	"fmt"
+
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
-	"go.followtheprocess.codes/req/internal/spec"
+	"go.followtheprocess.codes/req/internal/syntax"
 )
 
 // Model is the list tea Model.
@@ -14,10 +16,14 @@ type Model struct {
 }
 
 // New returns a new [Model].
-func New(title string, requests []spec.Request) Model {
+//
+// The requests are shown as parsed, rather than resolved, as resolving them
+// may involve prompting the user for values which should only happen once a
+// request has been picked.
+func New(title string, requests []syntax.Request) Model {
 	items := make([]list.Item, 0, len(requests))
 	for _, request := range requests {
-		items = append(items, request)
+		items = append(items, item(request))
 	}
 
 	l := list.New(items, list.NewDefaultDelegate(), 0, 0)
@@ -67,3 +73,23 @@ func (m Model) View() string {
 func (m Model) Selected() string {
 	return m.selected
 }
+
+// item wraps a [syntax.Request] so it can be shown in the list.
+//
+// See https://github.com/charmbracelet/bubbles/tree/master/list#adding-custom-items.
+type item syntax.Request
+
+// FilterValue helps implement [list.Item].
+func (i item) FilterValue() string {
+	return i.Name
+}
+
+// Title returns the request's name.
+func (i item) Title() string {
+	return i.Name
+}
+
+// Description returns a description of the request, in this case the method and URL.
+func (i item) Description() string {
+	return fmt.Sprintf("%s %s", i.Method, i.URL)
+}
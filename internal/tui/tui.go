@@ -7,7 +7,6 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"go.followtheprocess.codes/req/internal/req"
-	"go.followtheprocess.codes/req/internal/spec"
 	"go.followtheprocess.codes/req/internal/syntax"
 	"go.followtheprocess.codes/req/internal/syntax/parser"
 	"go.followtheprocess.codes/req/internal/tui/components/filepicker"
@@ -21,8 +20,16 @@ import (
 // - Once selected a file, parse it and then have a fancy list bubble of the http request the cursor is on
 // - On enter, it's basically now just `req do <file> <request>` so close the TUI and do the request
 
+// Options are the flags passed to the TUI.
+type Options struct {
+	Env     string   // Name of the environment to use when sending the picked request
+	VarFile string   // Path to a dotenv or JSON file of variable overrides
+	Vars    []string // Variable overrides as "name=value"
+	Seed    string   // If set, seeds dynamic variables so they're reproducible
+}
+
 // Run runs the TUI, this is what happens when users call `req` with no arguments.
-func Run() error {
+func Run(options Options) error {
 	model := filepicker.New()
 
 	tm, err := tea.NewProgram(&model).Run()
@@ -53,12 +60,7 @@ func Run() error {
 		return fmt.Errorf("%w: %s is not valid http syntax", err, file)
 	}
 
-	resolved, err := spec.ResolveFile(raw)
-	if err != nil {
-		return err
-	}
-
-	listModel := list.New("HTTP Requests in "+file, resolved.Requests)
+	listModel := list.New("HTTP Requests in "+file, raw.Requests)
 
 	tm, err = tea.NewProgram(&listModel, tea.WithAltScreen()).Run()
 	if err != nil {
@@ -74,11 +76,15 @@ func Run() error {
 
 	// TODO(@FollowTheProcess): This parses the file again
 
-	app := req.New(os.Stdout, os.Stderr, false)
-	options := req.DoOptions{
+	app := req.New(os.Stdin, os.Stdout, os.Stderr, false)
+	doOptions := req.DoOptions{
+		Env:               options.Env,
+		VarFile:           options.VarFile,
+		Vars:              options.Vars,
+		Seed:              options.Seed,
 		Timeout:           req.DefaultTimeout,
 		ConnectionTimeout: req.DefaultConnectionTimeout,
 	}
 
-	return app.Do(file, request, options)
+	return app.Do(file, request, doOptions)
 }
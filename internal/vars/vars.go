@@ -0,0 +1,202 @@
+// Package vars implements parsing of variables passed to req from outside of a .http file,
+// e.g. with the '--var' and '--var-file' flags.
+//
+// Variable files may be either JSON or dotenv formatted. A JSON file must be a single
+// flat object of variable name to value:
+//
+//	{
+//	  "base": "https://api.company.com",
+//	  "version": 2
+//	}
+//
+// Anything else is parsed as a dotenv file:
+//
+//	# Comments are allowed
+//	BASE=https://api.company.com
+//	export VERSION=2
+//	GREETING="hello\nworld"
+//	LITERAL='no $escapes\n here'
+package vars
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Parse parses a list of "name=value" pairs e.g. from command line flags
+// into a map of variable name to value.
+//
+// If a name is given more than once, the last one wins.
+func Parse(pairs []string) (map[string]string, error) {
+	values := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("bad variable %q, expected 'name=value'", pair)
+		}
+
+		values[strings.TrimSpace(name)] = value
+	}
+
+	return values, nil
+}
+
+// ReadFile reads a variable file, returning a map of variable name to value.
+//
+// Files with a '.json' extension are parsed as JSON, anything else as dotenv.
+func ReadFile(path string) (map[string]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read variable file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(contents, &raw); err != nil {
+			return nil, fmt.Errorf("invalid variable file %s: %w", path, err)
+		}
+
+		return FromJSON(raw), nil
+	}
+
+	values, err := ParseDotenv(bytes.NewReader(contents))
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// FromJSON converts a JSON object of variables to a map of variable name to value.
+//
+// Strings are unquoted and everything else (numbers, bools, objects etc.) is used
+// as it's compact JSON representation. A null value becomes the empty string.
+func FromJSON(raw map[string]json.RawMessage) map[string]string {
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[key] = stringify(value)
+	}
+
+	return values
+}
+
+// ParseDotenv parses dotenv formatted variables from r.
+//
+// Each non-blank line that isn't a comment must be of the form 'NAME=value', optionally
+// prefixed with 'export'. Values may be double quoted, in which case the escapes '\n', '\t',
+// '\"' and '\\' are expanded, or single quoted in which case they are taken literally.
+// Unquoted values have surrounding whitespace and any trailing ' #' comment removed.
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+
+		name, raw, ok := strings.Cut(text, "=")
+		name = strings.TrimSpace(name)
+
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("line %d: expected 'NAME=value', got %q", line, text)
+		}
+
+		value, err := dotenvValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		values[name] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// dotenvValue parses the value part of a dotenv line, handling quoting.
+func dotenvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch quote := raw[0]; quote {
+	case '"', '\'':
+		end := closingQuote(raw, quote)
+		if end == -1 {
+			return "", fmt.Errorf("unterminated quoted value %s", raw)
+		}
+
+		if rest := strings.TrimSpace(raw[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected text after quoted value: %q", rest)
+		}
+
+		value := raw[1:end]
+		if quote == '\'' {
+			return value, nil
+		}
+
+		return unescape(value), nil
+	default:
+		if before, _, found := strings.Cut(raw, " #"); found {
+			raw = before
+		}
+
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// closingQuote returns the index of the quote closing the quoted string starting
+// at s[0], or -1 if there isn't one.
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			// Only double quotes support escapes
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// unescape expands the escape sequences allowed in a double quoted dotenv value.
+func unescape(s string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\r`, "\r", `\"`, `"`, `\\`, `\`)
+	return replacer.Replace(s)
+}
+
+// stringify converts a raw JSON value to it's string form for use as a variable.
+func stringify(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, raw); err != nil {
+		return string(raw)
+	}
+
+	return buf.String()
+}
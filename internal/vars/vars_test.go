@@ -0,0 +1,230 @@
+package vars_test
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.followtheprocess.codes/req/internal/vars"
+	"go.followtheprocess.codes/test"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		want    map[string]string // Expected variables
+		name    string            // Name of the test case
+		pairs   []string          // Input pairs
+		wantErr bool              // Whether we want an error
+	}{
+		{
+			name:  "empty",
+			pairs: nil,
+			want:  map[string]string{},
+		},
+		{
+			name:  "valid",
+			pairs: []string{"base=https://api.com", "id=123"},
+			want:  map[string]string{"base": "https://api.com", "id": "123"},
+		},
+		{
+			name:  "equals in value",
+			pairs: []string{"query=a=b"},
+			want:  map[string]string{"query": "a=b"},
+		},
+		{
+			name:  "last wins",
+			pairs: []string{"id=1", "id=2"},
+			want:  map[string]string{"id": "2"},
+		},
+		{
+			name:  "empty value",
+			pairs: []string{"id="},
+			want:  map[string]string{"id": ""},
+		},
+		{
+			name:    "missing equals",
+			pairs:   []string{"id"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			pairs:   []string{"=123"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vars.Parse(tt.pairs)
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.EqualFunc(t, got, tt.want, maps.Equal)
+			}
+		})
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		want    map[string]string // Expected variables
+		name    string            // Name of the test case
+		src     string            // Dotenv source
+		wantErr bool              // Whether we want an error
+	}{
+		{
+			name: "empty",
+			src:  "",
+			want: map[string]string{},
+		},
+		{
+			name: "simple",
+			src:  "BASE=https://api.com\nID=123\n",
+			want: map[string]string{"BASE": "https://api.com", "ID": "123"},
+		},
+		{
+			name: "comments and blank lines",
+			src:  "# A comment\n\nBASE=https://api.com\n   # Indented comment\n",
+			want: map[string]string{"BASE": "https://api.com"},
+		},
+		{
+			name: "export",
+			src:  "export BASE=https://api.com",
+			want: map[string]string{"BASE": "https://api.com"},
+		},
+		{
+			name: "whitespace",
+			src:  "  BASE =  https://api.com  ",
+			want: map[string]string{"BASE": "https://api.com"},
+		},
+		{
+			name: "inline comment",
+			src:  "BASE=https://api.com # The base URL\nFRAGMENT=https://api.com/#section",
+			want: map[string]string{"BASE": "https://api.com", "FRAGMENT": "https://api.com/#section"},
+		},
+		{
+			name: "double quoted",
+			src:  `GREETING="hello\nworld \"quoted\" # not a comment" # A comment`,
+			want: map[string]string{"GREETING": "hello\nworld \"quoted\" # not a comment"},
+		},
+		{
+			name: "single quoted",
+			src:  `LITERAL='no\nescapes'`,
+			want: map[string]string{"LITERAL": `no\nescapes`},
+		},
+		{
+			name: "empty value",
+			src:  "EMPTY=\nQUOTED=\"\"",
+			want: map[string]string{"EMPTY": "", "QUOTED": ""},
+		},
+		{
+			name: "equals in value",
+			src:  "QUERY=a=b",
+			want: map[string]string{"QUERY": "a=b"},
+		},
+		{
+			name:    "missing equals",
+			src:     "BASE",
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			src:     "=value",
+			wantErr: true,
+		},
+		{
+			name:    "space in name",
+			src:     "MY VAR=value",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			src:     `GREETING="hello`,
+			wantErr: true,
+		},
+		{
+			name:    "text after quote",
+			src:     `GREETING="hello" world`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vars.ParseDotenv(strings.NewReader(tt.src))
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.EqualFunc(t, got, tt.want, maps.Equal)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		want     map[string]string // Expected variables
+		name     string            // Name of the test case
+		file     string            // Name of the file
+		contents string            // Contents of the file
+		wantErr  bool              // Whether we want an error
+	}{
+		{
+			name:     "json",
+			file:     "vars.json",
+			contents: `{"base": "https://api.com", "port": 8080, "debug": true}`,
+			want:     map[string]string{"base": "https://api.com", "port": "8080", "debug": "true"},
+		},
+		{
+			name:     "dotenv",
+			file:     ".env",
+			contents: "base=https://api.com\nport=8080",
+			want:     map[string]string{"base": "https://api.com", "port": "8080"},
+		},
+		{
+			name:     "other extension is dotenv",
+			file:     "ci.vars",
+			contents: "base=https://api.com",
+			want:     map[string]string{"base": "https://api.com"},
+		},
+		{
+			name:     "invalid json",
+			file:     "vars.json",
+			contents: `{"base": }`,
+			wantErr:  true,
+		},
+		{
+			name:     "nested json",
+			file:     "vars.json",
+			contents: `{"dev": {"base": "https://api.com"}}`,
+			want:     map[string]string{"dev": `{"base":"https://api.com"}`},
+		},
+		{
+			name:     "invalid dotenv",
+			file:     ".env",
+			contents: "base",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			test.Ok(t, os.WriteFile(path, []byte(tt.contents), 0o644))
+
+			got, err := vars.ReadFile(path)
+			test.WantErr(t, err, tt.wantErr)
+
+			if !tt.wantErr {
+				test.EqualFunc(t, got, tt.want, maps.Equal)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := vars.ReadFile(filepath.Join(t.TempDir(), "missing.env"))
+		test.Err(t, err)
+	})
+}
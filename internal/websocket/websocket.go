@@ -0,0 +1,584 @@
+// Package websocket implements just enough of the WebSocket protocol (RFC 6455) to send
+// and receive messages from a .http file, and to serve them in tests.
+//
+// It supports text and binary messages, fragmented messages and the close handshake,
+// pings are answered automatically. Extensions (such as compression) and subprotocols
+// are not negotiated, and messages larger than 32MB are refused.
+package websocket
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // Required by the protocol, not used for security
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned from [Conn.Read] once the connection has been closed, either by
+// the other side or with [Conn.Close].
+var ErrClosed = errors.New("websocket: connection closed")
+
+// acceptGUID is the fixed GUID the server hashes with the client's key to prove it
+// understands the protocol.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Protocol config.
+const (
+	// closeTimeout is how long [Conn.Close] waits for the other side to acknowledge the close.
+	closeTimeout = 1 * time.Second
+
+	keyLength         = 16       // Length of the random Sec-WebSocket-Key before encoding
+	maxControlPayload = 125      // The largest payload a control frame may carry, and the largest 7 bit length
+	maxMessageSize    = 32 << 20 // The largest message, or frame of one, that will be read
+	length16          = 126      // The 7 bit length that means a 16 bit length follows
+	length64          = 127      // The 7 bit length that means a 64 bit length follows
+	statusLength      = 2        // Length of the status code in a close frame
+)
+
+// Type is the type of a [Message].
+type Type byte
+
+// Message types, the values are the opcodes in the protocol.
+const (
+	Text   Type = 0x1 // A UTF-8 text message
+	Binary Type = 0x2 // A binary message
+)
+
+// String implements [fmt.Stringer] for a [Type].
+func (t Type) String() string {
+	switch t {
+	case Text:
+		return "text"
+	case Binary:
+		return "binary"
+	default:
+		return fmt.Sprintf("Type(%d)", byte(t))
+	}
+}
+
+// Control opcodes.
+const (
+	opContinuation byte = 0x0
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+// Frame header bits.
+const (
+	finBit     byte = 0x80
+	maskBit    byte = 0x80
+	opcodeBits byte = 0x0F
+)
+
+// Close status codes.
+const (
+	closeNormal   = 1000
+	closeProtocol = 1002
+	closeTooBig   = 1009
+)
+
+// Message is a complete WebSocket message, reassembled from any fragments.
+type Message struct {
+	Data []byte // The message payload
+	Type Type   // Whether it's a text or binary message
+}
+
+// Conn is a WebSocket connection, it's safe for one goroutine to read while
+// another writes.
+type Conn struct {
+	conn      net.Conn      // The underlying connection
+	reader    *bufio.Reader // Buffered reader over conn, may hold bytes read during the handshake
+	done      chan struct{} // Closed once reading stops, after a close frame or an error
+	pending   []Message     // Messages read by Close while waiting for the acknowledgement, returned by Read first
+	readMu    sync.Mutex    // Held while reading, so Close knows whether to read the acknowledgement itself
+	writeMu   sync.Mutex    // Frames must not be interleaved
+	closeOnce sync.Once     // Guards sending the close frame
+	doneOnce  sync.Once     // Guards closing done
+	client    bool          // Clients mask every frame they send, servers never do
+}
+
+// Dial opens a WebSocket connection to rawURL, which must be a ws:// or wss:// URL,
+// sending header with the opening handshake.
+//
+// The returned [http.Response] is the server's handshake response, it has no body.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket: invalid URL: %w", err)
+	}
+
+	var dialer interface {
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
+	}
+
+	port := u.Port()
+
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+		port = cmp.Or(port, "80")
+		dialer = &net.Dialer{}
+	case "wss":
+		u.Scheme = "https"
+		port = cmp.Or(port, "443")
+		dialer = &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+	default:
+		return nil, nil, fmt.Errorf("websocket: URL scheme must be ws or wss, got %q", u.Scheme)
+	}
+
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	// The handshake must finish within the context's deadline, after that the
+	// connection lives as long as it's needed
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("websocket: %w", err)
+		}
+	}
+
+	c, response, err := handshake(conn, u, header)
+	if err != nil {
+		conn.Close()
+		return nil, response, err
+	}
+
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	return c, response, nil
+}
+
+// handshake performs the client side of the opening handshake on conn.
+func handshake(conn net.Conn, u *url.URL, header http.Header) (*Conn, *http.Response, error) {
+	nonce := make([]byte, keyLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("websocket: could not generate key: %w", err)
+	}
+
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header.Clone(),
+		Host:       u.Host,
+	}
+
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := request.Write(conn); err != nil {
+		return nil, nil, fmt.Errorf("websocket: could not send handshake: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("websocket: could not read handshake response: %w", err)
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, response, fmt.Errorf("websocket: handshake failed: %s", response.Status)
+	}
+
+	if !strings.EqualFold(response.Header.Get("Upgrade"), "websocket") {
+		return nil, response, errors.New("websocket: handshake response is missing 'Upgrade: websocket'")
+	}
+
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, response, errors.New("websocket: handshake response has the wrong Sec-WebSocket-Accept")
+	}
+
+	return newConn(conn, reader, true), response, nil
+}
+
+// Accept upgrades a HTTP request to a WebSocket connection, it's the server side of [Dial].
+//
+// On error, a response has already been written to w.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a WebSocket upgrade request")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+
+		return nil, errors.New("websocket: unsupported version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing Sec-WebSocket-Key")
+	}
+
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "could not upgrade connection", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: %w", err)
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(buf, "Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(buf, "Sec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+
+	if err = buf.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: could not send handshake response: %w", err)
+	}
+
+	return newConn(conn, buf.Reader, false), nil
+}
+
+// newConn returns a new [Conn].
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		conn:   conn,
+		reader: reader,
+		done:   make(chan struct{}),
+		client: client,
+	}
+}
+
+// Write sends a single, unfragmented message.
+func (c *Conn) Write(typ Type, data []byte) error {
+	return c.writeFrame(byte(typ), data)
+}
+
+// Read reads the next complete message, answering any pings and reassembling any
+// fragments along the way.
+//
+// Once the connection is closed, by either side, Read returns [ErrClosed].
+func (c *Conn) Read() (Message, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if len(c.pending) > 0 {
+		message := c.pending[0]
+		c.pending = c.pending[1:]
+
+		return message, nil
+	}
+
+	message, err := c.read()
+	if err != nil {
+		c.doneOnce.Do(func() { close(c.done) })
+	}
+
+	return message, err
+}
+
+// read implements [Conn.Read].
+func (c *Conn) read() (Message, error) {
+	var message Message
+
+	for {
+		fin, opcode, payload, err := c.readFrame(maxMessageSize - uint64(len(message.Data)))
+		if err != nil {
+			return Message{}, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return Message{}, err
+			}
+		case opPong:
+			// Unsolicited pongs are allowed and ignored
+		case opClose:
+			return Message{}, c.handleClose(payload)
+		case byte(Text), byte(Binary):
+			if message.Type != 0 {
+				return Message{}, c.fail("new message before the last one finished")
+			}
+
+			message.Type = Type(opcode)
+			message.Data = payload
+		case opContinuation:
+			if message.Type == 0 {
+				return Message{}, c.fail("continuation frame without a message")
+			}
+
+			message.Data = append(message.Data, payload...)
+		default:
+			return Message{}, c.fail(fmt.Sprintf("unknown opcode %#x", opcode))
+		}
+
+		if fin && message.Type != 0 {
+			return message, nil
+		}
+	}
+}
+
+// Close starts the close handshake, waiting briefly for the other side to acknowledge
+// it before closing the underlying connection.
+//
+// If another goroutine is blocked in [Conn.Read], it reads the acknowledgement, otherwise
+// Close reads it itself and any messages sent before it are returned by later calls
+// to [Conn.Read].
+func (c *Conn) Close() error {
+	payload := binary.BigEndian.AppendUint16(nil, closeNormal)
+
+	var err error
+
+	c.closeOnce.Do(func() {
+		err = c.writeFrame(opClose, payload)
+	})
+
+	if err == nil {
+		if c.readMu.TryLock() {
+			c.drain()
+			c.readMu.Unlock()
+		} else {
+			select {
+			case <-c.done:
+			case <-time.After(closeTimeout):
+			}
+		}
+	}
+
+	// The connection is already closed if the other side acknowledged
+	closeErr := c.conn.Close()
+	if errors.Is(closeErr, net.ErrClosed) {
+		closeErr = nil
+	}
+
+	return errors.Join(err, closeErr)
+}
+
+// drain reads messages into pending until the other side acknowledges the close, or
+// the close timeout passes. The caller must hold readMu.
+func (c *Conn) drain() {
+	if err := c.conn.SetReadDeadline(time.Now().Add(closeTimeout)); err != nil {
+		return
+	}
+
+	for {
+		message, err := c.read()
+		if err != nil {
+			c.doneOnce.Do(func() { close(c.done) })
+			return
+		}
+
+		c.pending = append(c.pending, message)
+	}
+}
+
+// handleClose handles a close frame from the other side, echoing it back if we didn't
+// start the close, and returns [ErrClosed].
+func (c *Conn) handleClose(payload []byte) error {
+	c.closeOnce.Do(func() {
+		// Echo the status code only, the reason is for the other side's benefit
+		if len(payload) > statusLength {
+			payload = payload[:statusLength]
+		}
+
+		c.writeFrame(opClose, payload) //nolint:errcheck // We're closing anyway
+	})
+
+	c.conn.Close()
+
+	return ErrClosed
+}
+
+// fail closes the connection after a protocol error.
+func (c *Conn) fail(reason string) error {
+	return c.abort(closeProtocol, "protocol error: "+reason)
+}
+
+// abort closes the connection with status after an error reading, returning an error
+// with reason.
+func (c *Conn) abort(status uint16, reason string) error {
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, binary.BigEndian.AppendUint16(nil, status)) //nolint:errcheck // We're failing anyway
+	})
+
+	c.conn.Close()
+
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// readFrame reads a single frame, unmasking it's payload if needed.
+//
+// A data frame with a payload longer than limit, what's left of the maximum message size,
+// closes the connection rather than being read.
+func (c *Conn) readFrame(limit uint64) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, c.readErr(err)
+	}
+
+	fin = header[0]&finBit != 0
+	opcode = header[0] & opcodeBits
+	masked := header[1]&maskBit != 0
+	length := uint64(header[1] &^ maskBit)
+
+	switch length {
+	case length16:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case length64:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+
+		length = binary.BigEndian.Uint64(extended[:])
+
+		// The most significant bit must be 0
+		if length > math.MaxInt64 {
+			return false, 0, nil, c.fail("invalid frame length")
+		}
+	}
+
+	if opcode >= opClose && (!fin || length > maxControlPayload) {
+		return false, 0, nil, c.fail("invalid control frame")
+	}
+
+	if opcode < opClose && length > limit {
+		return false, 0, nil, c.abort(closeTooBig, fmt.Sprintf("message too big, the limit is %d bytes", maxMessageSize))
+	}
+
+	// Clients must mask, servers mustn't
+	if masked == c.client {
+		return false, 0, nil, c.fail("incorrectly masked frame")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, c.readErr(err)
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, c.readErr(err)
+	}
+
+	if masked {
+		applyMask(payload, mask)
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single, final frame.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{finBit | opcode}
+
+	var lengthByte byte
+	if c.client {
+		lengthByte = maskBit
+	}
+
+	switch length := len(payload); {
+	case length <= maxControlPayload:
+		frame = append(frame, lengthByte|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, lengthByte|length16)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, lengthByte|length64)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("websocket: could not generate mask: %w", err)
+		}
+
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		applyMask(frame[start:], mask)
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+
+	return nil
+}
+
+// readErr converts an error reading from the connection, a connection closed by
+// us is reported as [ErrClosed].
+func (c *Conn) readErr(err error) error {
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		return ErrClosed
+	}
+
+	return fmt.Errorf("websocket: %w", err)
+}
+
+// applyMask masks (or unmasks) data in place.
+func applyMask(data []byte, mask [4]byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value for a Sec-WebSocket-Key.
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID)) //nolint:gosec // Required by the protocol
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether any of the comma separated values of the header called
+// name is value, case insensitively.
+func headerContains(header http.Header, name, value string) bool {
+	for _, line := range header.Values(name) {
+		for item := range strings.SplitSeq(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
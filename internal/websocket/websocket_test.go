@@ -0,0 +1,340 @@
+package websocket_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.followtheprocess.codes/req/internal/websocket"
+	"go.followtheprocess.codes/test"
+)
+
+func TestEcho(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	defer server.Close()
+
+	tests := []struct {
+		name string         // Name of the test case
+		data string         // The message to send
+		typ  websocket.Type // The message type
+	}{
+		{
+			name: "empty",
+			data: "",
+			typ:  websocket.Text,
+		},
+		{
+			name: "text",
+			data: `{"message": "hello"}`,
+			typ:  websocket.Text,
+		},
+		{
+			name: "binary",
+			data: "\x00\x01\x02\xff",
+			typ:  websocket.Binary,
+		},
+		{
+			name: "16 bit length",
+			data: strings.Repeat("a", 300),
+			typ:  websocket.Text,
+		},
+		{
+			name: "64 bit length",
+			data: strings.Repeat("b", 70000),
+			typ:  websocket.Binary,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, response, err := websocket.Dial(context.Background(), wsURL(server), nil)
+			test.Ok(t, err)
+
+			defer conn.Close()
+
+			test.Equal(t, response.StatusCode, http.StatusSwitchingProtocols)
+
+			test.Ok(t, conn.Write(tt.typ, []byte(tt.data)))
+
+			got, err := conn.Read()
+			test.Ok(t, err)
+
+			test.Equal(t, got.Type, tt.typ)
+			test.Equal(t, string(got.Data), tt.data)
+		})
+	}
+}
+
+func TestDialHeaders(t *testing.T) {
+	var got string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		echo(w, r)
+	}))
+	defer server.Close()
+
+	header := http.Header{"Authorization": []string{"Bearer token"}}
+
+	conn, _, err := websocket.Dial(context.Background(), wsURL(server), header)
+	test.Ok(t, err)
+	test.Ok(t, conn.Close())
+
+	test.Equal(t, got, "Bearer token")
+}
+
+func TestDialNotWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, response, err := websocket.Dial(context.Background(), wsURL(server), nil)
+	test.Err(t, err)
+	test.Equal(t, err.Error(), "websocket: handshake failed: 200 OK")
+	test.Equal(t, response.StatusCode, http.StatusOK)
+}
+
+func TestDialBadScheme(t *testing.T) {
+	_, _, err := websocket.Dial(context.Background(), "http://localhost", nil)
+	test.Err(t, err)
+	test.Equal(t, err.Error(), `websocket: URL scheme must be ws or wss, got "http"`)
+}
+
+func TestAcceptNotWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	test.Ok(t, err)
+
+	defer response.Body.Close()
+
+	test.Equal(t, response.StatusCode, http.StatusBadRequest)
+}
+
+func TestServerClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+
+		conn.Write(websocket.Text, []byte("bye"))
+		conn.Close()
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), wsURL(server), nil)
+	test.Ok(t, err)
+
+	message, err := conn.Read()
+	test.Ok(t, err)
+	test.Equal(t, string(message.Data), "bye")
+
+	_, err = conn.Read()
+	test.True(t, errors.Is(err, websocket.ErrClosed), test.Context("wrong error: %v", err))
+
+	// Closing after the server has doesn't wait for an acknowledgement
+	start := time.Now()
+
+	conn.Close()
+	test.True(t, time.Since(start) < 500*time.Millisecond, test.Context("close took %s", time.Since(start)))
+}
+
+func TestCloseKeepsMessages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		test.Ok(t, conn.Write(websocket.Text, []byte("hello")))
+
+		// Read until the client closes, acknowledging it
+		for {
+			if _, err := conn.Read(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), wsURL(server), nil)
+	test.Ok(t, err)
+
+	// Nothing is reading, so Close reads the acknowledgement itself but any
+	// message before it isn't lost
+	test.Ok(t, conn.Close())
+
+	message, err := conn.Read()
+	test.Ok(t, err)
+	test.Equal(t, string(message.Data), "hello")
+
+	_, err = conn.Read()
+	test.True(t, errors.Is(err, websocket.ErrClosed), test.Context("wrong error: %v", err))
+}
+
+func TestPingAndFragments(t *testing.T) {
+	pong := make(chan string, 1)
+
+	// A raw server, so it can send frames the websocket server never would
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		buf.WriteString("Sec-WebSocket-Accept: " + accept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+
+		buf.Write([]byte{0x89, 0x04, 'p', 'i', 'n', 'g'}) // Ping "ping"
+		buf.Write([]byte{0x01, 0x03, 'h', 'e', 'l'})      // Text "hel", not final
+		buf.Write([]byte{0x80, 0x02, 'l', 'o'})           // Continuation "lo", final
+		buf.Write([]byte{0x88, 0x02, 0x03, 0xe8})         // Close 1000
+		buf.Flush()
+
+		pong <- readFrame(buf.Reader)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.Dial(context.Background(), wsURL(server), nil)
+	test.Ok(t, err)
+
+	message, err := conn.Read()
+	test.Ok(t, err)
+	test.Equal(t, message.Type, websocket.Text)
+	test.Equal(t, string(message.Data), "hello")
+
+	_, err = conn.Read()
+	test.True(t, errors.Is(err, websocket.ErrClosed), test.Context("wrong error: %v", err))
+
+	test.Equal(t, <-pong, "pong: ping")
+}
+
+func TestTooBig(t *testing.T) {
+	tests := []struct {
+		name   string // Name of the test case
+		frames []byte // The raw frames the server sends
+		errMsg string // The error reading the message
+		closed string // The close frame sent back, describing the status code
+	}{
+		{
+			name:   "length with the top bit set",
+			frames: []byte{0x82, 0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			errMsg: "websocket: protocol error: invalid frame length",
+			closed: "close: \x03\xea", // 1002
+		},
+		{
+			name:   "frame too big",
+			frames: []byte{0x82, 0x7F, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, // 1TB
+			errMsg: "websocket: message too big, the limit is 33554432 bytes",
+			closed: "close: \x03\xf1", // 1009
+		},
+		{
+			name: "fragments too big",
+			frames: []byte{
+				0x02, 0x01, 'a', // Binary "a", not final
+				0x80, 0x7F, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, // Continuation of exactly 32MB, final
+			},
+			errMsg: "websocket: message too big, the limit is 33554432 bytes",
+			closed: "close: \x03\xf1", // 1009
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closed := make(chan string, 1)
+
+			// A raw server, it only sends the headers of the frames so a client that
+			// tries to read or allocate the payload would hang or blow up
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+
+				buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+				buf.WriteString("Sec-WebSocket-Accept: " + accept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+				buf.Write(tt.frames)
+				buf.Flush()
+
+				closed <- readFrame(buf.Reader)
+			}))
+			defer server.Close()
+
+			conn, _, err := websocket.Dial(context.Background(), wsURL(server), nil)
+			test.Ok(t, err)
+
+			_, err = conn.Read()
+			test.Err(t, err)
+			test.Equal(t, err.Error(), tt.errMsg)
+
+			test.Equal(t, <-closed, tt.closed)
+		})
+	}
+}
+
+// echo is a [http.HandlerFunc] that echoes every message back.
+func echo(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		message, err := conn.Read()
+		if err != nil {
+			return
+		}
+
+		if err := conn.Write(message.Type, message.Data); err != nil {
+			return
+		}
+	}
+}
+
+// wsURL returns the ws:// URL of a test server.
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// accept computes Sec-WebSocket-Accept for a Sec-WebSocket-Key.
+func accept(key string) string {
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// readFrame reads a single small, masked frame from a client and describes it
+// e.g. "pong: ping".
+func readFrame(r *bufio.Reader) string {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err.Error()
+	}
+
+	payload := make([]byte, header[1]&0x7F)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return err.Error()
+	}
+
+	for i := range payload {
+		payload[i] ^= header[2+i%4]
+	}
+
+	kind := map[byte]string{0x8: "close", 0x9: "ping", 0xA: "pong"}[header[0]&0x0F]
+
+	return kind + ": " + string(payload)
+}